@@ -0,0 +1,1066 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: tracker/v1/tracker.proto
+
+package trackerv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type LogEntry struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Endpoint string                 `protobuf:"bytes,1,opt,name=endpoint,proto3" json:"endpoint,omitempty"`
+	// Used as the idempotency key when set.
+	EventId string `protobuf:"bytes,2,opt,name=event_id,json=eventId,proto3" json:"event_id,omitempty"`
+	// Event time in Unix milliseconds; the server time is used when unset.
+	TimestampUnixMs *int64 `protobuf:"varint,3,opt,name=timestamp_unix_ms,json=timestampUnixMs,proto3,oneof" json:"timestamp_unix_ms,omitempty"`
+	// Defaults to the caller's address.
+	IpAddress     string  `protobuf:"bytes,4,opt,name=ip_address,json=ipAddress,proto3" json:"ip_address,omitempty"`
+	Method        string  `protobuf:"bytes,5,opt,name=method,proto3" json:"method,omitempty"`
+	StatusCode    *uint32 `protobuf:"varint,6,opt,name=status_code,json=statusCode,proto3,oneof" json:"status_code,omitempty"`
+	LatencyMs     *uint32 `protobuf:"varint,7,opt,name=latency_ms,json=latencyMs,proto3,oneof" json:"latency_ms,omitempty"`
+	RequestBytes  *uint64 `protobuf:"varint,8,opt,name=request_bytes,json=requestBytes,proto3,oneof" json:"request_bytes,omitempty"`
+	ResponseBytes *uint64 `protobuf:"varint,9,opt,name=response_bytes,json=responseBytes,proto3,oneof" json:"response_bytes,omitempty"`
+	UserAgent     string  `protobuf:"bytes,10,opt,name=user_agent,json=userAgent,proto3" json:"user_agent,omitempty"`
+	// Custom dimensions such as env or region; subject to per-client cardinality limits.
+	Labels map[string]string `protobuf:"bytes,11,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Opaque ID of the caller's end user; stored hashed unless the client opted for raw IDs.
+	UserId        string `protobuf:"bytes,12,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogEntry) Reset() {
+	*x = LogEntry{}
+	mi := &file_tracker_v1_tracker_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogEntry) ProtoMessage() {}
+
+func (x *LogEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_tracker_v1_tracker_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogEntry.ProtoReflect.Descriptor instead.
+func (*LogEntry) Descriptor() ([]byte, []int) {
+	return file_tracker_v1_tracker_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *LogEntry) GetEndpoint() string {
+	if x != nil {
+		return x.Endpoint
+	}
+	return ""
+}
+
+func (x *LogEntry) GetEventId() string {
+	if x != nil {
+		return x.EventId
+	}
+	return ""
+}
+
+func (x *LogEntry) GetTimestampUnixMs() int64 {
+	if x != nil && x.TimestampUnixMs != nil {
+		return *x.TimestampUnixMs
+	}
+	return 0
+}
+
+func (x *LogEntry) GetIpAddress() string {
+	if x != nil {
+		return x.IpAddress
+	}
+	return ""
+}
+
+func (x *LogEntry) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *LogEntry) GetStatusCode() uint32 {
+	if x != nil && x.StatusCode != nil {
+		return *x.StatusCode
+	}
+	return 0
+}
+
+func (x *LogEntry) GetLatencyMs() uint32 {
+	if x != nil && x.LatencyMs != nil {
+		return *x.LatencyMs
+	}
+	return 0
+}
+
+func (x *LogEntry) GetRequestBytes() uint64 {
+	if x != nil && x.RequestBytes != nil {
+		return *x.RequestBytes
+	}
+	return 0
+}
+
+func (x *LogEntry) GetResponseBytes() uint64 {
+	if x != nil && x.ResponseBytes != nil {
+		return *x.ResponseBytes
+	}
+	return 0
+}
+
+func (x *LogEntry) GetUserAgent() string {
+	if x != nil {
+		return x.UserAgent
+	}
+	return ""
+}
+
+func (x *LogEntry) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *LogEntry) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+type RecordLogResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	HitId         string                 `protobuf:"bytes,1,opt,name=hit_id,json=hitId,proto3" json:"hit_id,omitempty"`
+	Duplicate     bool                   `protobuf:"varint,2,opt,name=duplicate,proto3" json:"duplicate,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RecordLogResponse) Reset() {
+	*x = RecordLogResponse{}
+	mi := &file_tracker_v1_tracker_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecordLogResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecordLogResponse) ProtoMessage() {}
+
+func (x *RecordLogResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tracker_v1_tracker_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecordLogResponse.ProtoReflect.Descriptor instead.
+func (*RecordLogResponse) Descriptor() ([]byte, []int) {
+	return file_tracker_v1_tracker_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RecordLogResponse) GetHitId() string {
+	if x != nil {
+		return x.HitId
+	}
+	return ""
+}
+
+func (x *RecordLogResponse) GetDuplicate() bool {
+	if x != nil {
+		return x.Duplicate
+	}
+	return false
+}
+
+type StreamError struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 1-based position of the message in the stream.
+	Index         uint32 `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Error         string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamError) Reset() {
+	*x = StreamError{}
+	mi := &file_tracker_v1_tracker_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamError) ProtoMessage() {}
+
+func (x *StreamError) ProtoReflect() protoreflect.Message {
+	mi := &file_tracker_v1_tracker_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamError.ProtoReflect.Descriptor instead.
+func (*StreamError) Descriptor() ([]byte, []int) {
+	return file_tracker_v1_tracker_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *StreamError) GetIndex() uint32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *StreamError) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type StreamLogsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Received      uint32                 `protobuf:"varint,1,opt,name=received,proto3" json:"received,omitempty"`
+	Accepted      uint32                 `protobuf:"varint,2,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	Duplicates    uint32                 `protobuf:"varint,3,opt,name=duplicates,proto3" json:"duplicates,omitempty"`
+	Rejected      uint32                 `protobuf:"varint,4,opt,name=rejected,proto3" json:"rejected,omitempty"`
+	Errors        []*StreamError         `protobuf:"bytes,5,rep,name=errors,proto3" json:"errors,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamLogsResponse) Reset() {
+	*x = StreamLogsResponse{}
+	mi := &file_tracker_v1_tracker_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamLogsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamLogsResponse) ProtoMessage() {}
+
+func (x *StreamLogsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tracker_v1_tracker_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamLogsResponse.ProtoReflect.Descriptor instead.
+func (*StreamLogsResponse) Descriptor() ([]byte, []int) {
+	return file_tracker_v1_tracker_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StreamLogsResponse) GetReceived() uint32 {
+	if x != nil {
+		return x.Received
+	}
+	return 0
+}
+
+func (x *StreamLogsResponse) GetAccepted() uint32 {
+	if x != nil {
+		return x.Accepted
+	}
+	return 0
+}
+
+func (x *StreamLogsResponse) GetDuplicates() uint32 {
+	if x != nil {
+		return x.Duplicates
+	}
+	return 0
+}
+
+func (x *StreamLogsResponse) GetRejected() uint32 {
+	if x != nil {
+		return x.Rejected
+	}
+	return 0
+}
+
+func (x *StreamLogsResponse) GetErrors() []*StreamError {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+type GetDailyUsageRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only count hits carrying all of these labels.
+	Labels map[string]string `protobuf:"bytes,1,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Split usage by the values of this label key.
+	GroupBy string `protobuf:"bytes,2,opt,name=group_by,json=groupBy,proto3" json:"group_by,omitempty"`
+	// Range start and end as YYYY-MM-DD (end inclusive) or RFC 3339; by default
+	// the series ends with the current bucket.
+	From string `protobuf:"bytes,3,opt,name=from,proto3" json:"from,omitempty"`
+	To   string `protobuf:"bytes,4,opt,name=to,proto3" json:"to,omitempty"`
+	// IANA time zone of the buckets; the server's when unset.
+	Tz string `protobuf:"bytes,5,opt,name=tz,proto3" json:"tz,omitempty"`
+	// hour, day (default), week or month.
+	Granularity   string `protobuf:"bytes,6,opt,name=granularity,proto3" json:"granularity,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetDailyUsageRequest) Reset() {
+	*x = GetDailyUsageRequest{}
+	mi := &file_tracker_v1_tracker_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetDailyUsageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDailyUsageRequest) ProtoMessage() {}
+
+func (x *GetDailyUsageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tracker_v1_tracker_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDailyUsageRequest.ProtoReflect.Descriptor instead.
+func (*GetDailyUsageRequest) Descriptor() ([]byte, []int) {
+	return file_tracker_v1_tracker_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetDailyUsageRequest) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *GetDailyUsageRequest) GetGroupBy() string {
+	if x != nil {
+		return x.GroupBy
+	}
+	return ""
+}
+
+func (x *GetDailyUsageRequest) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *GetDailyUsageRequest) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *GetDailyUsageRequest) GetTz() string {
+	if x != nil {
+		return x.Tz
+	}
+	return ""
+}
+
+func (x *GetDailyUsageRequest) GetGranularity() string {
+	if x != nil {
+		return x.Granularity
+	}
+	return ""
+}
+
+type DayUsage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Date          string                 `protobuf:"bytes,1,opt,name=date,proto3" json:"date,omitempty"`
+	RequestCount  int64                  `protobuf:"varint,2,opt,name=request_count,json=requestCount,proto3" json:"request_count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DayUsage) Reset() {
+	*x = DayUsage{}
+	mi := &file_tracker_v1_tracker_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DayUsage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DayUsage) ProtoMessage() {}
+
+func (x *DayUsage) ProtoReflect() protoreflect.Message {
+	mi := &file_tracker_v1_tracker_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DayUsage.ProtoReflect.Descriptor instead.
+func (*DayUsage) Descriptor() ([]byte, []int) {
+	return file_tracker_v1_tracker_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DayUsage) GetDate() string {
+	if x != nil {
+		return x.Date
+	}
+	return ""
+}
+
+func (x *DayUsage) GetRequestCount() int64 {
+	if x != nil {
+		return x.RequestCount
+	}
+	return 0
+}
+
+type DailyUsageResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ClientId      string                 `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	StartDate     string                 `protobuf:"bytes,2,opt,name=start_date,json=startDate,proto3" json:"start_date,omitempty"`
+	EndDate       string                 `protobuf:"bytes,3,opt,name=end_date,json=endDate,proto3" json:"end_date,omitempty"`
+	Usage         []*DayUsage            `protobuf:"bytes,4,rep,name=usage,proto3" json:"usage,omitempty"`
+	Labels        map[string]string      `protobuf:"bytes,5,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	GroupBy       string                 `protobuf:"bytes,6,opt,name=group_by,json=groupBy,proto3" json:"group_by,omitempty"`
+	Groups        []*LabelUsage          `protobuf:"bytes,7,rep,name=groups,proto3" json:"groups,omitempty"`
+	Tz            string                 `protobuf:"bytes,8,opt,name=tz,proto3" json:"tz,omitempty"`
+	Granularity   string                 `protobuf:"bytes,9,opt,name=granularity,proto3" json:"granularity,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DailyUsageResponse) Reset() {
+	*x = DailyUsageResponse{}
+	mi := &file_tracker_v1_tracker_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DailyUsageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DailyUsageResponse) ProtoMessage() {}
+
+func (x *DailyUsageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tracker_v1_tracker_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DailyUsageResponse.ProtoReflect.Descriptor instead.
+func (*DailyUsageResponse) Descriptor() ([]byte, []int) {
+	return file_tracker_v1_tracker_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DailyUsageResponse) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *DailyUsageResponse) GetStartDate() string {
+	if x != nil {
+		return x.StartDate
+	}
+	return ""
+}
+
+func (x *DailyUsageResponse) GetEndDate() string {
+	if x != nil {
+		return x.EndDate
+	}
+	return ""
+}
+
+func (x *DailyUsageResponse) GetUsage() []*DayUsage {
+	if x != nil {
+		return x.Usage
+	}
+	return nil
+}
+
+func (x *DailyUsageResponse) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *DailyUsageResponse) GetGroupBy() string {
+	if x != nil {
+		return x.GroupBy
+	}
+	return ""
+}
+
+func (x *DailyUsageResponse) GetGroups() []*LabelUsage {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+func (x *DailyUsageResponse) GetTz() string {
+	if x != nil {
+		return x.Tz
+	}
+	return ""
+}
+
+func (x *DailyUsageResponse) GetGranularity() string {
+	if x != nil {
+		return x.Granularity
+	}
+	return ""
+}
+
+type LabelUsage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         string                 `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Usage         []*DayUsage            `protobuf:"bytes,2,rep,name=usage,proto3" json:"usage,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LabelUsage) Reset() {
+	*x = LabelUsage{}
+	mi := &file_tracker_v1_tracker_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LabelUsage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LabelUsage) ProtoMessage() {}
+
+func (x *LabelUsage) ProtoReflect() protoreflect.Message {
+	mi := &file_tracker_v1_tracker_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LabelUsage.ProtoReflect.Descriptor instead.
+func (*LabelUsage) Descriptor() ([]byte, []int) {
+	return file_tracker_v1_tracker_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *LabelUsage) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *LabelUsage) GetUsage() []*DayUsage {
+	if x != nil {
+		return x.Usage
+	}
+	return nil
+}
+
+type GetTopClientsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only count hits carrying all of these labels.
+	Labels map[string]string `protobuf:"bytes,1,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Number of clients, 1 to 100; defaults to 3.
+	Limit int32 `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	// 1h, 24h, 7d, 30d or a custom duration such as 6h or 14d; defaults to 24h.
+	Window string `protobuf:"bytes,3,opt,name=window,proto3" json:"window,omitempty"`
+	// requests, ips or errors; defaults to requests.
+	Metric        string `protobuf:"bytes,4,opt,name=metric,proto3" json:"metric,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTopClientsRequest) Reset() {
+	*x = GetTopClientsRequest{}
+	mi := &file_tracker_v1_tracker_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTopClientsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTopClientsRequest) ProtoMessage() {}
+
+func (x *GetTopClientsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tracker_v1_tracker_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTopClientsRequest.ProtoReflect.Descriptor instead.
+func (*GetTopClientsRequest) Descriptor() ([]byte, []int) {
+	return file_tracker_v1_tracker_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetTopClientsRequest) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *GetTopClientsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *GetTopClientsRequest) GetWindow() string {
+	if x != nil {
+		return x.Window
+	}
+	return ""
+}
+
+func (x *GetTopClientsRequest) GetMetric() string {
+	if x != nil {
+		return x.Metric
+	}
+	return ""
+}
+
+type TopClient struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	ClientId     string                 `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	Name         string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	RequestCount int64                  `protobuf:"varint,3,opt,name=request_count,json=requestCount,proto3" json:"request_count,omitempty"`
+	// The client's value of the requested metric.
+	Value         int64 `protobuf:"varint,4,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TopClient) Reset() {
+	*x = TopClient{}
+	mi := &file_tracker_v1_tracker_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TopClient) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TopClient) ProtoMessage() {}
+
+func (x *TopClient) ProtoReflect() protoreflect.Message {
+	mi := &file_tracker_v1_tracker_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TopClient.ProtoReflect.Descriptor instead.
+func (*TopClient) Descriptor() ([]byte, []int) {
+	return file_tracker_v1_tracker_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *TopClient) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *TopClient) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *TopClient) GetRequestCount() int64 {
+	if x != nil {
+		return x.RequestCount
+	}
+	return 0
+}
+
+func (x *TopClient) GetValue() int64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+type TopClientsResponse struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Period          string                 `protobuf:"bytes,1,opt,name=period,proto3" json:"period,omitempty"`
+	GeneratedAtUnix int64                  `protobuf:"varint,2,opt,name=generated_at_unix,json=generatedAtUnix,proto3" json:"generated_at_unix,omitempty"`
+	TopClients      []*TopClient           `protobuf:"bytes,3,rep,name=top_clients,json=topClients,proto3" json:"top_clients,omitempty"`
+	TotalClients    int32                  `protobuf:"varint,4,opt,name=total_clients,json=totalClients,proto3" json:"total_clients,omitempty"`
+	Labels          map[string]string      `protobuf:"bytes,5,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Window          string                 `protobuf:"bytes,6,opt,name=window,proto3" json:"window,omitempty"`
+	Metric          string                 `protobuf:"bytes,7,opt,name=metric,proto3" json:"metric,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *TopClientsResponse) Reset() {
+	*x = TopClientsResponse{}
+	mi := &file_tracker_v1_tracker_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TopClientsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TopClientsResponse) ProtoMessage() {}
+
+func (x *TopClientsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tracker_v1_tracker_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TopClientsResponse.ProtoReflect.Descriptor instead.
+func (*TopClientsResponse) Descriptor() ([]byte, []int) {
+	return file_tracker_v1_tracker_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *TopClientsResponse) GetPeriod() string {
+	if x != nil {
+		return x.Period
+	}
+	return ""
+}
+
+func (x *TopClientsResponse) GetGeneratedAtUnix() int64 {
+	if x != nil {
+		return x.GeneratedAtUnix
+	}
+	return 0
+}
+
+func (x *TopClientsResponse) GetTopClients() []*TopClient {
+	if x != nil {
+		return x.TopClients
+	}
+	return nil
+}
+
+func (x *TopClientsResponse) GetTotalClients() int32 {
+	if x != nil {
+		return x.TotalClients
+	}
+	return 0
+}
+
+func (x *TopClientsResponse) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *TopClientsResponse) GetWindow() string {
+	if x != nil {
+		return x.Window
+	}
+	return ""
+}
+
+func (x *TopClientsResponse) GetMetric() string {
+	if x != nil {
+		return x.Metric
+	}
+	return ""
+}
+
+var File_tracker_v1_tracker_proto protoreflect.FileDescriptor
+
+const file_tracker_v1_tracker_proto_rawDesc = "" +
+	"\n" +
+	"\x18tracker/v1/tracker.proto\x12\n" +
+	"tracker.v1\"\xd0\x04\n" +
+	"\bLogEntry\x12\x1a\n" +
+	"\bendpoint\x18\x01 \x01(\tR\bendpoint\x12\x19\n" +
+	"\bevent_id\x18\x02 \x01(\tR\aeventId\x12/\n" +
+	"\x11timestamp_unix_ms\x18\x03 \x01(\x03H\x00R\x0ftimestampUnixMs\x88\x01\x01\x12\x1d\n" +
+	"\n" +
+	"ip_address\x18\x04 \x01(\tR\tipAddress\x12\x16\n" +
+	"\x06method\x18\x05 \x01(\tR\x06method\x12$\n" +
+	"\vstatus_code\x18\x06 \x01(\rH\x01R\n" +
+	"statusCode\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"latency_ms\x18\a \x01(\rH\x02R\tlatencyMs\x88\x01\x01\x12(\n" +
+	"\rrequest_bytes\x18\b \x01(\x04H\x03R\frequestBytes\x88\x01\x01\x12*\n" +
+	"\x0eresponse_bytes\x18\t \x01(\x04H\x04R\rresponseBytes\x88\x01\x01\x12\x1d\n" +
+	"\n" +
+	"user_agent\x18\n" +
+	" \x01(\tR\tuserAgent\x128\n" +
+	"\x06labels\x18\v \x03(\v2 .tracker.v1.LogEntry.LabelsEntryR\x06labels\x12\x17\n" +
+	"\auser_id\x18\f \x01(\tR\x06userId\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\x14\n" +
+	"\x12_timestamp_unix_msB\x0e\n" +
+	"\f_status_codeB\r\n" +
+	"\v_latency_msB\x10\n" +
+	"\x0e_request_bytesB\x11\n" +
+	"\x0f_response_bytes\"H\n" +
+	"\x11RecordLogResponse\x12\x15\n" +
+	"\x06hit_id\x18\x01 \x01(\tR\x05hitId\x12\x1c\n" +
+	"\tduplicate\x18\x02 \x01(\bR\tduplicate\"9\n" +
+	"\vStreamError\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\rR\x05index\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"\xb9\x01\n" +
+	"\x12StreamLogsResponse\x12\x1a\n" +
+	"\breceived\x18\x01 \x01(\rR\breceived\x12\x1a\n" +
+	"\baccepted\x18\x02 \x01(\rR\baccepted\x12\x1e\n" +
+	"\n" +
+	"duplicates\x18\x03 \x01(\rR\n" +
+	"duplicates\x12\x1a\n" +
+	"\brejected\x18\x04 \x01(\rR\brejected\x12/\n" +
+	"\x06errors\x18\x05 \x03(\v2\x17.tracker.v1.StreamErrorR\x06errors\"\x88\x02\n" +
+	"\x14GetDailyUsageRequest\x12D\n" +
+	"\x06labels\x18\x01 \x03(\v2,.tracker.v1.GetDailyUsageRequest.LabelsEntryR\x06labels\x12\x19\n" +
+	"\bgroup_by\x18\x02 \x01(\tR\agroupBy\x12\x12\n" +
+	"\x04from\x18\x03 \x01(\tR\x04from\x12\x0e\n" +
+	"\x02to\x18\x04 \x01(\tR\x02to\x12\x0e\n" +
+	"\x02tz\x18\x05 \x01(\tR\x02tz\x12 \n" +
+	"\vgranularity\x18\x06 \x01(\tR\vgranularity\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"C\n" +
+	"\bDayUsage\x12\x12\n" +
+	"\x04date\x18\x01 \x01(\tR\x04date\x12#\n" +
+	"\rrequest_count\x18\x02 \x01(\x03R\frequestCount\"\x93\x03\n" +
+	"\x12DailyUsageResponse\x12\x1b\n" +
+	"\tclient_id\x18\x01 \x01(\tR\bclientId\x12\x1d\n" +
+	"\n" +
+	"start_date\x18\x02 \x01(\tR\tstartDate\x12\x19\n" +
+	"\bend_date\x18\x03 \x01(\tR\aendDate\x12*\n" +
+	"\x05usage\x18\x04 \x03(\v2\x14.tracker.v1.DayUsageR\x05usage\x12B\n" +
+	"\x06labels\x18\x05 \x03(\v2*.tracker.v1.DailyUsageResponse.LabelsEntryR\x06labels\x12\x19\n" +
+	"\bgroup_by\x18\x06 \x01(\tR\agroupBy\x12.\n" +
+	"\x06groups\x18\a \x03(\v2\x16.tracker.v1.LabelUsageR\x06groups\x12\x0e\n" +
+	"\x02tz\x18\b \x01(\tR\x02tz\x12 \n" +
+	"\vgranularity\x18\t \x01(\tR\vgranularity\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"N\n" +
+	"\n" +
+	"LabelUsage\x12\x14\n" +
+	"\x05value\x18\x01 \x01(\tR\x05value\x12*\n" +
+	"\x05usage\x18\x02 \x03(\v2\x14.tracker.v1.DayUsageR\x05usage\"\xdd\x01\n" +
+	"\x14GetTopClientsRequest\x12D\n" +
+	"\x06labels\x18\x01 \x03(\v2,.tracker.v1.GetTopClientsRequest.LabelsEntryR\x06labels\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06window\x18\x03 \x01(\tR\x06window\x12\x16\n" +
+	"\x06metric\x18\x04 \x01(\tR\x06metric\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"w\n" +
+	"\tTopClient\x12\x1b\n" +
+	"\tclient_id\x18\x01 \x01(\tR\bclientId\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12#\n" +
+	"\rrequest_count\x18\x03 \x01(\x03R\frequestCount\x12\x14\n" +
+	"\x05value\x18\x04 \x01(\x03R\x05value\"\xe4\x02\n" +
+	"\x12TopClientsResponse\x12\x16\n" +
+	"\x06period\x18\x01 \x01(\tR\x06period\x12*\n" +
+	"\x11generated_at_unix\x18\x02 \x01(\x03R\x0fgeneratedAtUnix\x126\n" +
+	"\vtop_clients\x18\x03 \x03(\v2\x15.tracker.v1.TopClientR\n" +
+	"topClients\x12#\n" +
+	"\rtotal_clients\x18\x04 \x01(\x05R\ftotalClients\x12B\n" +
+	"\x06labels\x18\x05 \x03(\v2*.tracker.v1.TopClientsResponse.LabelsEntryR\x06labels\x12\x16\n" +
+	"\x06window\x18\x06 \x01(\tR\x06window\x12\x16\n" +
+	"\x06metric\x18\a \x01(\tR\x06metric\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x012\xbe\x02\n" +
+	"\x0eTrackerService\x12@\n" +
+	"\tRecordLog\x12\x14.tracker.v1.LogEntry\x1a\x1d.tracker.v1.RecordLogResponse\x12D\n" +
+	"\n" +
+	"StreamLogs\x12\x14.tracker.v1.LogEntry\x1a\x1e.tracker.v1.StreamLogsResponse(\x01\x12Q\n" +
+	"\rGetDailyUsage\x12 .tracker.v1.GetDailyUsageRequest\x1a\x1e.tracker.v1.DailyUsageResponse\x12Q\n" +
+	"\rGetTopClients\x12 .tracker.v1.GetTopClientsRequest\x1a\x1e.tracker.v1.TopClientsResponseB6Z4user-activity-tracker/api/proto/tracker/v1;trackerv1b\x06proto3"
+
+var (
+	file_tracker_v1_tracker_proto_rawDescOnce sync.Once
+	file_tracker_v1_tracker_proto_rawDescData []byte
+)
+
+func file_tracker_v1_tracker_proto_rawDescGZIP() []byte {
+	file_tracker_v1_tracker_proto_rawDescOnce.Do(func() {
+		file_tracker_v1_tracker_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_tracker_v1_tracker_proto_rawDesc), len(file_tracker_v1_tracker_proto_rawDesc)))
+	})
+	return file_tracker_v1_tracker_proto_rawDescData
+}
+
+var file_tracker_v1_tracker_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
+var file_tracker_v1_tracker_proto_goTypes = []any{
+	(*LogEntry)(nil),             // 0: tracker.v1.LogEntry
+	(*RecordLogResponse)(nil),    // 1: tracker.v1.RecordLogResponse
+	(*StreamError)(nil),          // 2: tracker.v1.StreamError
+	(*StreamLogsResponse)(nil),   // 3: tracker.v1.StreamLogsResponse
+	(*GetDailyUsageRequest)(nil), // 4: tracker.v1.GetDailyUsageRequest
+	(*DayUsage)(nil),             // 5: tracker.v1.DayUsage
+	(*DailyUsageResponse)(nil),   // 6: tracker.v1.DailyUsageResponse
+	(*LabelUsage)(nil),           // 7: tracker.v1.LabelUsage
+	(*GetTopClientsRequest)(nil), // 8: tracker.v1.GetTopClientsRequest
+	(*TopClient)(nil),            // 9: tracker.v1.TopClient
+	(*TopClientsResponse)(nil),   // 10: tracker.v1.TopClientsResponse
+	nil,                          // 11: tracker.v1.LogEntry.LabelsEntry
+	nil,                          // 12: tracker.v1.GetDailyUsageRequest.LabelsEntry
+	nil,                          // 13: tracker.v1.DailyUsageResponse.LabelsEntry
+	nil,                          // 14: tracker.v1.GetTopClientsRequest.LabelsEntry
+	nil,                          // 15: tracker.v1.TopClientsResponse.LabelsEntry
+}
+var file_tracker_v1_tracker_proto_depIdxs = []int32{
+	11, // 0: tracker.v1.LogEntry.labels:type_name -> tracker.v1.LogEntry.LabelsEntry
+	2,  // 1: tracker.v1.StreamLogsResponse.errors:type_name -> tracker.v1.StreamError
+	12, // 2: tracker.v1.GetDailyUsageRequest.labels:type_name -> tracker.v1.GetDailyUsageRequest.LabelsEntry
+	5,  // 3: tracker.v1.DailyUsageResponse.usage:type_name -> tracker.v1.DayUsage
+	13, // 4: tracker.v1.DailyUsageResponse.labels:type_name -> tracker.v1.DailyUsageResponse.LabelsEntry
+	7,  // 5: tracker.v1.DailyUsageResponse.groups:type_name -> tracker.v1.LabelUsage
+	5,  // 6: tracker.v1.LabelUsage.usage:type_name -> tracker.v1.DayUsage
+	14, // 7: tracker.v1.GetTopClientsRequest.labels:type_name -> tracker.v1.GetTopClientsRequest.LabelsEntry
+	9,  // 8: tracker.v1.TopClientsResponse.top_clients:type_name -> tracker.v1.TopClient
+	15, // 9: tracker.v1.TopClientsResponse.labels:type_name -> tracker.v1.TopClientsResponse.LabelsEntry
+	0,  // 10: tracker.v1.TrackerService.RecordLog:input_type -> tracker.v1.LogEntry
+	0,  // 11: tracker.v1.TrackerService.StreamLogs:input_type -> tracker.v1.LogEntry
+	4,  // 12: tracker.v1.TrackerService.GetDailyUsage:input_type -> tracker.v1.GetDailyUsageRequest
+	8,  // 13: tracker.v1.TrackerService.GetTopClients:input_type -> tracker.v1.GetTopClientsRequest
+	1,  // 14: tracker.v1.TrackerService.RecordLog:output_type -> tracker.v1.RecordLogResponse
+	3,  // 15: tracker.v1.TrackerService.StreamLogs:output_type -> tracker.v1.StreamLogsResponse
+	6,  // 16: tracker.v1.TrackerService.GetDailyUsage:output_type -> tracker.v1.DailyUsageResponse
+	10, // 17: tracker.v1.TrackerService.GetTopClients:output_type -> tracker.v1.TopClientsResponse
+	14, // [14:18] is the sub-list for method output_type
+	10, // [10:14] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
+}
+
+func init() { file_tracker_v1_tracker_proto_init() }
+func file_tracker_v1_tracker_proto_init() {
+	if File_tracker_v1_tracker_proto != nil {
+		return
+	}
+	file_tracker_v1_tracker_proto_msgTypes[0].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_tracker_v1_tracker_proto_rawDesc), len(file_tracker_v1_tracker_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   16,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_tracker_v1_tracker_proto_goTypes,
+		DependencyIndexes: file_tracker_v1_tracker_proto_depIdxs,
+		MessageInfos:      file_tracker_v1_tracker_proto_msgTypes,
+	}.Build()
+	File_tracker_v1_tracker_proto = out.File
+	file_tracker_v1_tracker_proto_goTypes = nil
+	file_tracker_v1_tracker_proto_depIdxs = nil
+}
@@ -0,0 +1,249 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: tracker/v1/tracker.proto
+
+package trackerv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	TrackerService_RecordLog_FullMethodName     = "/tracker.v1.TrackerService/RecordLog"
+	TrackerService_StreamLogs_FullMethodName    = "/tracker.v1.TrackerService/StreamLogs"
+	TrackerService_GetDailyUsage_FullMethodName = "/tracker.v1.TrackerService/GetDailyUsage"
+	TrackerService_GetTopClients_FullMethodName = "/tracker.v1.TrackerService/GetTopClients"
+)
+
+// TrackerServiceClient is the client API for TrackerService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// TrackerService mirrors the HTTP ingestion and usage endpoints.
+//
+// Authenticate with either an "x-api-key" metadata entry or an
+// "authorization: Bearer <jwt>" entry. Calls count towards the same hourly
+// rate limit as the HTTP API.
+type TrackerServiceClient interface {
+	// RecordLog records a single API hit (POST /api/logs).
+	RecordLog(ctx context.Context, in *LogEntry, opts ...grpc.CallOption) (*RecordLogResponse, error)
+	// StreamLogs records every hit sent on the stream (POST /api/logs/stream).
+	StreamLogs(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[LogEntry, StreamLogsResponse], error)
+	// GetDailyUsage returns the caller's daily usage (GET /api/usage/daily).
+	GetDailyUsage(ctx context.Context, in *GetDailyUsageRequest, opts ...grpc.CallOption) (*DailyUsageResponse, error)
+	// GetTopClients returns the busiest clients (GET /api/usage/top).
+	GetTopClients(ctx context.Context, in *GetTopClientsRequest, opts ...grpc.CallOption) (*TopClientsResponse, error)
+}
+
+type trackerServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTrackerServiceClient(cc grpc.ClientConnInterface) TrackerServiceClient {
+	return &trackerServiceClient{cc}
+}
+
+func (c *trackerServiceClient) RecordLog(ctx context.Context, in *LogEntry, opts ...grpc.CallOption) (*RecordLogResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RecordLogResponse)
+	err := c.cc.Invoke(ctx, TrackerService_RecordLog_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trackerServiceClient) StreamLogs(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[LogEntry, StreamLogsResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TrackerService_ServiceDesc.Streams[0], TrackerService_StreamLogs_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[LogEntry, StreamLogsResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TrackerService_StreamLogsClient = grpc.ClientStreamingClient[LogEntry, StreamLogsResponse]
+
+func (c *trackerServiceClient) GetDailyUsage(ctx context.Context, in *GetDailyUsageRequest, opts ...grpc.CallOption) (*DailyUsageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DailyUsageResponse)
+	err := c.cc.Invoke(ctx, TrackerService_GetDailyUsage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trackerServiceClient) GetTopClients(ctx context.Context, in *GetTopClientsRequest, opts ...grpc.CallOption) (*TopClientsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TopClientsResponse)
+	err := c.cc.Invoke(ctx, TrackerService_GetTopClients_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TrackerServiceServer is the server API for TrackerService service.
+// All implementations must embed UnimplementedTrackerServiceServer
+// for forward compatibility.
+//
+// TrackerService mirrors the HTTP ingestion and usage endpoints.
+//
+// Authenticate with either an "x-api-key" metadata entry or an
+// "authorization: Bearer <jwt>" entry. Calls count towards the same hourly
+// rate limit as the HTTP API.
+type TrackerServiceServer interface {
+	// RecordLog records a single API hit (POST /api/logs).
+	RecordLog(context.Context, *LogEntry) (*RecordLogResponse, error)
+	// StreamLogs records every hit sent on the stream (POST /api/logs/stream).
+	StreamLogs(grpc.ClientStreamingServer[LogEntry, StreamLogsResponse]) error
+	// GetDailyUsage returns the caller's daily usage (GET /api/usage/daily).
+	GetDailyUsage(context.Context, *GetDailyUsageRequest) (*DailyUsageResponse, error)
+	// GetTopClients returns the busiest clients (GET /api/usage/top).
+	GetTopClients(context.Context, *GetTopClientsRequest) (*TopClientsResponse, error)
+	mustEmbedUnimplementedTrackerServiceServer()
+}
+
+// UnimplementedTrackerServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedTrackerServiceServer struct{}
+
+func (UnimplementedTrackerServiceServer) RecordLog(context.Context, *LogEntry) (*RecordLogResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method RecordLog not implemented")
+}
+func (UnimplementedTrackerServiceServer) StreamLogs(grpc.ClientStreamingServer[LogEntry, StreamLogsResponse]) error {
+	return status.Error(codes.Unimplemented, "method StreamLogs not implemented")
+}
+func (UnimplementedTrackerServiceServer) GetDailyUsage(context.Context, *GetDailyUsageRequest) (*DailyUsageResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetDailyUsage not implemented")
+}
+func (UnimplementedTrackerServiceServer) GetTopClients(context.Context, *GetTopClientsRequest) (*TopClientsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetTopClients not implemented")
+}
+func (UnimplementedTrackerServiceServer) mustEmbedUnimplementedTrackerServiceServer() {}
+func (UnimplementedTrackerServiceServer) testEmbeddedByValue()                        {}
+
+// UnsafeTrackerServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TrackerServiceServer will
+// result in compilation errors.
+type UnsafeTrackerServiceServer interface {
+	mustEmbedUnimplementedTrackerServiceServer()
+}
+
+func RegisterTrackerServiceServer(s grpc.ServiceRegistrar, srv TrackerServiceServer) {
+	// If the following call panics, it indicates UnimplementedTrackerServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&TrackerService_ServiceDesc, srv)
+}
+
+func _TrackerService_RecordLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LogEntry)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrackerServiceServer).RecordLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TrackerService_RecordLog_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrackerServiceServer).RecordLog(ctx, req.(*LogEntry))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrackerService_StreamLogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TrackerServiceServer).StreamLogs(&grpc.GenericServerStream[LogEntry, StreamLogsResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TrackerService_StreamLogsServer = grpc.ClientStreamingServer[LogEntry, StreamLogsResponse]
+
+func _TrackerService_GetDailyUsage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDailyUsageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrackerServiceServer).GetDailyUsage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TrackerService_GetDailyUsage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrackerServiceServer).GetDailyUsage(ctx, req.(*GetDailyUsageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrackerService_GetTopClients_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTopClientsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrackerServiceServer).GetTopClients(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TrackerService_GetTopClients_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrackerServiceServer).GetTopClients(ctx, req.(*GetTopClientsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// TrackerService_ServiceDesc is the grpc.ServiceDesc for TrackerService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TrackerService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "tracker.v1.TrackerService",
+	HandlerType: (*TrackerServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RecordLog",
+			Handler:    _TrackerService_RecordLog_Handler,
+		},
+		{
+			MethodName: "GetDailyUsage",
+			Handler:    _TrackerService_GetDailyUsage_Handler,
+		},
+		{
+			MethodName: "GetTopClients",
+			Handler:    _TrackerService_GetTopClients_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamLogs",
+			Handler:       _TrackerService_StreamLogs_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "tracker/v1/tracker.proto",
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"user-activity-tracker/internal/cache"
+	"user-activity-tracker/internal/database"
+	"user-activity-tracker/internal/models"
+	"user-activity-tracker/internal/services"
+)
+
+// options configure an import run
+type options struct {
+	File      string
+	Format    string
+	KeyColumn int
+	KeyField  string
+	KeyType   string
+	Client    string
+	BatchSize int
+	Progress  string
+	Restart   bool
+}
+
+// stats summarise an import, including earlier runs that were resumed
+type stats struct {
+	Lines         int64            `json:"lines"`
+	Imported      int64            `json:"imported"`
+	SampledOut    int64            `json:"sampled_out"`
+	Duplicates    int64            `json:"duplicates"`
+	Malformed     int64            `json:"malformed"`
+	Invalid       int64            `json:"invalid"`
+	UnknownClient int64            `json:"unknown_client"`
+	Clients       map[string]int64 `json:"clients"`
+	First         time.Time        `json:"first"`
+	Last          time.Time        `json:"last"`
+}
+
+// progress is saved after every batch so an interrupted import can resume
+type progress struct {
+	File   string              `json:"file"`
+	Offset int64               `json:"offset"`
+	Stats  stats               `json:"stats"`
+	Dates  map[string][]string `json:"dates"` // day -> clients whose daily_usage needs a rebuild
+}
+
+type importer struct {
+	opts   options
+	parser parser
+
+	db          *database.DBManager
+	authService *services.AuthService
+	stages      []services.IngestStage
+
+	clients map[string]string // key -> client ID, "" when unknown
+	state   progress
+}
+
+func newImporter(opts options) (*importer, error) {
+	p, err := newParser(opts.Format, opts.KeyColumn, opts.KeyField)
+	if err != nil {
+		return nil, err
+	}
+	if opts.KeyType != "api_key" && opts.KeyType != "client_id" {
+		return nil, fmt.Errorf("unknown key type %q, use api_key or client_id", opts.KeyType)
+	}
+
+	return &importer{
+		opts:        opts,
+		parser:      p,
+		db:          database.GetDBManager(),
+		authService: services.NewAuthService(),
+		// Same stages as live ingestion
+		stages:  services.NewIngestPipeline().Stages(),
+		clients: make(map[string]string),
+	}, nil
+}
+
+// run imports the file from the saved position, then rebuilds daily usage
+func (imp *importer) run() (*stats, error) {
+	if err := imp.loadProgress(); err != nil {
+		return nil, err
+	}
+	if imp.state.Offset > 0 {
+		log.Printf("Resuming %s at byte %d (%d lines already read)", imp.opts.File, imp.state.Offset, imp.state.Stats.Lines)
+	}
+
+	reader, closeFile, err := imp.open()
+	if err != nil {
+		return nil, err
+	}
+	defer closeFile()
+
+	offset := imp.state.Offset
+	batch := make([]models.APILogs, 0, imp.opts.BatchSize)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			offset += int64(len(line))
+			if hit, ok := imp.hit(line, offset); ok {
+				batch = append(batch, hit)
+			}
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+
+		done := errors.Is(err, io.EOF)
+		if len(batch) >= imp.opts.BatchSize || done {
+			if err := imp.flush(batch, offset); err != nil {
+				return nil, err
+			}
+			batch = batch[:0]
+		}
+		if done {
+			break
+		}
+	}
+
+	if err := imp.rebuildDailyUsage(); err != nil {
+		return nil, err
+	}
+
+	// Re-running a finished import only finds duplicates, so the progress file is no longer needed
+	if err := os.Remove(imp.opts.Progress); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Failed to remove progress file: %v", err)
+	}
+	return &imp.state.Stats, nil
+}
+
+// open returns a reader positioned at the saved offset. Offsets of gzip files
+// count decompressed bytes.
+func (imp *importer) open() (*bufio.Reader, func(), error) {
+	file, err := os.Open(imp.opts.File)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var r io.Reader = file
+	closeFile := func() { file.Close() }
+
+	if strings.HasSuffix(imp.opts.File, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		r = gz
+		if _, err := io.CopyN(io.Discard, gz, imp.state.Offset); err != nil {
+			file.Close()
+			return nil, nil, fmt.Errorf("skip to saved offset: %w", err)
+		}
+	} else if _, err := file.Seek(imp.state.Offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	return bufio.NewReaderSize(r, 256*1024), closeFile, nil
+}
+
+// hit turns a log line ending at offset into an API hit. ok is false when the
+// line is skipped; the reason is counted in the stats.
+func (imp *importer) hit(line []byte, offset int64) (models.APILogs, bool) {
+	s := &imp.state.Stats
+
+	line = []byte(strings.TrimSpace(string(line)))
+	if len(line) == 0 {
+		return models.APILogs{}, false
+	}
+	s.Lines++
+
+	rec, err := imp.parser.parse(line)
+	if err != nil {
+		s.Malformed++
+		if s.Malformed <= 10 {
+			log.Printf("Skipping malformed line ending at byte %d: %v", offset, err)
+		}
+		return models.APILogs{}, false
+	}
+	if rec.Endpoint == "" || len(rec.Endpoint) > 500 || len(rec.Method) > 10 ||
+		(rec.IPAddress != "" && net.ParseIP(rec.IPAddress) == nil) {
+		s.Invalid++
+		return models.APILogs{}, false
+	}
+
+	clientID := imp.client(rec.Key)
+	if clientID == "" {
+		s.UnknownClient++
+		return models.APILogs{}, false
+	}
+
+	hit := models.APILogs{
+		ClientID:      clientID,
+		EventID:       imp.eventID(line, offset),
+		Endpoint:      rec.Endpoint,
+		IPAddress:     rec.IPAddress,
+		Timestamp:     rec.Timestamp,
+		Method:        strings.ToUpper(rec.Method),
+		StatusCode:    rec.StatusCode,
+		LatencyMs:     rec.LatencyMs,
+		RequestBytes:  rec.RequestBytes,
+		ResponseBytes: rec.ResponseBytes,
+		UserAgent:     rec.UserAgent,
+		SampleWeight:  1,
+	}
+	if len(hit.UserAgent) > 512 {
+		hit.UserAgent = hit.UserAgent[:512]
+	}
+
+	for _, stage := range imp.stages {
+		if !stage(&hit) {
+			s.SampledOut++
+			imp.track(hit)
+			return models.APILogs{}, false
+		}
+	}
+	return hit, true
+}
+
+// client resolves a line's key to a registered client, falling back to the
+// default client for lines without a key
+func (imp *importer) client(key string) string {
+	if key == "" {
+		return imp.opts.Client
+	}
+
+	clientID, ok := imp.clients[key]
+	if ok {
+		return clientID
+	}
+
+	var client *models.Client
+	var err error
+	if imp.opts.KeyType == "api_key" {
+		client, err = imp.authService.ValidateAPIKey(key)
+	} else {
+		client, err = imp.authService.GetClient(key)
+	}
+	if err == nil {
+		clientID = client.ClientID
+	}
+	imp.clients[key] = clientID
+	return clientID
+}
+
+// eventID derives a stable event ID from the absolute file path, the line
+// position and the line itself, so re-imported lines are recognised as
+// duplicates while a rotated file reusing the same name is not
+func (imp *importer) eventID(line []byte, offset int64) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%d\x00", imp.state.File, offset)
+	h.Write(line)
+	return "import-" + hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// flush stores a batch and saves the position after it
+func (imp *importer) flush(batch []models.APILogs, offset int64) error {
+	hits, err := imp.withoutImported(batch)
+	if err != nil {
+		return err
+	}
+	imp.state.Stats.Duplicates += int64(len(batch) - len(hits))
+
+	if len(hits) > 0 {
+		if err := imp.db.BatchInsertHits(hits); err != nil {
+			return fmt.Errorf("insert batch: %w", err)
+		}
+	}
+	for _, hit := range hits {
+		imp.state.Stats.Imported++
+		imp.track(hit)
+	}
+
+	imp.state.Offset = offset
+	return imp.saveProgress()
+}
+
+// withoutImported drops hits stored by an earlier run of the same import
+func (imp *importer) withoutImported(batch []models.APILogs) ([]models.APILogs, error) {
+	if len(batch) == 0 {
+		return batch, nil
+	}
+
+	keys := make([][]interface{}, len(batch))
+	for i, hit := range batch {
+		keys[i] = []interface{}{hit.ClientID, hit.EventID}
+	}
+
+	var existing []models.APILogs
+	err := imp.db.WriteDB.Select("client_id", "event_id").
+		Where("(client_id, event_id) IN ?", keys).
+		Find(&existing).Error
+	if err != nil {
+		return nil, fmt.Errorf("check for imported lines: %w", err)
+	}
+	if len(existing) == 0 {
+		return batch, nil
+	}
+
+	stored := make(map[string]struct{}, len(existing))
+	for _, hit := range existing {
+		stored[hit.ClientID+"\x00"+hit.EventID] = struct{}{}
+	}
+	hits := make([]models.APILogs, 0, len(batch))
+	for _, hit := range batch {
+		if _, ok := stored[hit.ClientID+"\x00"+hit.EventID]; !ok {
+			hits = append(hits, hit)
+		}
+	}
+	return hits, nil
+}
+
+// track records the client, time range and day of a stored or sampled hit
+func (imp *importer) track(hit models.APILogs) {
+	s := &imp.state.Stats
+	if s.Clients == nil {
+		s.Clients = make(map[string]int64)
+	}
+	s.Clients[hit.ClientID]++
+	if s.First.IsZero() || hit.Timestamp.Before(s.First) {
+		s.First = hit.Timestamp
+	}
+	if hit.Timestamp.After(s.Last) {
+		s.Last = hit.Timestamp
+	}
+
+	if imp.state.Dates == nil {
+		imp.state.Dates = make(map[string][]string)
+	}
+	day := hit.Timestamp.In(time.Local).Format("2006-01-02")
+	for _, clientID := range imp.state.Dates[day] {
+		if clientID == hit.ClientID {
+			return
+		}
+	}
+	imp.state.Dates[day] = append(imp.state.Dates[day], hit.ClientID)
+}
+
+// rebuildDailyUsage recomputes daily_usage for every past day touched by the
+// import. Today is left to the regular aggregation, as with backfills.
+func (imp *importer) rebuildDailyUsage() error {
+	days := make([]string, 0, len(imp.state.Dates))
+	for day := range imp.state.Dates {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+
+	today := time.Now().Format("2006-01-02")
+	cacheMgr := cache.GetCacheManager()
+	for _, day := range days {
+		if day >= today {
+			continue
+		}
+		date, _ := time.ParseInLocation("2006-01-02", day, time.Local)
+		clientIDs := imp.state.Dates[day]
+		if err := imp.db.RebuildDailyUsage(date, clientIDs...); err != nil {
+			return fmt.Errorf("rebuild daily usage for %s: %w", day, err)
+		}
+		log.Printf("Rebuilt daily usage for %s (%d clients)", day, len(clientIDs))
+
+		// Saved so a resumed run does not rebuild the day again
+		delete(imp.state.Dates, day)
+		if err := imp.saveProgress(); err != nil {
+			return err
+		}
+		for _, clientID := range clientIDs {
+			cacheMgr.PublishUpdate(clientID)
+		}
+	}
+	return nil
+}
+
+func (imp *importer) loadProgress() error {
+	abs, err := filepath.Abs(imp.opts.File)
+	if err != nil {
+		return err
+	}
+	imp.state = progress{File: abs}
+	if imp.opts.Restart {
+		return nil
+	}
+
+	data, err := os.ReadFile(imp.opts.Progress)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var saved progress
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("read progress file: %w", err)
+	}
+	if saved.File != abs {
+		return fmt.Errorf("progress file %s belongs to %s, use -restart or another -progress path", imp.opts.Progress, saved.File)
+	}
+	imp.state = saved
+	return nil
+}
+
+// saveProgress atomically replaces the progress file
+func (imp *importer) saveProgress() error {
+	data, err := json.Marshal(imp.state)
+	if err != nil {
+		return err
+	}
+	tmp := imp.opts.Progress + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, imp.opts.Progress)
+}
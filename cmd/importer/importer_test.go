@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestEventID(t *testing.T) {
+	imp := &importer{state: progress{File: "/var/log/nginx/access.log"}}
+	line := []byte(`203.0.113.7 - - [01/May/2024:12:00:00 +0000] "GET /a HTTP/1.1" 200 12 "-" "curl"`)
+	id := imp.eventID(line, 100)
+
+	if again := imp.eventID(line, 100); again != id {
+		t.Errorf("eventID is not stable: %s, then %s", id, again)
+	}
+
+	rotated := &importer{state: progress{File: "/var/log/nginx/access.log"}}
+	other := []byte(`198.51.100.2 - - [02/May/2024:08:00:00 +0000] "GET /b HTTP/1.1" 200 12 "-" "curl"`)
+	elsewhere := &importer{state: progress{File: "/srv/old/access.log"}}
+
+	for name, other := range map[string]string{
+		"another line at the same position":  rotated.eventID(other, 100),
+		"the same line at another position":  imp.eventID(line, 200),
+		"the same line in another directory": elsewhere.eventID(line, 100),
+	} {
+		if other == id {
+			t.Errorf("%s has the same event ID %s", name, id)
+		}
+	}
+}
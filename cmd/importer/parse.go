@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const combinedTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// record is one access log line in a format-independent shape
+type record struct {
+	Key           string
+	Endpoint      string
+	Method        string
+	IPAddress     string
+	Timestamp     time.Time
+	StatusCode    *uint16
+	LatencyMs     *uint32
+	RequestBytes  *uint64
+	ResponseBytes *uint64
+	UserAgent     string
+}
+
+type parser interface {
+	parse(line []byte) (record, error)
+}
+
+func newParser(format string, keyColumn int, keyField string) (parser, error) {
+	switch format {
+	case "combined":
+		return &combinedParser{keyColumn: keyColumn}, nil
+	case "json":
+		var path []string
+		if keyField != "" {
+			path = strings.Split(keyField, ".")
+		}
+		return &jsonParser{keyPath: path}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q, use combined or json", format)
+}
+
+// combinedParser reads the nginx/Apache combined format:
+//
+//	$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent"
+//
+// Extra columns appended by a custom log_format, such as an API key header,
+// can be selected with keyColumn (1-based, bracketed and quoted values count as one column).
+type combinedParser struct {
+	keyColumn int
+}
+
+func (p *combinedParser) parse(line []byte) (record, error) {
+	var rec record
+
+	cols, err := splitColumns(string(line))
+	if err != nil {
+		return rec, err
+	}
+	if len(cols) < 7 {
+		return rec, errors.New("too few columns for combined format")
+	}
+
+	rec.IPAddress = cols[0]
+	if rec.Timestamp, err = time.Parse(combinedTimeLayout, cols[3]); err != nil {
+		return rec, fmt.Errorf("invalid time %q", cols[3])
+	}
+
+	// "GET /path HTTP/1.1"
+	request := strings.Fields(cols[4])
+	if len(request) < 2 {
+		return rec, fmt.Errorf("invalid request %q", cols[4])
+	}
+	rec.Method, rec.Endpoint = request[0], request[1]
+
+	if rec.StatusCode, err = parseStatus(cols[5]); err != nil {
+		return rec, err
+	}
+	if cols[6] != "-" {
+		if n, err := strconv.ParseUint(cols[6], 10, 64); err == nil {
+			rec.ResponseBytes = &n
+		}
+	}
+	if len(cols) > 8 && cols[8] != "-" {
+		rec.UserAgent = cols[8]
+	}
+	if p.keyColumn > 0 && p.keyColumn <= len(cols) && cols[p.keyColumn-1] != "-" {
+		rec.Key = cols[p.keyColumn-1]
+	}
+	return rec, nil
+}
+
+// splitColumns splits a log line on spaces, keeping [bracketed] and "quoted"
+// values (with backslash escapes) together
+func splitColumns(line string) ([]string, error) {
+	var cols []string
+	for i := 0; i < len(line); {
+		switch line[i] {
+		case ' ', '\t':
+			i++
+
+		case '[':
+			end := strings.IndexByte(line[i:], ']')
+			if end < 0 {
+				return nil, errors.New("unterminated [ column")
+			}
+			cols = append(cols, line[i+1:i+end])
+			i += end + 1
+
+		case '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(line) && line[j] != '"'; j++ {
+				if line[j] == '\\' && j+1 < len(line) {
+					j++
+				}
+				b.WriteByte(line[j])
+			}
+			if j >= len(line) {
+				return nil, errors.New("unterminated quoted column")
+			}
+			cols = append(cols, b.String())
+			i = j + 1
+
+		default:
+			end := strings.IndexAny(line[i:], " \t")
+			if end < 0 {
+				end = len(line) - i
+			}
+			cols = append(cols, line[i:i+end])
+			i += end
+		}
+	}
+	return cols, nil
+}
+
+// jsonParser reads one JSON object per line. It understands the tracker's own
+// field names as well as the usual nginx log_format escape=json variable names.
+type jsonParser struct {
+	keyPath []string
+}
+
+func (p *jsonParser) parse(line []byte) (record, error) {
+	var rec record
+
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil {
+		return rec, errors.New("invalid JSON")
+	}
+
+	rec.Endpoint = stringField(obj, "endpoint", "request_uri", "uri", "path", "url")
+	rec.Method = stringField(obj, "method", "request_method")
+	if request := strings.Fields(stringField(obj, "request")); len(request) >= 2 {
+		if rec.Endpoint == "" {
+			rec.Endpoint = request[1]
+		}
+		if rec.Method == "" {
+			rec.Method = request[0]
+		}
+	}
+
+	rec.IPAddress = stringField(obj, "ip_address", "remote_addr", "client_ip", "ip")
+	rec.UserAgent = stringField(obj, "user_agent", "http_user_agent")
+
+	var err error
+	ts := stringField(obj, "timestamp", "time", "time_iso8601", "time_local", "@timestamp", "msec")
+	if rec.Timestamp, err = parseTime(ts); err != nil {
+		return rec, err
+	}
+
+	if status := stringField(obj, "status_code", "status"); status != "" {
+		if rec.StatusCode, err = parseStatus(status); err != nil {
+			return rec, err
+		}
+	}
+
+	if v, err := strconv.ParseFloat(stringField(obj, "latency_ms", "duration_ms"), 64); err == nil {
+		rec.LatencyMs = millis(v)
+	} else if v, err := strconv.ParseFloat(stringField(obj, "request_time"), 64); err == nil {
+		// nginx reports $request_time in seconds
+		rec.LatencyMs = millis(v * 1000)
+	}
+	if v, err := strconv.ParseUint(stringField(obj, "request_bytes", "request_length"), 10, 64); err == nil {
+		rec.RequestBytes = &v
+	}
+	if v, err := strconv.ParseUint(stringField(obj, "response_bytes", "body_bytes_sent", "bytes_sent"), 10, 64); err == nil {
+		rec.ResponseBytes = &v
+	}
+
+	if len(p.keyPath) > 0 {
+		rec.Key = pathField(obj, p.keyPath)
+	}
+	return rec, nil
+}
+
+// stringField returns the first of the keys present in obj, rendered as a string
+func stringField(obj map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if s := scalarString(obj[key]); s != "" && s != "-" {
+			return s
+		}
+	}
+	return ""
+}
+
+// pathField follows a dotted path such as headers.x-api-key into nested objects
+func pathField(obj map[string]interface{}, path []string) string {
+	var v interface{} = obj
+	for _, key := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		v = m[key]
+	}
+	if s := scalarString(v); s != "-" {
+		return s
+	}
+	return ""
+}
+
+func scalarString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	return ""
+}
+
+// parseTime accepts RFC 3339, the combined log time format, and Unix time in
+// seconds (optionally fractional, like nginx $msec) or milliseconds
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, errors.New("missing time")
+	}
+	for _, layout := range []string{time.RFC3339Nano, combinedTimeLayout} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.ParseInLocation(time.DateTime, s, time.Local); err == nil {
+		return t, nil
+	}
+	if v, err := strconv.ParseFloat(s, 64); err == nil && v > 0 {
+		if v >= 1e11 {
+			return time.UnixMilli(int64(v)), nil
+		}
+		return time.UnixMilli(int64(math.Round(v * 1000))), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+func parseStatus(s string) (*uint16, error) {
+	v, err := strconv.ParseUint(s, 10, 16)
+	if err != nil || v < 100 || v > 599 {
+		return nil, fmt.Errorf("invalid status %q", s)
+	}
+	code := uint16(v)
+	return &code, nil
+}
+
+func millis(v float64) *uint32 {
+	if v < 0 {
+		return nil
+	}
+	ms := uint32(min(math.Round(v), math.MaxUint32))
+	return &ms
+}
@@ -1,9 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
+	_ "time/tzdata" // usage time zones on images without zoneinfo
 
 	"user-activity-tracker/configs"
 	"user-activity-tracker/internal/cache"
@@ -14,6 +21,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"google.golang.org/grpc"
 )
 
 // @title User Activity Tracker API
@@ -59,10 +67,33 @@ func main() {
 
 	// Initialize services
 	authService := services.NewAuthService()
+	ingestService := services.NewIngestService()
+	pipeline := services.NewIngestPipeline()
+	for _, stage := range pipeline.Stages() {
+		ingestService.AddStage(stage)
+	}
+	redactionService := pipeline.Redaction
+	normalizer := pipeline.Normalizer
+	samplingService := pipeline.Sampling
+	userService := pipeline.UserIdentity
+	geoService := pipeline.GeoIP
+	geoService.Start()
+	ipPrivacyService := pipeline.IPPrivacy
+	ipPrivacyService.Start()
+	ingestService.Start()
+	sessionService := services.NewSessionService()
+	sessionService.Start()
+	leaderboardService := services.NewLeaderboardService()
+	leaderboardService.Start()
 
 	// Initialize handlers
 	wsHandler := handlers.NewWebSocketHandler()
-	clientHandler := handlers.NewClientHandler(authService, wsHandler)
+	clientHandler := handlers.NewClientHandler(authService, ingestService, leaderboardService, ipPrivacyService, wsHandler)
+	routeHandler := handlers.NewRouteHandler(normalizer)
+	samplingHandler := handlers.NewSamplingHandler(samplingService)
+	userHandler := handlers.NewUserHandler(userService, ipPrivacyService)
+	redactionHandler := handlers.NewRedactionHandler(redactionService)
+	privacyHandler := handlers.NewPrivacyHandler(ipPrivacyService)
 
 	// Setup Gin router
 	if os.Getenv("GIN_MODE") != "debug" {
@@ -72,14 +103,17 @@ func main() {
 	router := gin.Default()
 
 	// Global middleware
-	router.Use(middleware.ValidationMiddleware())
+	router.Use(middleware.ValidationMiddleware(map[string][]string{
+		"/api/logs/stream": {"application/x-ndjson"},
+		"/otlp/v1/traces":  {"application/x-protobuf"},
+	}))
 	router.Use(gin.Recovery())
 
 	// CORS middleware
 	router.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-API-Key")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-API-Key, Idempotency-Key")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
@@ -99,8 +133,42 @@ func main() {
 	protected.Use(middleware.RateLimitMiddleware(cacheMgr))
 
 	protected.POST("/logs", clientHandler.RecordLog)
+	protected.POST("/logs/batch", clientHandler.RecordLogBatch)
+	protected.POST("/logs/stream", clientHandler.RecordLogStream)
+	protected.POST("/logs/backfill", middleware.BackfillPermissionMiddleware(authService), clientHandler.RecordLogBackfill)
 	protected.GET("/usage/daily", clientHandler.GetDailyUsage)
 	protected.GET("/usage/top", clientHandler.GetTopClients)
+	protected.GET("/usage/top/endpoints", clientHandler.GetTopEndpoints)
+	protected.GET("/usage/top/ips", clientHandler.GetTopIPs)
+	protected.GET("/usage/geo", clientHandler.GetGeoUsage)
+	protected.GET("/usage/platforms", clientHandler.GetPlatformUsage)
+	protected.GET("/usage/endpoints", clientHandler.GetEndpointUsage)
+	protected.GET("/sessions/daily", clientHandler.GetSessionDaily)
+	protected.GET("/sessions/lengths", clientHandler.GetSessionLengths)
+	protected.GET("/route-templates", routeHandler.ListRouteTemplates)
+	protected.POST("/route-templates", routeHandler.CreateRouteTemplate)
+	protected.DELETE("/route-templates/:id", routeHandler.DeleteRouteTemplate)
+	protected.GET("/sampling-policies", samplingHandler.ListSamplingPolicies)
+	protected.PUT("/sampling-policies", samplingHandler.SetSamplingPolicy)
+	protected.DELETE("/sampling-policies/:id", samplingHandler.DeleteSamplingPolicy)
+	protected.GET("/redaction-rules", redactionHandler.GetRedactionRules)
+	protected.PUT("/redaction-rules", redactionHandler.SetRedactionRules)
+	protected.DELETE("/redaction-rules", redactionHandler.DeleteRedactionRules)
+	protected.POST("/redaction-rules/test", redactionHandler.TestRedaction)
+	protected.GET("/settings/user-ids", userHandler.GetUserIDSettings)
+	protected.PUT("/settings/user-ids", userHandler.SetUserIDSettings)
+	protected.GET("/settings/ip-privacy", privacyHandler.GetIPPrivacy)
+	protected.PUT("/settings/ip-privacy", privacyHandler.SetIPPrivacy)
+	protected.GET("/users/top", userHandler.GetTopUsers)
+	protected.GET("/users/active", userHandler.GetActiveUsers)
+	protected.GET("/users/timeline", userHandler.GetUserTimeline)
+
+	// OTLP/HTTP receiver; exporters authenticate with the shared OTLP token instead of client credentials
+	if configs.AppConfig.EnableOTLP {
+		otlpHandler := handlers.NewOTLPHandler(authService, clientHandler)
+		router.POST("/otlp/v1/traces", otlpHandler.ExportTraces)
+		log.Println("OTLP/HTTP trace receiver enabled")
+	}
 
 	// WebSocket route
 	if configs.AppConfig.EnableWebSocket {
@@ -108,10 +176,29 @@ func main() {
 		router.GET("/ws", wsHandler.HandleConnections)
 		log.Println("WebSocket server enabled")
 	}
+	// Redis stream consumer ingests hits pushed by producers that cannot call the API
+	var streamConsumer *handlers.RedisStreamConsumer
+	if configs.AppConfig.EnableRedisStream {
+		if cacheMgr.IsAvailable() {
+			streamConsumer = handlers.NewRedisStreamConsumer(authService, clientHandler, cacheMgr.Client())
+			if err := streamConsumer.Start(); err != nil {
+				log.Printf("Failed to start Redis stream consumer: %v", err)
+				streamConsumer = nil
+			}
+		} else {
+			log.Println("Redis stream consumer disabled: Redis is not available")
+		}
+	}
+
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
+		// Degraded while accepted hits wait for a retry or were recently dropped
+		status := "healthy"
+		if ingestService.Degraded() {
+			status = "degraded"
+		}
 		c.JSON(200, gin.H{
-			"status":    "healthy",
+			"status":    status,
 			"timestamp": time.Now().Unix(),
 			"services": map[string]string{
 				"database": "connected",
@@ -124,15 +211,98 @@ func main() {
 				}(),
 				"cache": "active",
 			},
+			"ingestion": gin.H{
+				"queue_depth":    ingestService.QueueDepth(),
+				"queue_capacity": ingestService.QueueCapacity(),
+				"retry_pending":  ingestService.RetryBacklog(),
+				"dropped_hits":   ingestService.DroppedHits(),
+			},
+			"spool": func() gin.H {
+				pending, size := ingestService.SpoolBacklog()
+				return gin.H{
+					"enabled":       ingestService.SpoolEnabled(),
+					"pending_hits":  pending,
+					"pending_bytes": size,
+				}
+			}(),
+			"redis_stream": func() gin.H {
+				if streamConsumer == nil {
+					return gin.H{"enabled": false}
+				}
+				processed, rejected := streamConsumer.Stats()
+				return gin.H{
+					"enabled":   true,
+					"processed": processed,
+					"rejected":  rejected,
+				}
+			}(),
 		})
 	})
 
 	// Start server
 	port := ":" + configs.AppConfig.ServerPort
-	log.Printf("Server starting on port %s", port)
-	log.Printf("Swagger docs available at http://localhost%s/swagger/index.html", port)
+	server := &http.Server{
+		Addr:    port,
+		Handler: router,
+	}
+
+	// gRPC server runs next to the Gin router on its own port
+	var grpcServer *grpc.Server
+	if configs.AppConfig.EnableGRPC {
+		listener, err := net.Listen("tcp", ":"+configs.AppConfig.GRPCPort)
+		if err != nil {
+			log.Fatal("Failed to start gRPC server:", err)
+		}
+		grpcServer = handlers.NewGRPCHandler(authService, clientHandler).Server()
 
-	if err := router.Run(port); err != nil {
-		log.Fatal("Failed to start server:", err)
+		go func() {
+			log.Printf("gRPC server starting on port %s", configs.AppConfig.GRPCPort)
+			if err := grpcServer.Serve(listener); err != nil {
+				log.Fatal("Failed to start gRPC server:", err)
+			}
+		}()
+	}
+
+	go func() {
+		log.Printf("Server starting on port %s", port)
+		log.Printf("Swagger docs available at http://localhost%s/swagger/index.html", port)
+
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Failed to start server:", err)
+		}
+	}()
+
+	// Wait for shutdown signal, then drain in-flight requests and queued hits
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down server...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Server forced to shutdown: %v", err)
+	}
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			log.Println("gRPC server forced to shutdown")
+			grpcServer.Stop()
+		}
+	}
+	if streamConsumer != nil {
+		streamConsumer.Stop()
 	}
+	ingestService.Stop()
+	sessionService.Stop()
+	leaderboardService.Stop()
+	ipPrivacyService.Stop()
+	geoService.Stop()
 }
@@ -1,15 +1,21 @@
 package configs
 
 import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"errors"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
+	// Environment is "production" or anything else for development
+	Environment       string
 	ServerPort        string
 	DatabaseURL       string
 	RedisURL          string
@@ -20,6 +26,85 @@ type Config struct {
 	ShardCount        int
 	EnableWebSocket   bool
 	EnableIPWhitelist bool
+	EnableGRPC        bool
+	GRPCPort          string
+
+	// Ingestion pipeline
+	IngestQueueSize     int
+	IngestWorkers       int
+	IngestBatchSize     int
+	IngestFlushInterval time.Duration
+	IngestRetryMaxHits  int
+	LogBatchMaxSize     int
+	IdempotencyTTL      time.Duration
+	MaxPastSkew         time.Duration
+	MaxFutureSkew       time.Duration
+	BackfillMaxAge      time.Duration
+	StreamMaxLineBytes  int
+	StreamEnqueueWait   time.Duration
+
+	// Disk spool used while the write DB is unavailable
+	SpoolDir            string
+	SpoolSegmentBytes   int
+	SpoolMaxBytes       int
+	SpoolReplayInterval time.Duration
+
+	// Redis Streams ingestion
+	EnableRedisStream        bool
+	RedisStreamKey           string
+	RedisStreamGroup         string
+	RedisStreamConsumer      string
+	RedisStreamReadCount     int
+	RedisStreamBlock         time.Duration
+	RedisStreamClaimMinIdle  time.Duration
+	RedisStreamClaimInterval time.Duration
+	// Clients whose entries may carry client_id instead of api_key
+	RedisStreamTrustedClients []string
+
+	// OTLP/HTTP trace ingestion
+	EnableOTLP          bool
+	OTLPClientAttribute string
+	OTLPAuthToken       string
+	OTLPMaxBodyBytes    int
+
+	// GeoIP enrichment
+	GeoIPDBPath         string
+	GeoIPASNDBPath      string
+	GeoIPReloadInterval time.Duration
+
+	// Query parameters removed from every endpoint unless a client allow-lists parameters
+	RedactQueryParams []string
+
+	// IP address privacy; hashed addresses are HMAC-SHA256 keyed with IPHashKey.
+	// Addresses older than IPAnonymizeAfter (0 disables) are anonymized with the
+	// client's mode, or IPAnonymizeMode for clients storing full addresses.
+	IPHashKey           string
+	IPAnonymizeAfter    time.Duration
+	IPAnonymizeMode     string
+	IPAnonymizeInterval time.Duration
+
+	// End-user IDs; hashed IDs are HMAC-SHA256 keyed with UserIDHashKey
+	UserIDHashKey string
+
+	// Sessionization of hits by end user or IP address
+	EnableSessions   bool
+	SessionGap       time.Duration
+	SessionInterval  time.Duration
+	SessionBatchSize int
+
+	// Refresh the 1h/24h/7d/30d client leaderboards in the background
+	PrecomputeLeaderboards bool
+
+	// Count request leaderboards of the last 24 hours per minute in Redis, and
+	// check them against MySQL every interval (0 disables the check)
+	RealtimeLeaderboards      bool
+	LeaderboardReconcileEvery time.Duration
+	LeaderboardDriftPercent   int
+
+	// Custom labels
+	LabelMaxPerHit        int
+	LabelMaxKeysPerClient int
+	LabelMaxValuesPerKey  int
 }
 
 var AppConfig *Config
@@ -29,6 +114,7 @@ func LoadConfig() error {
 	godotenv.Load()
 
 	AppConfig = &Config{
+		Environment:       getEnv("APP_ENV", "development"),
 		ServerPort:        getEnv("SERVER_PORT", "8080"),
 		DatabaseURL:       getEnv("DATABASE_URL", "root:password@tcp(localhost:3306)/activity_tracker?charset=utf8mb4&parseTime=True&loc=Local"),
 		RedisURL:          getEnv("REDIS_URL", "localhost:6379"),
@@ -39,11 +125,120 @@ func LoadConfig() error {
 		ShardCount:        parseInt(getEnv("SHARD_COUNT", "4")),
 		EnableWebSocket:   parseBool(getEnv("ENABLE_WEBSOCKET", "true")),
 		EnableIPWhitelist: parseBool(getEnv("ENABLE_IP_WHITELIST", "false")),
+		EnableGRPC:        parseBool(getEnv("ENABLE_GRPC", "true")),
+		GRPCPort:          getEnv("GRPC_PORT", "9090"),
+
+		IngestQueueSize:     parseInt(getEnv("INGEST_QUEUE_SIZE", "10000")),
+		IngestWorkers:       parseInt(getEnv("INGEST_WORKERS", "4")),
+		IngestBatchSize:     parseInt(getEnv("INGEST_BATCH_SIZE", "500")),
+		IngestFlushInterval: parseDuration(getEnv("INGEST_FLUSH_INTERVAL", "1s")),
+		IngestRetryMaxHits:  parseInt(getEnv("INGEST_RETRY_MAX_HITS", "100000")),
+		LogBatchMaxSize:     parseInt(getEnv("LOG_BATCH_MAX_SIZE", "1000")),
+		IdempotencyTTL:      parseDuration(getEnv("IDEMPOTENCY_TTL", "24h")),
+		MaxPastSkew:         parseDuration(getEnv("MAX_PAST_SKEW", "1h")),
+		MaxFutureSkew:       parseDuration(getEnv("MAX_FUTURE_SKEW", "5m")),
+		BackfillMaxAge:      parseDuration(getEnv("BACKFILL_MAX_AGE", "8760h")),
+		StreamMaxLineBytes:  parseInt(getEnv("STREAM_MAX_LINE_BYTES", "65536")),
+		StreamEnqueueWait:   parseDuration(getEnv("STREAM_ENQUEUE_WAIT", "5s")),
+
+		SpoolDir:            getEnv("SPOOL_DIR", "./data/spool"),
+		SpoolSegmentBytes:   parseInt(getEnv("SPOOL_SEGMENT_BYTES", "67108864")),
+		SpoolMaxBytes:       parseInt(getEnv("SPOOL_MAX_BYTES", "1073741824")),
+		SpoolReplayInterval: parseDuration(getEnv("SPOOL_REPLAY_INTERVAL", "5s")),
+
+		EnableRedisStream:         parseBool(getEnv("ENABLE_REDIS_STREAM", "false")),
+		RedisStreamKey:            getEnv("REDIS_STREAM_KEY", "tracker:hits"),
+		RedisStreamGroup:          getEnv("REDIS_STREAM_GROUP", "tracker-ingest"),
+		RedisStreamConsumer:       getEnv("REDIS_STREAM_CONSUMER", defaultConsumerName()),
+		RedisStreamReadCount:      parseInt(getEnv("REDIS_STREAM_READ_COUNT", "500")),
+		RedisStreamBlock:          parseDuration(getEnv("REDIS_STREAM_BLOCK", "2s")),
+		RedisStreamClaimMinIdle:   parseDuration(getEnv("REDIS_STREAM_CLAIM_MIN_IDLE", "1m")),
+		RedisStreamClaimInterval:  parseDuration(getEnv("REDIS_STREAM_CLAIM_INTERVAL", "30s")),
+		RedisStreamTrustedClients: parseList(getEnv("REDIS_STREAM_TRUSTED_CLIENTS", "")),
+
+		EnableOTLP:          parseBool(getEnv("ENABLE_OTLP", "false")),
+		OTLPClientAttribute: getEnv("OTLP_CLIENT_ATTRIBUTE", "tracker.client_id"),
+		OTLPAuthToken:       getEnv("OTLP_AUTH_TOKEN", ""),
+		OTLPMaxBodyBytes:    parseInt(getEnv("OTLP_MAX_BODY_BYTES", "4194304")),
+
+		GeoIPDBPath:         getEnv("GEOIP_DB_PATH", ""),
+		GeoIPASNDBPath:      getEnv("GEOIP_ASN_DB_PATH", ""),
+		GeoIPReloadInterval: parseDuration(getEnv("GEOIP_RELOAD_INTERVAL", "1m")),
+
+		RedactQueryParams: parseList(getEnv("REDACT_QUERY_PARAMS",
+			"access_token,api_key,apikey,auth,authorization,client_secret,code,key,password,passwd,pwd,refresh_token,secret,session,sessionid,sig,signature,token")),
+
+		IPHashKey:           getEnv("IP_HASH_KEY", ""),
+		IPAnonymizeAfter:    parseDuration(getEnv("IP_ANONYMIZE_AFTER", "0")),
+		IPAnonymizeMode:     getEnv("IP_ANONYMIZE_MODE", "truncated"),
+		IPAnonymizeInterval: parseDuration(getEnv("IP_ANONYMIZE_INTERVAL", "1h")),
+
+		UserIDHashKey: getEnv("USER_ID_HASH_KEY", ""),
+
+		EnableSessions:   parseBool(getEnv("ENABLE_SESSIONS", "true")),
+		SessionGap:       parseDuration(getEnv("SESSION_GAP", "30m")),
+		SessionInterval:  parseDuration(getEnv("SESSION_INTERVAL", "1m")),
+		SessionBatchSize: parseInt(getEnv("SESSION_BATCH_SIZE", "5000")),
+
+		PrecomputeLeaderboards: parseBool(getEnv("PRECOMPUTE_LEADERBOARDS", "true")),
+
+		RealtimeLeaderboards:      parseBool(getEnv("REALTIME_LEADERBOARDS", "true")),
+		LeaderboardReconcileEvery: parseDuration(getEnv("LEADERBOARD_RECONCILE_INTERVAL", "10m")),
+		LeaderboardDriftPercent:   parseInt(getEnv("LEADERBOARD_DRIFT_PERCENT", "5")),
+
+		LabelMaxPerHit:        parseInt(getEnv("LABEL_MAX_PER_HIT", "10")),
+		LabelMaxKeysPerClient: parseInt(getEnv("LABEL_MAX_KEYS_PER_CLIENT", "20")),
+		LabelMaxValuesPerKey:  parseInt(getEnv("LABEL_MAX_VALUES_PER_KEY", "500")),
+	}
+
+	// The OTLP receiver trusts the client named in the export, so it must not be open
+	if AppConfig.EnableOTLP && AppConfig.OTLPAuthToken == "" {
+		return errors.New("OTLP_AUTH_TOKEN is required when ENABLE_OTLP is set")
+	}
+
+	// Production needs dedicated hash keys; elsewhere missing ones are derived
+	// from the JWT secret, with a different label each so no two keys are equal
+	if AppConfig.Environment == "production" {
+		if AppConfig.UserIDHashKey == "" || AppConfig.IPHashKey == "" {
+			return errors.New("USER_ID_HASH_KEY and IP_HASH_KEY are required when APP_ENV is production")
+		}
+		if AppConfig.UserIDHashKey == AppConfig.IPHashKey ||
+			AppConfig.UserIDHashKey == AppConfig.JWTSecret || AppConfig.IPHashKey == AppConfig.JWTSecret {
+			return errors.New("USER_ID_HASH_KEY, IP_HASH_KEY and JWT_SECRET must all differ")
+		}
+	}
+	var err error
+	if AppConfig.UserIDHashKey == "" {
+		if AppConfig.UserIDHashKey, err = deriveKey(AppConfig.JWTSecret, "user-id-hash"); err != nil {
+			return err
+		}
+	}
+	if AppConfig.IPHashKey == "" {
+		if AppConfig.IPHashKey, err = deriveKey(AppConfig.JWTSecret, "ip-hash"); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// deriveKey derives a 256-bit key for one purpose from secret with HKDF-SHA256
+func deriveKey(secret, purpose string) (string, error) {
+	key, err := hkdf.Key(sha256.New, []byte(secret), nil, "user-activity-tracker "+purpose, 32)
+	if err != nil {
+		return "", err
+	}
+	return string(key), nil
+}
+
+// defaultConsumerName identifies this instance within a Redis consumer group
+func defaultConsumerName() string {
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		return hostname
+	}
+	return "tracker"
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -51,6 +246,17 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// parseList splits a comma-separated value, dropping empty items
+func parseList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func parseInt(s string) int {
 	i, err := strconv.Atoi(s)
 	if err != nil {
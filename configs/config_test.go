@@ -0,0 +1,83 @@
+package configs
+
+import "testing"
+
+func TestOTLPRequiresAuthToken(t *testing.T) {
+	original := AppConfig
+	t.Cleanup(func() { AppConfig = original })
+
+	t.Setenv("ENABLE_OTLP", "true")
+	t.Setenv("OTLP_AUTH_TOKEN", "")
+	if err := LoadConfig(); err == nil {
+		t.Error("LoadConfig succeeded with ENABLE_OTLP and no OTLP_AUTH_TOKEN")
+	}
+
+	t.Setenv("OTLP_AUTH_TOKEN", "secret")
+	if err := LoadConfig(); err != nil {
+		t.Errorf("LoadConfig with a token: %v", err)
+	}
+}
+
+func TestHashKeys(t *testing.T) {
+	original := AppConfig
+	t.Cleanup(func() { AppConfig = original })
+	t.Setenv("JWT_SECRET", "jwt-secret")
+
+	tests := []struct {
+		name    string
+		env     string
+		userKey string
+		ipKey   string
+		wantErr bool
+	}{
+		{name: "development derives missing keys", env: "development"},
+		{name: "development keeps set keys", env: "development", userKey: "user-key", ipKey: "ip-key"},
+		{name: "production with separate keys", env: "production", userKey: "user-key", ipKey: "ip-key"},
+		{name: "production without keys", env: "production", wantErr: true},
+		{name: "production without the IP key", env: "production", userKey: "user-key", wantErr: true},
+		{name: "production with equal keys", env: "production", userKey: "same", ipKey: "same", wantErr: true},
+		{name: "production reusing the JWT secret", env: "production", userKey: "jwt-secret", ipKey: "ip-key", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("APP_ENV", tt.env)
+			t.Setenv("USER_ID_HASH_KEY", tt.userKey)
+			t.Setenv("IP_HASH_KEY", tt.ipKey)
+
+			err := LoadConfig()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("LoadConfig succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+
+			userKey, ipKey := AppConfig.UserIDHashKey, AppConfig.IPHashKey
+			if tt.userKey != "" && (userKey != tt.userKey || ipKey != tt.ipKey) {
+				t.Errorf("keys = %q, %q, want the configured ones", userKey, ipKey)
+			}
+			if len(userKey) == 0 || userKey == ipKey || userKey == "jwt-secret" || ipKey == "jwt-secret" {
+				t.Errorf("keys must be set and differ from each other and the JWT secret: %q, %q", userKey, ipKey)
+			}
+		})
+	}
+
+	// Derived keys are stable across restarts
+	t.Setenv("APP_ENV", "development")
+	t.Setenv("USER_ID_HASH_KEY", "")
+	t.Setenv("IP_HASH_KEY", "")
+	if err := LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	first := *AppConfig
+	if err := LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if AppConfig.UserIDHashKey != first.UserIDHashKey || AppConfig.IPHashKey != first.IPHashKey {
+		t.Error("derived keys changed between loads")
+	}
+}
@@ -97,10 +97,9 @@ func (cm *CacheManager) handleUpdateMessage(payload string) {
 		return
 	}
 
-	// Invalidate related caches
+	// Invalidate related caches; usage series keys carry the usage version
+	// bumped by PublishUpdate instead, and leaderboards are refreshed on a schedule
 	cacheKeys := []string{
-		fmt.Sprintf("usage:daily:%s", update.ClientID),
-		"usage:top:last24h",
 		fmt.Sprintf("client:%s", update.ClientID),
 	}
 
@@ -132,6 +131,37 @@ func (cm *CacheManager) Set(key string, value interface{}, ttl time.Duration) er
 	return nil
 }
 
+// SetNX stores the value only if the key does not exist yet and reports whether it was stored
+func (cm *CacheManager) SetNX(key string, value interface{}, ttl time.Duration) (bool, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if cm.redisClient != nil {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return false, err
+		}
+
+		ctx, cancel := context.WithTimeout(cm.ctx, 5*time.Second)
+		defer cancel()
+
+		stored, err := cm.redisClient.SetNX(ctx, key, data, ttl).Result()
+		if err != nil {
+			return false, err
+		}
+		if stored {
+			cm.localCache.Set(key, value, ttl)
+		}
+		return stored, nil
+	}
+
+	// Fallback to local cache
+	if err := cm.localCache.Add(key, value, ttl); err != nil {
+		return false, nil
+	}
+	return true, nil
+}
+
 func (cm *CacheManager) Get(key string, target interface{}) (bool, error) {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
@@ -185,6 +215,12 @@ func (cm *CacheManager) Delete(key string) error {
 }
 
 func (cm *CacheManager) Increment(key string, value int64) (int64, error) {
+	return cm.increment(key, value, cache.DefaultExpiration)
+}
+
+// increment adds value to a counter; without Redis the counter is kept in the
+// local cache for expiration
+func (cm *CacheManager) increment(key string, value int64, expiration time.Duration) (int64, error) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
@@ -200,11 +236,15 @@ func (cm *CacheManager) Increment(key string, value int64) (int64, error) {
 		current = val.(int64)
 	}
 	current += value
-	cm.localCache.Set(key, current, cache.DefaultExpiration)
+	cm.localCache.Set(key, current, expiration)
 	return current, nil
 }
 
 func (cm *CacheManager) PublishUpdate(clientID string) {
+	// The version must outlive the series cached under it, or it would start
+	// over and serve them again
+	cm.increment(usageVersionKey(clientID), 1, cache.NoExpiration)
+
 	if cm.redisClient == nil {
 		return
 	}
@@ -222,11 +262,43 @@ func (cm *CacheManager) PublishUpdate(clientID string) {
 	cm.redisClient.Publish(ctx, "usage_updates", data)
 }
 
+// UsageKey builds the cache key of a client's usage series. Besides the series
+// kind and every query parameter it holds the client's usage version, so new
+// hits make all cached series of the client stale at once.
+func (cm *CacheManager) UsageKey(clientID, kind, params string) string {
+	return fmt.Sprintf("usage:%s:%s:v%d:%s", kind, clientID, cm.usageVersion(clientID), params)
+}
+
+// usageVersion returns the number of usage updates published for the client
+func (cm *CacheManager) usageVersion(clientID string) int64 {
+	key := usageVersionKey(clientID)
+
+	if cm.redisClient != nil {
+		ctx, cancel := context.WithTimeout(cm.ctx, 5*time.Second)
+		defer cancel()
+
+		version, err := cm.redisClient.Get(ctx, key).Int64()
+		if err != nil && err != redis.Nil {
+			// Unversioned keys would never be invalidated, so skip the cache
+			return time.Now().UnixNano()
+		}
+		return version
+	}
+
+	if val, found := cm.localCache.Get(key); found {
+		return val.(int64)
+	}
+	return 0
+}
+
+func usageVersionKey(clientID string) string {
+	return fmt.Sprintf("usage:version:%s", clientID)
+}
+
 // Cache warming functions
 func (cm *CacheManager) WarmUsageCache() {
 	// Pre-warm commonly accessed cache
 	keysToWarm := []string{
-		"usage:top:last24h",
 		"system:stats",
 	}
 
@@ -239,3 +311,8 @@ func (cm *CacheManager) WarmUsageCache() {
 func (cm *CacheManager) IsAvailable() bool {
 	return cm.redisClient != nil
 }
+
+// Client returns the Redis client, or nil when running on the local cache only
+func (cm *CacheManager) Client() *redis.Client {
+	return cm.redisClient
+}
@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+func TestLocalUsageVersionDoesNotExpire(t *testing.T) {
+	cm := &CacheManager{ctx: context.Background(), localCache: cache.New(time.Millisecond, 0)}
+
+	before := cm.UsageKey("client", "daily", "7d")
+	cm.PublishUpdate("client")
+	time.Sleep(5 * time.Millisecond)
+
+	if version := cm.usageVersion("client"); version != 1 {
+		t.Fatalf("version = %d after its default expiration, want 1", version)
+	}
+	if after := cm.UsageKey("client", "daily", "7d"); after == before {
+		t.Errorf("key %q did not change after an update", after)
+	}
+
+	// Other counters still expire
+	cm.Increment("counter:total:client", 1)
+	time.Sleep(5 * time.Millisecond)
+	if _, found := cm.localCache.Get("counter:total:client"); found {
+		t.Error("counter did not expire")
+	}
+}
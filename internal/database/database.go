@@ -10,6 +10,7 @@ import (
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
@@ -115,7 +116,120 @@ func (m *DBManager) GetShardConnection(shardID int) (*gorm.DB, error) {
 	return m.WriteDB, nil
 }
 
-// BatchInsertHits efficiently inserts multiple API hits
+// BatchInsertHits efficiently inserts multiple API hits in one transaction,
+// along with their idempotency keys; hits whose key another event committed
+// within IDEMPOTENCY_TTL are duplicates and left out
 func (m *DBManager) BatchInsertHits(hits []models.APILogs) error {
-	return m.WriteDB.CreateInBatches(hits, 1000).Error
+	return m.WriteDB.Transaction(func(tx *gorm.DB) error {
+		hits, err := commitIdempotencyKeys(tx, hits)
+		if err != nil || len(hits) == 0 {
+			return err
+		}
+		if err := tx.CreateInBatches(hits, 1000).Error; err != nil {
+			return err
+		}
+
+		// Labels reference the generated log IDs
+		var labels []models.APILogLabel
+		for _, hit := range hits {
+			for key, value := range hit.Labels {
+				labels = append(labels, models.APILogLabel{
+					LogID:        hit.ID,
+					Key:          key,
+					Value:        value,
+					ClientID:     hit.ClientID,
+					Timestamp:    hit.Timestamp,
+					SampleWeight: hit.SampleWeight,
+				})
+			}
+		}
+		if len(labels) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(labels, 1000).Error
+	})
+}
+
+// CommitIdempotencyKeys commits the idempotency keys of hits that are not stored
+func (m *DBManager) CommitIdempotencyKeys(hits []models.APILogs) error {
+	return m.WriteDB.Transaction(func(tx *gorm.DB) error {
+		_, err := commitIdempotencyKeys(tx, hits)
+		return err
+	})
+}
+
+// commitIdempotencyKeys records the idempotency keys of hits and returns the
+// hits that own their key. Keys older than IDEMPOTENCY_TTL are taken over.
+func commitIdempotencyKeys(tx *gorm.DB, hits []models.APILogs) ([]models.APILogs, error) {
+	now := time.Now()
+	var keys []models.IdempotencyKey
+	var pairs [][]interface{}
+	for _, hit := range hits {
+		if hit.IdempotencyKey != "" {
+			keys = append(keys, models.IdempotencyKey{ClientID: hit.ClientID, Key: hit.IdempotencyKey, EventID: hit.EventID, CreatedAt: now})
+			pairs = append(pairs, []interface{}{hit.ClientID, hit.IdempotencyKey})
+		}
+	}
+	if len(keys) == 0 {
+		return hits, nil
+	}
+
+	// event_id is assigned first since its test reads the old created_at
+	cutoff := now.Add(-configs.AppConfig.IdempotencyTTL)
+	err := tx.Clauses(clause.OnConflict{DoUpdates: clause.Set{
+		{Column: clause.Column{Name: "event_id"}, Value: gorm.Expr("IF(created_at < ?, VALUES(event_id), event_id)", cutoff)},
+		{Column: clause.Column{Name: "created_at"}, Value: gorm.Expr("IF(created_at < ?, VALUES(created_at), created_at)", cutoff)},
+	}}).CreateInBatches(keys, 1000).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var owners []models.IdempotencyKey
+	if err := tx.Where("(client_id, idempotency_key) IN ?", pairs).Find(&owners).Error; err != nil {
+		return nil, err
+	}
+	owner := make(map[string]string, len(owners))
+	for _, key := range owners {
+		owner[key.ClientID+"\x00"+key.Key] = key.EventID
+	}
+
+	kept := make([]models.APILogs, 0, len(hits))
+	for _, hit := range hits {
+		if hit.IdempotencyKey == "" || owner[hit.ClientID+"\x00"+hit.IdempotencyKey] == hit.EventID {
+			kept = append(kept, hit)
+		}
+	}
+	return kept, nil
+}
+
+// PurgeIdempotencyKeys deletes up to limit keys created before cutoff and
+// returns how many were deleted
+func (m *DBManager) PurgeIdempotencyKeys(cutoff time.Time, limit int) (int64, error) {
+	result := m.WriteDB.Where("created_at < ?", cutoff).Limit(limit).Delete(&models.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
+
+// RebuildDailyUsage recomputes daily_usage rows for a date from api_logs.
+// When no client IDs are given, every client with hits on that date is rebuilt.
+func (m *DBManager) RebuildDailyUsage(date time.Time, clientIDs ...string) error {
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	end := start.AddDate(0, 0, 1)
+
+	query := `INSERT INTO daily_usage (client_id, date, request_count)
+		SELECT client_id, DATE(timestamp), CAST(ROUND(SUM(sample_weight)) AS SIGNED)
+		FROM api_logs
+		WHERE timestamp >= ? AND timestamp < ?`
+	args := []interface{}{start, end}
+
+	if len(clientIDs) > 0 {
+		query += " AND client_id IN ?"
+		args = append(args, clientIDs)
+	}
+
+	query += ` GROUP BY client_id, DATE(timestamp)
+		ON DUPLICATE KEY UPDATE
+			request_count = VALUES(request_count),
+			updated_at = CURRENT_TIMESTAMP`
+
+	return m.WriteDB.Exec(query, args...).Error
 }
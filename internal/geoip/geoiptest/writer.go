@@ -0,0 +1,227 @@
+// Package geoiptest builds small MaxMind DB files for tests
+package geoiptest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+)
+
+// Network is a prefix and the record the database returns for it. Record
+// values may be strings, uint16, uint32, uint64, []interface{} and nested
+// map[string]interface{}.
+type Network struct {
+	CIDR   string
+	Record map[string]interface{}
+}
+
+// Options describe the database layout
+type Options struct {
+	IPVersion    int // 4 or 6; IPv4 networks are stored under ::/96 in IPv6 databases
+	RecordSize   int // 24, 28 or 32 bits
+	DatabaseType string
+	BuildEpoch   uint64
+}
+
+// empty marks a record without data or subtree
+const empty = -1
+
+// Build encodes networks as an MMDB file. Networks are inserted from the
+// shortest prefix to the longest, so more specific networks win.
+func Build(opts Options, networks []Network) ([]byte, error) {
+	if opts.IPVersion != 4 && opts.IPVersion != 6 {
+		return nil, fmt.Errorf("unsupported IP version %d", opts.IPVersion)
+	}
+	if opts.RecordSize != 24 && opts.RecordSize != 28 && opts.RecordSize != 32 {
+		return nil, fmt.Errorf("unsupported record size %d", opts.RecordSize)
+	}
+
+	type prefix struct {
+		addr  []byte
+		bits  int
+		index int
+	}
+	var prefixes []prefix
+	for i, network := range networks {
+		_, ipNet, err := net.ParseCIDR(network.CIDR)
+		if err != nil {
+			return nil, err
+		}
+		ones, _ := ipNet.Mask.Size()
+		addr := []byte(ipNet.IP)
+		if ip4 := ipNet.IP.To4(); ip4 != nil {
+			addr = ip4
+			if opts.IPVersion == 6 {
+				addr = append(make([]byte, 12), ip4...)
+				ones += 96
+			}
+		} else if opts.IPVersion == 4 {
+			return nil, fmt.Errorf("IPv6 network %s in an IPv4 database", network.CIDR)
+		}
+		prefixes = append(prefixes, prefix{addr: addr, bits: ones, index: i})
+	}
+	sort.SliceStable(prefixes, func(i, j int) bool { return prefixes[i].bits < prefixes[j].bits })
+
+	// A child is a node index, empty, or -2-i for the record of networks[i]
+	nodes := [][2]int{{empty, empty}}
+	for _, p := range prefixes {
+		node := 0
+		for i := 0; i < p.bits; i++ {
+			bit := (p.addr[i/8] >> (7 - uint(i%8))) & 1
+			if i == p.bits-1 {
+				nodes[node][bit] = -2 - p.index
+				break
+			}
+			child := nodes[node][bit]
+			if child < 0 {
+				// Split an empty or covering record into a subtree
+				nodes = append(nodes, [2]int{child, child})
+				child = len(nodes) - 1
+				nodes[node][bit] = child
+			}
+			node = child
+		}
+	}
+
+	var data []byte
+	offsets := make([]int, len(networks))
+	for i, network := range networks {
+		offsets[i] = len(data)
+		encoded, err := encode(network.Record)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, encoded...)
+	}
+
+	nodeCount := len(nodes)
+	value := func(child int) uint32 {
+		switch {
+		case child == empty:
+			return uint32(nodeCount)
+		case child < 0:
+			return uint32(nodeCount + 16 + offsets[-2-child])
+		}
+		return uint32(child)
+	}
+
+	var buf bytes.Buffer
+	for _, node := range nodes {
+		left, right := value(node[0]), value(node[1])
+		switch opts.RecordSize {
+		case 24:
+			buf.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left), byte(right >> 16), byte(right >> 8), byte(right)})
+		case 28:
+			buf.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left),
+				byte(left>>24&0x0F)<<4 | byte(right>>24&0x0F),
+				byte(right >> 16), byte(right >> 8), byte(right)})
+		case 32:
+			binary.Write(&buf, binary.BigEndian, [2]uint32{left, right})
+		}
+	}
+	buf.Write(make([]byte, 16))
+	buf.Write(data)
+
+	metadata, err := encode(map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 opts.BuildEpoch,
+		"database_type":               opts.DatabaseType,
+		"description":                 map[string]interface{}{"en": "test database"},
+		"ip_version":                  uint16(opts.IPVersion),
+		"languages":                   []interface{}{"en"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(opts.RecordSize),
+	})
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteString("\xAB\xCD\xEFMaxMind.com")
+	buf.Write(metadata)
+	return buf.Bytes(), nil
+}
+
+// Data section types
+const (
+	typeString = 2
+	typeUint16 = 5
+	typeUint32 = 6
+	typeMap    = 7
+	typeUint64 = 9
+	typeArray  = 11
+)
+
+// encode encodes a value of the data section
+func encode(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return append(control(typeString, len(v)), v...), nil
+	case uint16:
+		return encodeUint(typeUint16, uint64(v)), nil
+	case uint32:
+		return encodeUint(typeUint32, uint64(v)), nil
+	case uint64:
+		return encodeUint(typeUint64, v), nil
+	case []interface{}:
+		b := control(typeArray, len(v))
+		for _, item := range v {
+			encoded, err := encode(item)
+			if err != nil {
+				return nil, err
+			}
+			b = append(b, encoded...)
+		}
+		return b, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		b := control(typeMap, len(v))
+		for _, key := range keys {
+			encoded, err := encode(v[key])
+			if err != nil {
+				return nil, err
+			}
+			b = append(append(b, control(typeString, len(key))...), key...)
+			b = append(b, encoded...)
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("unsupported value %T", v)
+}
+
+func encodeUint(typ int, v uint64) []byte {
+	var b []byte
+	for ; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	return append(control(typ, len(b)), b...)
+}
+
+// control encodes the control byte of a value, followed by the extended type
+// and size bytes when needed
+func control(typ, size int) []byte {
+	var b []byte
+	if typ > 7 {
+		b = []byte{0, byte(typ - 7)}
+	} else {
+		b = []byte{byte(typ << 5)}
+	}
+
+	switch {
+	case size < 29:
+		b[0] |= byte(size)
+	case size < 285:
+		b[0] |= 29
+		b = append(b, byte(size-29))
+	default:
+		b[0] |= 30
+		b = append(b, byte((size-285)>>8), byte(size-285))
+	}
+	return b
+}
@@ -0,0 +1,56 @@
+package geoip
+
+import "net"
+
+// Location is the geographic and network information of an address. Fields
+// the database does not provide are left empty.
+type Location struct {
+	Country string // ISO 3166-1 alpha-2 code
+	Region  string // first-level subdivision name, e.g. a state or province
+	ASN     uint32
+	ASOrg   string
+}
+
+// record holds the fields of the GeoIP2/GeoLite2 Country, City and ASN
+// database layouts that make up a Location
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+	Subdivisions []struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	ASN   uint32 `maxminddb:"autonomous_system_number"`
+	ASOrg string `maxminddb:"autonomous_system_organization"`
+}
+
+// Locate looks up ip. Addresses the database does not cover, including IPv6
+// addresses in an IPv4 database, have an empty Location.
+func (r *Reader) Locate(ip net.IP) (Location, error) {
+	var loc Location
+	if ip.To4() == nil && r.Metadata.IPVersion == 4 {
+		return loc, nil
+	}
+
+	var rec record
+	if err := r.db.Lookup(ip, &rec); err != nil {
+		return loc, err
+	}
+
+	loc.Country = rec.Country.ISOCode
+	if loc.Country == "" {
+		loc.Country = rec.RegisteredCountry.ISOCode
+	}
+	if len(rec.Subdivisions) > 0 {
+		loc.Region = rec.Subdivisions[0].Names["en"]
+		if loc.Region == "" {
+			loc.Region = rec.Subdivisions[0].ISOCode
+		}
+	}
+	loc.ASN, loc.ASOrg = rec.ASN, rec.ASOrg
+	return loc, nil
+}
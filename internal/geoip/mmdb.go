@@ -0,0 +1,36 @@
+// Package geoip looks up addresses in MaxMind DB (MMDB) files such as
+// GeoLite2-City and GeoLite2-ASN, using the maxminddb-golang reader.
+package geoip
+
+import (
+	"os"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Reader looks up IP addresses in an MMDB file held in memory. It is safe for
+// concurrent use.
+type Reader struct {
+	Metadata maxminddb.Metadata
+
+	db *maxminddb.Reader
+}
+
+// Open reads an MMDB file into memory. Nothing stays mapped, so the file can
+// be replaced on disk while the reader is in use.
+func Open(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return FromBytes(buf)
+}
+
+// FromBytes parses an MMDB file's contents
+func FromBytes(buf []byte) (*Reader, error) {
+	db, err := maxminddb.FromBytes(buf)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{Metadata: db.Metadata, db: db}, nil
+}
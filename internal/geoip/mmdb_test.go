@@ -0,0 +1,143 @@
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"user-activity-tracker/internal/geoip/geoiptest"
+)
+
+var cityNetworks = []geoiptest.Network{
+	{CIDR: "203.0.113.0/24", Record: map[string]interface{}{
+		"country": map[string]interface{}{"iso_code": "US"},
+		"subdivisions": []interface{}{
+			map[string]interface{}{"iso_code": "CA", "names": map[string]interface{}{"en": "California", "de": "Kalifornien"}},
+		},
+	}},
+	// A more specific network inside the one above
+	{CIDR: "203.0.113.128/25", Record: map[string]interface{}{
+		"country":      map[string]interface{}{"iso_code": "US"},
+		"subdivisions": []interface{}{map[string]interface{}{"iso_code": "NV"}},
+	}},
+	{CIDR: "198.51.100.0/24", Record: map[string]interface{}{
+		"registered_country": map[string]interface{}{"iso_code": "NL"},
+	}},
+}
+
+var asnNetworks = []geoiptest.Network{
+	{CIDR: "203.0.113.0/24", Record: map[string]interface{}{
+		"autonomous_system_number":       uint32(64500),
+		"autonomous_system_organization": "Example Networks, a rather long organisation name to need a wider size",
+	}},
+}
+
+func build(t *testing.T, opts geoiptest.Options, networks []geoiptest.Network) *Reader {
+	t.Helper()
+	buf, err := geoiptest.Build(opts, networks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := FromBytes(buf)
+	if err != nil {
+		t.Fatalf("FromBytes: %v", err)
+	}
+	return r
+}
+
+func TestLocate(t *testing.T) {
+	v6Networks := append([]geoiptest.Network{
+		{CIDR: "2001:db8::/32", Record: map[string]interface{}{"country": map[string]interface{}{"iso_code": "DE"}}},
+	}, cityNetworks...)
+
+	tests := []struct {
+		ip     string
+		v4, v6 Location // expected in an IPv4 and an IPv6 database
+	}{
+		{"203.0.113.7", Location{Country: "US", Region: "California"}, Location{Country: "US", Region: "California"}},
+		{"203.0.113.200", Location{Country: "US", Region: "NV"}, Location{Country: "US", Region: "NV"}},
+		{"198.51.100.1", Location{Country: "NL"}, Location{Country: "NL"}},
+		{"192.0.2.1", Location{}, Location{}},
+		{"::ffff:203.0.113.7", Location{Country: "US", Region: "California"}, Location{Country: "US", Region: "California"}},
+		{"2001:db8::1", Location{}, Location{Country: "DE"}},
+		{"2001:db9::1", Location{}, Location{}},
+	}
+
+	for _, size := range []int{24, 28, 32} {
+		for _, version := range []int{4, 6} {
+			t.Run(fmt.Sprintf("IPv%d/%d-bit", version, size), func(t *testing.T) {
+				networks := cityNetworks
+				if version == 6 {
+					networks = v6Networks
+				}
+				r := build(t, geoiptest.Options{IPVersion: version, RecordSize: size, DatabaseType: "GeoLite2-City"}, networks)
+				if r.Metadata.RecordSize != uint(size) || r.Metadata.DatabaseType != "GeoLite2-City" {
+					t.Errorf("metadata = %+v", r.Metadata)
+				}
+
+				for _, tt := range tests {
+					want := tt.v4
+					if version == 6 {
+						want = tt.v6
+					}
+					loc, err := r.Locate(net.ParseIP(tt.ip))
+					if err != nil {
+						t.Errorf("Locate(%s): %v", tt.ip, err)
+						continue
+					}
+					if loc != want {
+						t.Errorf("Locate(%s) = %+v, want %+v", tt.ip, loc, want)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestLocateASN(t *testing.T) {
+	r := build(t, geoiptest.Options{IPVersion: 6, RecordSize: 24, DatabaseType: "GeoLite2-ASN"}, asnNetworks)
+
+	loc, err := r.Locate(net.ParseIP("203.0.113.9"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loc.ASN != 64500 || loc.ASOrg != asnNetworks[0].Record["autonomous_system_organization"] || loc.Country != "" {
+		t.Errorf("Locate = %+v, want AS64500 without a country", loc)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	buf, err := geoiptest.Build(geoiptest.Options{IPVersion: 4, RecordSize: 28, BuildEpoch: 1714521600}, cityNetworks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "city.mmdb")
+	if err := os.WriteFile(path, buf, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if r.Metadata.BuildEpoch != 1714521600 {
+		t.Errorf("build epoch = %d", r.Metadata.BuildEpoch)
+	}
+
+	// The reader holds its own copy, so the file can be replaced
+	if err := os.WriteFile(path, []byte("not a database"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if loc, err := r.Locate(net.ParseIP("203.0.113.7")); err != nil || loc.Country != "US" {
+		t.Errorf("Locate after the file changed = %+v, %v", loc, err)
+	}
+
+	if _, err := Open(path); err == nil {
+		t.Error("Open succeeded on a file that is not a database")
+	}
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.mmdb")); err == nil {
+		t.Error("Open succeeded on a missing file")
+	}
+}
@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"user-activity-tracker/configs"
@@ -16,19 +20,28 @@ import (
 	"gorm.io/gorm"
 )
 
+var errIdempotencyCheck = errors.New("failed to check idempotency key")
+
 type ClientHandler struct {
-	db          *gorm.DB
-	authService *services.AuthService
-	cache       *cache.CacheManager
-	wsHandler   *WebSocketHandler // Add this line
+	db            *gorm.DB
+	authService   *services.AuthService
+	ingestService *services.IngestService
+	leaderboards  *services.LeaderboardService
+	ipPrivacy     *services.IPPrivacyService
+	cache         *cache.CacheManager
+	wsHandler     *WebSocketHandler // Add this line
 }
 
-func NewClientHandler(authService *services.AuthService, wsHandler *WebSocketHandler) *ClientHandler {
+func NewClientHandler(authService *services.AuthService, ingestService *services.IngestService, leaderboards *services.LeaderboardService,
+	ipPrivacy *services.IPPrivacyService, wsHandler *WebSocketHandler) *ClientHandler {
 	return &ClientHandler{
-		db:          database.GetDBManager().WriteDB,
-		authService: authService,
-		cache:       cache.GetCacheManager(),
-		wsHandler:   wsHandler, // Add this line
+		db:            database.GetDBManager().WriteDB,
+		authService:   authService,
+		ingestService: ingestService,
+		leaderboards:  leaderboards,
+		ipPrivacy:     ipPrivacy,
+		cache:         cache.GetCacheManager(),
+		wsHandler:     wsHandler, // Add this line
 	}
 }
 
@@ -56,6 +69,20 @@ func (h *ClientHandler) RegisterClient(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Name and email are required"})
 		return
 	}
+	if req.UserIDStorage == "" {
+		req.UserIDStorage = services.UserIDHashed
+	}
+	if req.UserIDStorage != services.UserIDHashed && req.UserIDStorage != services.UserIDRaw {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: services.ErrInvalidUserIDStorage.Error()})
+		return
+	}
+	if req.IPPrivacy == "" {
+		req.IPPrivacy = services.IPPrivacyFull
+	}
+	if !services.ValidIPPrivacy(req.IPPrivacy) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: services.ErrInvalidIPPrivacy.Error()})
+		return
+	}
 
 	// Check if email already exists
 	var existingClient models.Client
@@ -82,6 +109,9 @@ func (h *ClientHandler) RegisterClient(c *gin.Context) {
 		Email:       req.Email,
 		APIKey:      hashedAPIKey,
 		IPWhitelist: req.IPWhitelist,
+
+		UserIDStorage: req.UserIDStorage,
+		IPPrivacy:     req.IPPrivacy,
 	}
 
 	if err := h.db.Create(&client).Error; err != nil {
@@ -126,11 +156,13 @@ func (h *ClientHandler) RegisterClient(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param request body LogRequest true "API hit data"
+// @Param Idempotency-Key header string false "Key used to deduplicate retried submissions"
 // @Security ApiKeyAuth
+// @Success 200 {object} SuccessResponse "Duplicate submission, original hit_id returned"
 // @Success 202 {object} SuccessResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
 // @Router /api/logs [post]
 func (h *ClientHandler) RecordLog(c *gin.Context) {
 	var req LogRequest
@@ -139,198 +171,712 @@ func (h *ClientHandler) RecordLog(c *gin.Context) {
 		return
 	}
 
+	if errMsg := validateHitDetails(req.HitDetails); errMsg != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: errMsg})
+		return
+	}
+	if len(req.EventID) > 64 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "event_id exceeds 64 characters"})
+		return
+	}
+
+	now := time.Now()
+	if errMsg := validateTimestamp(req.Timestamp, now, false); errMsg != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: errMsg})
+		return
+	}
+
 	clientID, _ := c.Get("client_id")
 	ipAddress := c.ClientIP()
 
+	// Resolve the idempotency key: header first, then the event ID
+	idempotencyKey := c.GetHeader("Idempotency-Key")
+	if idempotencyKey == "" {
+		idempotencyKey = req.EventID
+	}
+	if len(idempotencyKey) > 255 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Idempotency-Key exceeds 255 characters"})
+		return
+	}
+
 	// Create API hit record
-	apiHit := models.APILogs{
-		ClientID:  clientID.(string),
-		Endpoint:  req.Endpoint,
-		IPAddress: ipAddress,
-		Timestamp: time.Now(),
+	apiHit, store := h.buildEntryHit(clientID.(string), ipAddress, LogBatchEntry{
+		EventID:    req.EventID,
+		Endpoint:   req.Endpoint,
+		Timestamp:  req.Timestamp,
+		HitDetails: req.HitDetails,
+	}, now)
+
+	hitID, duplicate, err := h.ingestHit(apiHit, store, idempotencyKey)
+	if errors.Is(err, errIdempotencyCheck) {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to check idempotency key"})
+		return
+	}
+	if err != nil {
+		h.rejectIngest(c, err)
+		return
 	}
 
-	// Use batch insert for better performance
-	// In production, this would be queued and processed asynchronously
-	if err := h.db.Create(&apiHit).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to record log"})
+	if duplicate {
+		c.Header("Idempotent-Replayed", "true")
+		c.JSON(http.StatusOK, SuccessResponse{
+			Message: "Duplicate log ignored",
+			Data:    map[string]interface{}{"hit_id": hitID, "duplicate": true},
+		})
 		return
 	}
 
-	// Update cache counters atomically
-	dailyKey := fmt.Sprintf("counter:daily:%s:%s", clientID, time.Now().Format("2006-01-02"))
-	totalKey := fmt.Sprintf("counter:total:%s", clientID)
+	c.JSON(http.StatusAccepted, SuccessResponse{
+		Message: "Log accepted for processing",
+		Data:    map[string]interface{}{"hit_id": hitID, "status": "queued"},
+	})
+}
+
+// ingestHit claims the idempotency key (if any), queues the hit unless it was
+// sampled out, and publishes the usage update. For duplicates it returns the
+// original hit ID and does nothing else.
+func (h *ClientHandler) ingestHit(hit models.APILogs, store bool, idempotencyKey string) (string, bool, error) {
+	if idempotencyKey != "" {
+		originalID, duplicate, err := h.ingestService.ClaimIdempotencyKey(hit.ClientID, idempotencyKey, hit.EventID)
+		if err != nil {
+			return "", false, errIdempotencyCheck
+		}
+		if duplicate {
+			return originalID, true, nil
+		}
+	}
+
+	// Queue the hit; workers persist it and its key through BatchInsertHits
+	hit.IdempotencyKey = idempotencyKey
+	if store {
+		if err := h.ingestService.Enqueue(hit); err != nil {
+			if idempotencyKey != "" {
+				h.ingestService.ReleaseIdempotencyKey(hit.ClientID, idempotencyKey)
+			}
+			return "", false, err
+		}
+	} else if idempotencyKey != "" {
+		h.ingestService.CommitIdempotencyKeys(hit)
+	}
+
+	// Update cache counters atomically; they count every hit, sampled or not
+	dailyKey := fmt.Sprintf("counter:daily:%s:%s", hit.ClientID, hit.Timestamp.Format("2006-01-02"))
+	totalKey := fmt.Sprintf("counter:total:%s", hit.ClientID)
 
 	h.cache.Increment(dailyKey, 1)
 	h.cache.Increment(totalKey, 1)
+	if store {
+		h.leaderboards.Record(hit)
+	}
 
 	// Publish update for real-time notifications
-	h.cache.PublishUpdate(clientID.(string))
+	h.cache.PublishUpdate(hit.ClientID)
 	if h.wsHandler != nil {
-		h.wsHandler.BroadcastUpdate(clientID.(string), map[string]interface{}{
-			"endpoint":   req.Endpoint,
-			"ip_address": ipAddress,
-			"timestamp":  time.Now().Unix(),
-		})
+		h.wsHandler.BroadcastUpdate(hit.ClientID, hitBroadcastData(hit))
 	}
 
-	c.JSON(http.StatusAccepted, SuccessResponse{
-		Message: "Log recorded successfully",
-		Data:    map[string]interface{}{"hit_id": apiHit.ID},
-	})
+	return hit.EventID, false, nil
 }
 
-type UsageRecord struct {
-	Date         string `json:"date"`
-	RequestCount int64  `json:"request_count"`
+// RecordLogBatch handles bulk API hit logging
+// @Summary Record a batch of API hits
+// @Description Record many API hits in one request. Each entry is validated on its own and the response carries a result per entry.
+// @Tags logs
+// @Accept json
+// @Produce json
+// @Param request body LogBatchRequest true "Batch of API hits"
+// @Security ApiKeyAuth
+// @Success 202 {object} LogBatchResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/logs/batch [post]
+func (h *ClientHandler) RecordLogBatch(c *gin.Context) {
+	var req LogBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+
+	h.processBatch(c, req.Entries, false)
 }
 
-// GetDailyUsage returns daily usage for last 7 days
-// @Summary Get daily usage
-// @Description Get total daily requests per client for the last 7 days
-// @Tags usage
+// RecordLogBackfill handles historical hit imports
+// @Summary Backfill historical API hits
+// @Description Record hits with past timestamps beyond the live skew window. Requires the backfill permission. Affected daily_usage rows are re-aggregated.
+// @Tags logs
+// @Accept json
 // @Produce json
+// @Param request body LogBatchRequest true "Batch of historical API hits, each with a timestamp"
 // @Security ApiKeyAuth
-// @Success 200 {object} DailyUsageResponse
+// @Success 202 {object} LogBatchResponse
+// @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
-// @Router /api/usage/daily [get]
-func (h *ClientHandler) GetDailyUsage(c *gin.Context) {
-	clientID, _ := c.Get("client_id")
+// @Router /api/logs/backfill [post]
+func (h *ClientHandler) RecordLogBackfill(c *gin.Context) {
+	var req LogBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
 
-	// Try cache first
-	cacheKey := fmt.Sprintf("usage:daily:%s", clientID)
-	var cachedResponse DailyUsageResponse
-	if found, err := h.cache.Get(cacheKey, &cachedResponse); found && err == nil {
-		c.JSON(http.StatusOK, cachedResponse)
+	h.processBatch(c, req.Entries, true)
+}
+
+// processBatch validates, deduplicates and stores a batch of entries. In backfill
+// mode every entry needs a timestamp and the affected days are re-aggregated.
+func (h *ClientHandler) processBatch(c *gin.Context, entries []LogBatchEntry, backfill bool) {
+	maxSize := configs.AppConfig.LogBatchMaxSize
+	if maxSize > 0 && len(entries) > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{Error: fmt.Sprintf("Batch exceeds maximum of %d entries", maxSize)})
 		return
 	}
 
-	// Calculate date range
-	endDate := time.Now()
-	startDate := endDate.AddDate(0, 0, -6) // Last 7 days
+	clientID := c.GetString("client_id")
+	requestIP := c.ClientIP()
+	now := time.Now()
 
-	// Query from database (using read replica)
-	readDB := database.GetDBManager().GetReadDB()
+	results := make([]LogBatchResult, len(entries))
+	hits := make([]models.APILogs, 0, len(entries))
+	stored := make([]models.APILogs, 0, len(entries))
+	hitIndexes := make([]int, 0, len(entries))
+	claimedKeys := make([]string, 0)
+	sampled := make([]models.APILogs, 0)
 
-	// Get daily aggregated usage
-	var dailyUsage []UsageRecord
-	err := readDB.Model(&models.DailyUsage{}).
-		Select("DATE_FORMAT(date, '%Y-%m-%d') as date, SUM(request_count) as request_count").
-		Where("client_id = ? AND date >= ? AND date < ?",
-			clientID,
-			startDate.Format("2006-01-02"),
-			endDate.Format("2006-01-02")).
-		Group("date").
-		Order("date DESC").
-		Scan(&dailyUsage).Error
+	for i, entry := range entries {
+		results[i] = LogBatchResult{Index: i}
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to fetch usage data"})
-		return
+		if errMsg := validateBatchEntry(entry, now, backfill); errMsg != "" {
+			results[i].Status = "rejected"
+			results[i].Error = errMsg
+			continue
+		}
+
+		if entry.EventID != "" {
+			originalID, duplicate, err := h.ingestService.ClaimIdempotencyKey(clientID, entry.EventID, entry.EventID)
+			if err != nil {
+				results[i].Status = "rejected"
+				results[i].Error = "failed to check event_id"
+				continue
+			}
+			if duplicate {
+				results[i].Status = "duplicate"
+				results[i].HitID = originalID
+				continue
+			}
+			claimedKeys = append(claimedKeys, entry.EventID)
+		}
+
+		hit, store := h.buildEntryHit(clientID, requestIP, entry, now)
+		hit.IdempotencyKey = entry.EventID
+		hits = append(hits, hit)
+		hitIndexes = append(hitIndexes, i)
+		if store {
+			stored = append(stored, hit)
+		} else if hit.IdempotencyKey != "" {
+			sampled = append(sampled, hit)
+		}
+	}
+
+	duplicates := 0
+	for _, result := range results {
+		if result.Status == "duplicate" {
+			duplicates++
+		}
 	}
 
-	// Get today's usage from logs
-	var todayUsage UsageRecord
-	err = readDB.Model(&models.APILogs{}).
-		Select("DATE_FORMAT(timestamp, '%Y-%m-%d') as date, COUNT(id) as request_count").
-		Where("client_id = ? AND DATE(timestamp) = ?",
-			clientID,
-			endDate.Format("2006-01-02")).
-		Group("date").
-		Scan(&todayUsage).Error
+	if len(hits) == 0 {
+		status := http.StatusBadRequest
+		if duplicates > 0 {
+			status = http.StatusOK
+		}
+		c.JSON(status, LogBatchResponse{
+			Duplicates: duplicates,
+			Rejected:   len(results) - duplicates,
+			Results:    results,
+		})
+		return
+	}
 
+	spooled, err := h.ingestService.InsertBatch(stored)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to fetch today's usage"})
+		for _, key := range claimedKeys {
+			h.ingestService.ReleaseIdempotencyKey(clientID, key)
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to record logs"})
 		return
 	}
 
-	// Merge results (replace today's aggregated data with real-time data)
-	result := make([]UsageRecord, 0, len(dailyUsage)+1)
-	todayStr := endDate.Format("2006-01-02")
+	if len(sampled) > 0 {
+		h.ingestService.CommitIdempotencyKeys(sampled...)
+	}
+
+	for n, i := range hitIndexes {
+		results[i].Status = "accepted"
+		results[i].HitID = hits[n].EventID
+	}
+
+	// Update cache counters once per batch
+	dailyCounts := make(map[string]int64)
+	for _, hit := range hits {
+		dailyCounts[hit.Timestamp.Format("2006-01-02")]++
+	}
+	h.incrementCounters(clientID, dailyCounts)
+	h.leaderboards.Record(stored...)
 
-	// Add or update today's data
-	if todayUsage.Date == todayStr && todayUsage.RequestCount > 0 {
-		// Replace aggregated today data with real-time data
-		for _, record := range dailyUsage {
-			if record.Date != todayStr {
-				result = append(result, record)
+	response := LogBatchResponse{
+		Accepted:   len(hits),
+		Duplicates: duplicates,
+		Rejected:   len(results) - len(hits) - duplicates,
+		Results:    results,
+		Spooled:    spooled,
+	}
+
+	// Spooled hits are re-aggregated when they are replayed
+	if backfill && !spooled {
+		// Rebuild aggregates for every past day touched by the backfill
+		today := now.Format("2006-01-02")
+		for date := range dailyCounts {
+			if date >= today {
+				continue
+			}
+			day, _ := time.ParseInLocation("2006-01-02", date, time.Local)
+			if err := database.GetDBManager().RebuildDailyUsage(day, clientID); err != nil {
+				c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Hits stored but failed to re-aggregate daily usage"})
+				return
 			}
+			response.ReaggregatedDates = append(response.ReaggregatedDates, date)
 		}
-		result = append(result, todayUsage)
-	} else {
-		// Just use the aggregated data
-		result = dailyUsage
+		sort.Strings(response.ReaggregatedDates)
+	}
+
+	// Publish a single update for the whole batch, which also invalidates the
+	// cached usage of re-aggregated days
+	h.cache.PublishUpdate(clientID)
+	if h.wsHandler != nil && !backfill {
+		h.wsHandler.BroadcastUpdate(clientID, map[string]interface{}{
+			"batch_size": len(hits),
+			"timestamp":  now.Unix(),
+		})
 	}
 
-	str := fmt.Sprintf("%v", clientID)
+	c.JSON(http.StatusAccepted, response)
+}
 
-	// Fill in missing days with zero
-	response := h.fillMissingDays(str, result, startDate, endDate)
+// buildEntryHit turns a validated entry into an API hit record and runs it
+// through the ingestion stages. store is false when the hit was sampled out.
+func (h *ClientHandler) buildEntryHit(clientID, requestIP string, entry LogBatchEntry, now time.Time) (models.APILogs, bool) {
+	ipAddress := entry.IPAddress
+	if ipAddress == "" {
+		ipAddress = requestIP
+	}
 
-	// Cache the result
-	h.cache.Set(cacheKey, response, configs.AppConfig.CacheTTL)
+	// MySQL stores server-local time, so days are counted in it whatever
+	// offset the client sent
+	timestamp := now
+	if entry.Timestamp != nil {
+		timestamp = entry.Timestamp.In(time.Local)
+	}
+
+	eventID := entry.EventID
+	if eventID == "" {
+		eventID = uuid.New().String()
+	}
+
+	hit := models.APILogs{
+		ClientID:  clientID,
+		EventID:   eventID,
+		Endpoint:  entry.Endpoint,
+		IPAddress: ipAddress,
+		Timestamp: timestamp,
+	}
+	entry.HitDetails.applyTo(&hit)
+	store := h.ingestService.Prepare(&hit)
+	return hit, store
+}
+
+// clientEntry is a validated entry attributed to a client by a transport that
+// carries hits for several clients, with the time the hit was received
+type clientEntry struct {
+	ClientID string
+	Entry    LogBatchEntry
+	Received time.Time
+}
+
+// storeClientEntries deduplicates and stores entries for any number of clients,
+// then updates counters and notifies subscribers. Nothing is stored on error.
+func (h *ClientHandler) storeClientEntries(entries []clientEntry) (accepted, duplicates int, err error) {
+	hits := make([]models.APILogs, 0, len(entries))
+	stored := make([]models.APILogs, 0, len(entries))
+	sampled := make([]models.APILogs, 0)
+	claimed := make(map[string][]string)
+
+	release := func() {
+		for clientID, keys := range claimed {
+			for _, key := range keys {
+				h.ingestService.ReleaseIdempotencyKey(clientID, key)
+			}
+		}
+	}
+
+	for _, e := range entries {
+		if e.Entry.EventID != "" {
+			_, duplicate, err := h.ingestService.ClaimIdempotencyKey(e.ClientID, e.Entry.EventID, e.Entry.EventID)
+			if err != nil {
+				release()
+				return 0, 0, err
+			}
+			if duplicate {
+				duplicates++
+				continue
+			}
+			claimed[e.ClientID] = append(claimed[e.ClientID], e.Entry.EventID)
+		}
+
+		hit, store := h.buildEntryHit(e.ClientID, "", e.Entry, e.Received)
+		hit.IdempotencyKey = e.Entry.EventID
+		hits = append(hits, hit)
+		if store {
+			stored = append(stored, hit)
+		} else if hit.IdempotencyKey != "" {
+			sampled = append(sampled, hit)
+		}
+	}
+
+	if _, err := h.ingestService.InsertBatch(stored); err != nil {
+		release()
+		return 0, 0, err
+	}
+	if len(sampled) > 0 {
+		h.ingestService.CommitIdempotencyKeys(sampled...)
+	}
+
+	clientHits := make(map[string]int)
+	dailyCounts := make(map[string]map[string]int64)
+	for _, hit := range hits {
+		clientHits[hit.ClientID]++
+		if dailyCounts[hit.ClientID] == nil {
+			dailyCounts[hit.ClientID] = make(map[string]int64)
+		}
+		dailyCounts[hit.ClientID][hit.Timestamp.Format("2006-01-02")]++
+	}
+	h.leaderboards.Record(stored...)
+	for clientID, counts := range dailyCounts {
+		h.incrementCounters(clientID, counts)
+		h.cache.PublishUpdate(clientID)
+		if h.wsHandler != nil {
+			h.wsHandler.BroadcastUpdate(clientID, map[string]interface{}{
+				"batch_size": clientHits[clientID],
+				"timestamp":  time.Now().Unix(),
+			})
+		}
+	}
+	return len(hits), duplicates, nil
+}
+
+// incrementCounters adds per-day hit counts to the daily and total cache counters
+func (h *ClientHandler) incrementCounters(clientID string, dailyCounts map[string]int64) {
+	var total int64
+	for date, count := range dailyCounts {
+		h.cache.Increment(fmt.Sprintf("counter:daily:%s:%s", clientID, date), count)
+		total += count
+	}
+	if total > 0 {
+		h.cache.Increment(fmt.Sprintf("counter:total:%s", clientID), total)
+	}
+}
+
+// validateBatchEntry returns an error message for an invalid entry, or an empty string
+func validateBatchEntry(entry LogBatchEntry, now time.Time, backfill bool) string {
+	if entry.Endpoint == "" {
+		return "endpoint is required"
+	}
+	if len(entry.Endpoint) > 500 {
+		return "endpoint exceeds 500 characters"
+	}
+	if entry.IPAddress != "" && net.ParseIP(entry.IPAddress) == nil {
+		return "ip_address is not a valid IP"
+	}
+	if backfill && entry.Timestamp == nil {
+		return "timestamp is required for backfill"
+	}
+	if errMsg := validateTimestamp(entry.Timestamp, now, backfill); errMsg != "" {
+		return errMsg
+	}
+	if len(entry.EventID) > 64 {
+		return "event_id exceeds 64 characters"
+	}
+	return validateHitDetails(entry.HitDetails)
+}
+
+// validateTimestamp checks a client-supplied timestamp against the live skew
+// window, or against the maximum backfill age in backfill mode
+func validateTimestamp(ts *time.Time, now time.Time, backfill bool) string {
+	if ts == nil {
+		return ""
+	}
+	if ts.IsZero() {
+		return "timestamp is invalid"
+	}
+
+	cfg := configs.AppConfig
+	if ts.After(now.Add(cfg.MaxFutureSkew)) {
+		return "timestamp is too far in the future"
+	}
+
+	if backfill {
+		if ts.Before(now.Add(-cfg.BackfillMaxAge)) {
+			return "timestamp is older than the maximum backfill age"
+		}
+		return ""
+	}
+
+	if ts.Before(now.Add(-cfg.MaxPastSkew)) {
+		return "timestamp is outside the live ingestion window, use /api/logs/backfill"
+	}
+	return ""
+}
+
+// validateHitDetails checks the optional request details shared by all ingestion paths
+func validateHitDetails(d HitDetails) string {
+	if len(d.Method) > 10 {
+		return "method exceeds 10 characters"
+	}
+	if d.StatusCode != nil && (*d.StatusCode < 100 || *d.StatusCode > 599) {
+		return "status_code must be between 100 and 599"
+	}
+	if len(d.UserID) > 128 {
+		return "user_id exceeds 128 characters"
+	}
+	return validateLabels(d.Labels)
+}
+
+// applyTo copies the optional request details onto a hit
+func (d HitDetails) applyTo(hit *models.APILogs) {
+	hit.Method = strings.ToUpper(d.Method)
+	hit.StatusCode = d.StatusCode
+	hit.LatencyMs = d.LatencyMs
+	hit.RequestBytes = d.RequestBytes
+	hit.ResponseBytes = d.ResponseBytes
+	hit.UserAgent = d.UserAgent
+	if len(hit.UserAgent) > 512 {
+		hit.UserAgent = hit.UserAgent[:512]
+	}
+	hit.UserID = d.UserID
+
+	if len(d.Labels) > 0 {
+		hit.Labels = make(map[string]string, len(d.Labels))
+		for key, value := range d.Labels {
+			hit.Labels[key] = value
+		}
+	}
+}
+
+// hitBroadcastData builds the WebSocket usage_update payload for a hit
+func hitBroadcastData(hit models.APILogs) map[string]interface{} {
+	data := map[string]interface{}{
+		"endpoint":   hit.Endpoint,
+		"ip_address": hit.IPAddress,
+		"timestamp":  hit.Timestamp.Unix(),
+	}
+	if hit.EndpointTemplate != "" {
+		data["endpoint_template"] = hit.EndpointTemplate
+	}
+	if hit.Method != "" {
+		data["method"] = hit.Method
+	}
+	if hit.StatusCode != nil {
+		data["status_code"] = *hit.StatusCode
+	}
+	if hit.LatencyMs != nil {
+		data["latency_ms"] = *hit.LatencyMs
+	}
+	if hit.RequestBytes != nil {
+		data["request_bytes"] = *hit.RequestBytes
+	}
+	if hit.ResponseBytes != nil {
+		data["response_bytes"] = *hit.ResponseBytes
+	}
+	if hit.UserAgent != "" {
+		data["user_agent"] = hit.UserAgent
+	}
+	if hit.UserID != "" {
+		data["user_id"] = hit.UserID
+	}
+	if len(hit.Labels) > 0 {
+		data["labels"] = hit.Labels
+	}
+	return data
+}
+
+// rejectIngest answers with 503 and a Retry-After hint when the pipeline cannot take more hits
+func (h *ClientHandler) rejectIngest(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrQueueFull) {
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{Error: "Ingestion queue is full, retry later"})
+		return
+	}
+
+	c.Header("Retry-After", "5")
+	c.JSON(http.StatusServiceUnavailable, ErrorResponse{Error: "Ingestion is unavailable"})
+}
+
+type UsageRecord struct {
+	Date         string `json:"date"`
+	RequestCount int64  `json:"request_count"`
+}
+
+// GetDailyUsage returns the client's usage over time
+// @Summary Get daily usage
+// @Description Get the client's total requests per hour, day, week or month, by default per day for the last 7 days, optionally filtered and grouped by custom labels. Buckets follow the requested time zone; weeks start on Monday. A range holds at most 168 hours, 366 days, 104 weeks or 60 months.
+// @Tags usage
+// @Produce json
+// @Param from query string false "Start, YYYY-MM-DD or RFC 3339, widened to a bucket start"
+// @Param to query string false "End, YYYY-MM-DD (inclusive) or RFC 3339, widened to a bucket end; default the end of the current bucket"
+// @Param tz query string false "IANA time zone, default the server's"
+// @Param granularity query string false "hour, day (default), week or month"
+// @Param label query []string false "Label filter as key:value, repeatable" collectionFormat(multi)
+// @Param group_by query string false "Label key to split usage by"
+// @Security ApiKeyAuth
+// @Success 200 {object} DailyUsageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/usage/daily [get]
+func (h *ClientHandler) GetDailyUsage(c *gin.Context) {
+	filter, errMsg := parseUsageFilter(c)
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: errMsg})
+		return
+	}
+	usageRange, errMsg := parseUsageRange(c.Query("from"), c.Query("to"), c.Query("tz"), c.Query("granularity"))
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: errMsg})
+		return
+	}
+
+	response, err := h.dailyUsage(c.GetString("client_id"), filter, usageRange)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to fetch usage data"})
+		return
+	}
 
 	c.JSON(http.StatusOK, response)
 }
 
-func (h *ClientHandler) fillMissingDays(clientID string, data []UsageRecord, startDate, endDate time.Time) DailyUsageResponse {
-	result := DailyUsageResponse{
-		ClientID:  clientID,
-		StartDate: startDate.Format("2006-01-02"),
-		EndDate:   endDate.Format("2006-01-02"),
-		Usage:     make([]DayUsage, 0),
+// dailyUsage returns the client's usage over a range, served from cache when possible
+func (h *ClientHandler) dailyUsage(clientID string, filter UsageFilter, r usageRange) (DailyUsageResponse, error) {
+	// Try cache first
+	cacheKey := h.cache.UsageKey(clientID, "daily", r.cacheKey()+filter.cacheSuffix())
+	var cachedResponse DailyUsageResponse
+	if found, err := h.cache.Get(cacheKey, &cachedResponse); found && err == nil {
+		return cachedResponse, nil
 	}
 
-	// Create a map for easy lookup
-	dataMap := make(map[string]int64)
-	for _, item := range data {
-		dataMap[item.Date] = item.RequestCount
+	// Labels are not part of the daily aggregates, so count labelled hits directly
+	if !filter.IsZero() {
+		response, err := h.labelDailyUsage(clientID, filter, r)
+		if err != nil {
+			return DailyUsageResponse{}, err
+		}
+		h.cache.Set(cacheKey, response, configs.AppConfig.CacheTTL)
+		return response, nil
 	}
 
-	// Generate all dates in range
-	for d := startDate; !d.After(endDate); d = d.AddDate(0, 0, 1) {
-		dateStr := d.Format("2006-01-02")
-		count := dataMap[dateStr]
-		result.Usage = append(result.Usage, DayUsage{
-			Date:         dateStr,
-			RequestCount: count,
-		})
+	// Query from database (using read replica)
+	readDB := database.GetDBManager().GetReadDB()
+	counts := make([]int64, len(r.starts))
+
+	// Completed days come from the daily aggregates when buckets are made of
+	// whole server-local days; the rest is counted from the logs
+	since := r.start()
+	if r.alignedWithDays() {
+		now := time.Now()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+		until := r.end
+		if today.Before(until) {
+			until = today
+		}
+
+		if since.Before(until) {
+			var days []UsageRecord
+			err := readDB.Model(&models.DailyUsage{}).
+				Select("DATE_FORMAT(date, '%Y-%m-%d') as date, SUM(request_count) as request_count").
+				Where("client_id = ? AND date >= ? AND date < ?",
+					clientID,
+					since.In(time.Local).Format("2006-01-02"),
+					until.In(time.Local).Format("2006-01-02")).
+				Group("date").
+				Scan(&days).Error
+			if err != nil {
+				return DailyUsageResponse{}, fmt.Errorf("fetch usage data: %w", err)
+			}
+
+			for _, day := range days {
+				if t, err := time.ParseInLocation("2006-01-02", day.Date, time.Local); err == nil {
+					r.add(counts, t, day.RequestCount)
+				}
+			}
+			since = until
+		}
 	}
 
-	return result
+	if since.Before(r.end) {
+		var slots []slotCount
+		err := readDB.Model(&models.APILogs{}).
+			Select(r.slotExpr("timestamp")+" AS slot, CAST(ROUND(SUM(sample_weight)) AS SIGNED) AS request_count").
+			Where("client_id = ? AND timestamp >= ? AND timestamp < ?", clientID, since, r.end).
+			Group("slot").
+			Scan(&slots).Error
+		if err != nil {
+			return DailyUsageResponse{}, fmt.Errorf("fetch recent usage: %w", err)
+		}
+		r.addSlots(counts, slots)
+	}
+
+	response := r.response(clientID, counts)
+
+	// Cache the result
+	h.cache.Set(cacheKey, response, configs.AppConfig.CacheTTL)
+
+	return response, nil
 }
 
-// GetTopClients returns top 3 clients with highest requests in last 24 hours
+// GetTopClients returns the busiest clients
 // @Summary Get top clients
-// @Description Get top 3 clients with highest total requests in last 24 hours
+// @Description Clients ranked by requests, distinct IP addresses or errors (4xx/5xx) over a window ending now, by default the top 3 by requests in the last 24 hours. Request leaderboards of whole-minute windows up to 24h are merged from real-time per-minute counters. Other metrics of the 1h, 24h, 7d and 30d windows are precomputed and refreshed every 1, 5, 30 and 60 minutes; other custom windows and label filters are computed on demand.
 // @Tags usage
 // @Produce json
+// @Param limit query int false "Number of clients (default 3, max 100)"
+// @Param window query string false "1h, 24h (default), 7d, 30d or a custom duration such as 90m, 6h or 14d (max 90d)"
+// @Param metric query string false "requests (default), ips or errors"
+// @Param label query []string false "Label filter as key:value, repeatable" collectionFormat(multi)
 // @Security ApiKeyAuth
 // @Success 200 {object} TopClientsResponse
+// @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/usage/top [get]
 func (h *ClientHandler) GetTopClients(c *gin.Context) {
-	// Try cache first with prefetch mechanism
-	cacheKey := "usage:top:last24h"
-	var cachedResponse TopClientsResponse
-
-	if found, err := h.cache.Get(cacheKey, &cachedResponse); found && err == nil {
-		// Check if cache is stale (more than 5 minutes old)
-		if time.Since(cachedResponse.GeneratedAt) < 5*time.Minute {
-			c.JSON(http.StatusOK, cachedResponse)
-			return
-		}
-		// Cache is stale, refresh in background
-		go h.refreshTopClientsCache()
-	} else {
-		// Cache miss, fetch and cache
-		h.refreshTopClientsCache()
+	filter, errMsg := parseUsageFilter(c)
+	if errMsg == "" && filter.GroupBy != "" {
+		errMsg = "group_by is not supported for top clients"
+	}
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: errMsg})
+		return
+	}
+	query, errMsg := parseLeaderboardQuery(c.Query("limit"), c.Query("window"), c.Query("metric"), defaultTopClients, services.LeaderboardMetrics)
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: errMsg})
+		return
 	}
 
-	// Get fresh data
-	response, err := h.fetchTopClients()
+	response, err := h.topClients(filter, query)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to fetch top clients"})
 		return
@@ -339,42 +885,74 @@ func (h *ClientHandler) GetTopClients(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-func (h *ClientHandler) refreshTopClientsCache() {
-	data, err := h.fetchTopClients()
-	if err == nil {
-		h.cache.Set("usage:top:last24h", data, configs.AppConfig.CacheTTL)
+// topClients returns the top clients, from the precomputed leaderboards unless
+// hits are filtered by labels
+func (h *ClientHandler) topClients(filter UsageFilter, query leaderboardQuery) (TopClientsResponse, error) {
+	var (
+		board services.ClientLeaderboard
+		err   error
+	)
+	if len(filter.Labels) == 0 {
+		board, err = h.leaderboards.TopClients(query.window, query.metric)
+	} else {
+		board, err = h.labelTopClients(filter, query)
+	}
+	if err != nil {
+		return TopClientsResponse{}, err
+	}
+
+	clients := board.Clients
+	if len(clients) > query.limit {
+		clients = clients[:query.limit]
+	}
+	if clients == nil {
+		clients = []services.ClientRank{}
 	}
+
+	return TopClientsResponse{
+		Labels:       filter.Labels,
+		Period:       query.window.Period,
+		Window:       query.window.Name,
+		Metric:       query.metric,
+		GeneratedAt:  board.GeneratedAt,
+		TopClients:   clients,
+		TotalClients: len(clients),
+	}, nil
 }
 
-func (h *ClientHandler) fetchTopClients() (TopClientsResponse, error) {
-	twentyFourHoursAgo := time.Now().Add(-24 * time.Hour)
+// labelTopClients ranks clients by labelled hits. Cached leaderboards older than
+// five minutes are served while a fresh one is computed in the background.
+func (h *ClientHandler) labelTopClients(filter UsageFilter, query leaderboardQuery) (services.ClientLeaderboard, error) {
+	cacheKey := fmt.Sprintf("usage:top:%ds%s", int64(query.window.Duration.Seconds()), filter.cacheSuffix())
 
-	var topClients []struct {
-		ClientID     string `json:"client_id"`
-		Name         string `json:"name"`
-		RequestCount int64  `json:"request_count"`
+	var boards map[string]services.ClientLeaderboard
+	if found, err := h.cache.Get(cacheKey, &boards); found && err == nil {
+		if board, ok := boards[query.metric]; ok {
+			if time.Since(board.GeneratedAt) >= 5*time.Minute {
+				go h.refreshLabelTopClients(cacheKey, filter, query.window)
+			}
+			return board, nil
+		}
 	}
 
-	readDB := database.GetDBManager().GetReadDB()
-	err := readDB.Model(&models.APILogs{}).
-		Select("clients.client_id, clients.name, COUNT(api_logs.id) as request_count").
-		Joins("JOIN clients ON clients.client_id = api_logs.client_id").
-		Where("api_logs.timestamp >= ?", twentyFourHoursAgo).
-		Group("clients.client_id, clients.name").
-		Order("request_count DESC").
-		Limit(3).
-		Scan(&topClients).Error
-
+	boards, err := h.refreshLabelTopClients(cacheKey, filter, query.window)
 	if err != nil {
-		return TopClientsResponse{}, err
+		return services.ClientLeaderboard{}, err
 	}
+	return boards[query.metric], nil
+}
 
-	return TopClientsResponse{
-		Period:       "last_24_hours",
-		GeneratedAt:  time.Now(),
-		TopClients:   topClients,
-		TotalClients: len(topClients),
-	}, nil
+func (h *ClientHandler) refreshLabelTopClients(cacheKey string, filter UsageFilter, window services.Window) (map[string]services.ClientLeaderboard, error) {
+	now := time.Now()
+	query := labelQuery(database.GetDBManager().GetReadDB(), filter, now.Add(-window.Duration), now.Add(configs.AppConfig.MaxFutureSkew)).
+		Joins("JOIN api_logs ON api_logs.id = l0.log_id AND api_logs.timestamp = l0.timestamp")
+
+	boards, err := services.RankClients(query, window)
+	if err != nil {
+		return nil, err
+	}
+	h.cache.Set(cacheKey, boards, configs.AppConfig.CacheTTL)
+	return boards, nil
 }
 
 // Request/Response structures
@@ -382,6 +960,12 @@ type RegisterRequest struct {
 	Name        string `json:"name" binding:"required"`
 	Email       string `json:"email" binding:"required,email"`
 	IPWhitelist string `json:"ip_whitelist"`
+
+	// hashed (default) or raw
+	UserIDStorage string `json:"user_id_storage,omitempty"`
+
+	// full (default), truncated, hashed or none
+	IPPrivacy string `json:"ip_privacy,omitempty"`
 }
 
 type RegisterResponse struct {
@@ -394,7 +978,53 @@ type RegisterResponse struct {
 }
 
 type LogRequest struct {
-	Endpoint string `json:"endpoint" binding:"required"`
+	EventID   string     `json:"event_id,omitempty"`
+	Endpoint  string     `json:"endpoint" binding:"required"`
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+	HitDetails
+}
+
+// HitDetails holds the optional request details a client can report with a hit
+type HitDetails struct {
+	Method        string  `json:"method,omitempty"`
+	StatusCode    *uint16 `json:"status_code,omitempty"`
+	LatencyMs     *uint32 `json:"latency_ms,omitempty"`
+	RequestBytes  *uint64 `json:"request_bytes,omitempty"`
+	ResponseBytes *uint64 `json:"response_bytes,omitempty"`
+	UserAgent     string  `json:"user_agent,omitempty"`
+
+	// Opaque ID of the client's own end user, stored hashed or raw per client setting
+	UserID string `json:"user_id,omitempty"`
+
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type LogBatchEntry struct {
+	EventID   string     `json:"event_id,omitempty"`
+	Endpoint  string     `json:"endpoint"`
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+	IPAddress string     `json:"ip_address,omitempty"`
+	HitDetails
+}
+
+type LogBatchRequest struct {
+	Entries []LogBatchEntry `json:"entries" binding:"required"`
+}
+
+type LogBatchResult struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	HitID  string `json:"hit_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type LogBatchResponse struct {
+	Accepted          int              `json:"accepted"`
+	Duplicates        int              `json:"duplicates"`
+	Rejected          int              `json:"rejected"`
+	Results           []LogBatchResult `json:"results"`
+	ReaggregatedDates []string         `json:"reaggregated_dates,omitempty"`
+	Spooled           bool             `json:"spooled,omitempty"`
 }
 
 type SuccessResponse struct {
@@ -407,10 +1037,23 @@ type ErrorResponse struct {
 }
 
 type DailyUsageResponse struct {
-	ClientID  string     `json:"client_id"`
-	StartDate string     `json:"start_date"`
-	EndDate   string     `json:"end_date"`
-	Usage     []DayUsage `json:"usage"`
+	ClientID  string `json:"client_id"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+
+	// Exact range covered and how it is bucketed
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	Timezone    string    `json:"tz"`
+	Granularity string    `json:"granularity"`
+
+	// One entry per bucket; date is YYYY-MM-DD, YYYY-MM for months and an RFC 3339 time for hours
+	Usage []DayUsage `json:"usage"`
+
+	// Set when the usage was filtered or grouped by custom labels
+	Labels  map[string]string `json:"labels,omitempty"`
+	GroupBy string            `json:"group_by,omitempty"`
+	Groups  []LabelUsage      `json:"groups,omitempty"`
 }
 
 type DayUsage struct {
@@ -419,12 +1062,11 @@ type DayUsage struct {
 }
 
 type TopClientsResponse struct {
-	Period      string    `json:"period"`
-	GeneratedAt time.Time `json:"generated_at"`
-	TopClients  []struct {
-		ClientID     string `json:"client_id"`
-		Name         string `json:"name"`
-		RequestCount int64  `json:"request_count"`
-	} `json:"top_clients"`
-	TotalClients int `json:"total_clients"`
+	Labels       map[string]string     `json:"labels,omitempty"`
+	Period       string                `json:"period"`
+	Window       string                `json:"window"`
+	Metric       string                `json:"metric"`
+	GeneratedAt  time.Time             `json:"generated_at"`
+	TopClients   []services.ClientRank `json:"top_clients"`
+	TotalClients int                   `json:"total_clients"`
 }
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"user-activity-tracker/internal/services"
+)
+
+func TestBuildEntryHitUsesServerTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	withLocal(t, berlin)
+
+	h := &ClientHandler{ingestService: &services.IngestService{}}
+	sent := time.Date(2024, 5, 1, 23, 30, 0, 0, time.FixedZone("", -8*60*60))
+	hit, store := h.buildEntryHit("client", "198.51.100.1", LogBatchEntry{Endpoint: "/a", Timestamp: &sent}, time.Now())
+	if !store {
+		t.Fatal("hit was not stored")
+	}
+
+	if !hit.Timestamp.Equal(sent) || hit.Timestamp.Location() != berlin {
+		t.Errorf("timestamp = %v, want %v in server time", hit.Timestamp, sent)
+	}
+	// 23:30 at -08:00 is 09:30 the next day in Berlin, where MySQL stores it
+	if day := hit.Timestamp.Format("2006-01-02"); day != "2024-05-02" {
+		t.Errorf("day = %s, want 2024-05-02", day)
+	}
+}
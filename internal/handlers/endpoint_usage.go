@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"user-activity-tracker/configs"
+	"user-activity-tracker/internal/database"
+	"user-activity-tracker/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// endpointGroups maps the by parameter onto the expression hits are grouped by;
+// hits recorded before normalization fall back to their raw endpoint
+var endpointGroups = map[string]string{
+	"template": services.EndpointTemplateColumn,
+	"endpoint": "endpoint",
+}
+
+// endpointSortKeys maps the sort parameter onto a selected column. Endpoints
+// without status or latency data sort as -1.
+var endpointSortKeys = map[string]string{
+	"requests":       "requests",
+	"errors":         "errors",
+	"error_rate":     "COALESCE(error_rate, -1)",
+	"avg_latency_ms": "COALESCE(avg_latency_ms, -1)",
+	"max_latency_ms": "COALESCE(max_latency_ms, -1)",
+	"endpoint":       "endpoint",
+}
+
+// endpointColumns are the aggregates of a breakdown row. Rates and averages are
+// rounded in SQL so cursor values compare exactly.
+const endpointColumns = "CAST(ROUND(SUM(sample_weight)) AS SIGNED) AS requests, " +
+	"CAST(ROUND(SUM(CASE WHEN status_code IS NOT NULL THEN sample_weight ELSE 0 END)) AS SIGNED) AS with_status, " +
+	"CAST(ROUND(SUM(CASE WHEN status_code >= 400 THEN sample_weight ELSE 0 END)) AS SIGNED) AS errors, " +
+	"CAST(ROUND(SUM(CASE WHEN status_code >= 500 THEN sample_weight ELSE 0 END)) AS SIGNED) AS server_errors, " +
+	"ROUND(SUM(CASE WHEN status_code >= 400 THEN sample_weight ELSE 0 END) / " +
+	"NULLIF(SUM(CASE WHEN status_code IS NOT NULL THEN sample_weight ELSE 0 END), 0), 4) AS error_rate, " +
+	"ROUND(SUM(latency_ms * sample_weight) / " +
+	"NULLIF(SUM(CASE WHEN latency_ms IS NOT NULL THEN sample_weight ELSE 0 END), 0), 1) AS avg_latency_ms, " +
+	"MAX(latency_ms) AS max_latency_ms, MAX(timestamp) AS last_seen"
+
+type EndpointUsage struct {
+	Endpoint string  `json:"endpoint"`
+	Requests int64   `json:"requests"`
+	Share    float64 `json:"share"`
+
+	// Only set when hits of the endpoint reported a status code; errors are 4xx and 5xx
+	Errors       *int64   `json:"errors,omitempty"`
+	ServerErrors *int64   `json:"server_errors,omitempty"`
+	ErrorRate    *float64 `json:"error_rate,omitempty"`
+
+	// Only set when hits of the endpoint reported a latency
+	AvgLatencyMs *float64 `json:"avg_latency_ms,omitempty"`
+	MaxLatencyMs *uint32  `json:"max_latency_ms,omitempty"`
+
+	LastSeen time.Time `json:"last_seen"`
+}
+
+type EndpointUsageResponse struct {
+	ClientID      string          `json:"client_id"`
+	StartDate     string          `json:"start_date"`
+	EndDate       string          `json:"end_date"`
+	By            string          `json:"by"`
+	Sort          string          `json:"sort"`
+	Order         string          `json:"order"`
+	TotalRequests int64           `json:"total_requests"`
+	Endpoints     []EndpointUsage `json:"endpoints"`
+	NextCursor    string          `json:"next_cursor,omitempty"`
+}
+
+// endpointCursor is the position after the last row of a page
+type endpointCursor struct {
+	Sort     string `json:"s"`
+	Value    string `json:"v"`
+	Endpoint string `json:"e"`
+
+	value float64 // Value parsed, unless sorting by endpoint
+}
+
+// GetEndpointUsage returns how the client's traffic is spread over endpoints
+// @Summary Get per-endpoint usage
+// @Description Requests per route template (or raw endpoint) over a date range (default the last 7 days), with error counts and rate when hits report status codes and average/maximum latency when they report latencies. Pass next_cursor back as cursor for the next page.
+// @Tags usage
+// @Produce json
+// @Param by query string false "template (default) or endpoint"
+// @Param sort query string false "requests (default), errors, error_rate, avg_latency_ms, max_latency_ms or endpoint"
+// @Param order query string false "desc (default, asc for endpoint) or asc"
+// @Param start_date query string false "First day, YYYY-MM-DD"
+// @Param end_date query string false "Last day, YYYY-MM-DD"
+// @Param limit query int false "Maximum rows (default 50, max 500)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Security ApiKeyAuth
+// @Success 200 {object} EndpointUsageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/usage/endpoints [get]
+func (h *ClientHandler) GetEndpointUsage(c *gin.Context) {
+	by := c.DefaultQuery("by", "template")
+	if _, ok := endpointGroups[by]; !ok {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "by must be one of: template, endpoint"})
+		return
+	}
+	sort := c.DefaultQuery("sort", "requests")
+	if _, ok := endpointSortKeys[sort]; !ok {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "sort must be one of: requests, errors, error_rate, avg_latency_ms, max_latency_ms, endpoint"})
+		return
+	}
+	order := c.Query("order")
+	if order == "" {
+		order = "desc"
+		if sort == "endpoint" {
+			order = "asc"
+		}
+	}
+	if order != "asc" && order != "desc" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "order must be asc or desc"})
+		return
+	}
+
+	startDate, endDate, errMsg := parseDateRange(c)
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: errMsg})
+		return
+	}
+	limit, errMsg := parseBreakdownLimit(c)
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: errMsg})
+		return
+	}
+
+	var cursor *endpointCursor
+	if value := c.Query("cursor"); value != "" {
+		var ok bool
+		cursor, ok = parseEndpointCursor(value, sort, order)
+		if !ok {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "cursor is invalid for this sort and order"})
+			return
+		}
+	}
+
+	clientID := c.GetString("client_id")
+	cacheKey := fmt.Sprintf("usage:endpoints:%s:%s:%s:%s:%s:%s:%d:%s", clientID, by, sort, order,
+		startDate.Format("2006-01-02"), endDate.Format("2006-01-02"), limit, c.Query("cursor"))
+
+	var response EndpointUsageResponse
+	if found, err := h.cache.Get(cacheKey, &response); found && err == nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response = EndpointUsageResponse{
+		ClientID:  clientID,
+		StartDate: startDate.Format("2006-01-02"),
+		EndDate:   endDate.Format("2006-01-02"),
+		By:        by,
+		Sort:      sort,
+		Order:     order,
+		Endpoints: []EndpointUsage{},
+	}
+
+	readDB := database.GetDBManager().GetReadDB()
+	query := readDB.Table("api_logs").
+		Where("client_id = ? AND timestamp >= ? AND timestamp < ?", clientID, startDate, endDate.AddDate(0, 0, 1))
+
+	err := query.Session(&gorm.Session{}).
+		Select("COALESCE(CAST(ROUND(SUM(sample_weight)) AS SIGNED), 0)").
+		Scan(&response.TotalRequests).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to fetch usage data"})
+		return
+	}
+
+	// One extra row tells whether there is another page
+	var rows []endpointRow
+	err = endpointPageQuery(query, by, sort, order, cursor).Limit(limit + 1).Scan(&rows).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to fetch usage data"})
+		return
+	}
+	response.Endpoints, response.NextCursor = endpointPage(rows, limit, response.TotalRequests, sort, order)
+
+	h.cache.Set(cacheKey, response, configs.AppConfig.CacheTTL)
+	c.JSON(http.StatusOK, response)
+}
+
+// endpointRow is a breakdown row as selected by endpointColumns
+type endpointRow struct {
+	EndpointUsage
+	WithStatus int64
+}
+
+// endpointPageQuery groups and orders the hits of query into breakdown rows
+// and skips the rows up to cursor
+func endpointPageQuery(query *gorm.DB, by, sort, order string, cursor *endpointCursor) *gorm.DB {
+	sortKey := endpointSortKeys[sort]
+
+	// Ties are broken by endpoint so pages never overlap
+	query = query.Select(endpointGroups[by] + " AS endpoint, " + endpointColumns).Group(endpointGroups[by])
+	if sort == "endpoint" {
+		query = query.Order("endpoint " + order)
+		if cursor != nil {
+			query = query.Having(fmt.Sprintf("endpoint %s ?", cursorComparison(order)), cursor.Endpoint)
+		}
+		return query
+	}
+
+	query = query.Order(sortKey + " " + order).Order("endpoint")
+	if cursor != nil {
+		query = query.Having(fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND endpoint > ?))", sortKey, cursorComparison(order)),
+			cursor.value, cursor.value, cursor.Endpoint)
+	}
+	return query
+}
+
+// endpointPage turns up to limit+1 rows into a page of usage and the cursor of
+// the next page, which is empty when the rows fit in the page
+func endpointPage(rows []endpointRow, limit int, totalRequests int64, sort, order string) ([]EndpointUsage, string) {
+	endpoints := []EndpointUsage{}
+	for i, row := range rows {
+		if i == limit {
+			last := endpoints[limit-1]
+			return endpoints, encodeEndpointCursor(endpointCursor{
+				Sort:     sort + ":" + order,
+				Value:    endpointSortValue(last, sort),
+				Endpoint: last.Endpoint,
+			})
+		}
+
+		usage := row.EndpointUsage
+		if row.WithStatus == 0 {
+			usage.Errors, usage.ServerErrors, usage.ErrorRate = nil, nil, nil
+		}
+		if totalRequests > 0 {
+			usage.Share = float64(usage.Requests) / float64(totalRequests)
+		}
+		endpoints = append(endpoints, usage)
+	}
+	return endpoints, ""
+}
+
+// endpointSortValue returns the value a row is sorted by, as its sort key selects it
+func endpointSortValue(row EndpointUsage, sort string) string {
+	value := -1.0
+	switch sort {
+	case "requests":
+		value = float64(row.Requests)
+	case "errors":
+		value = 0
+		if row.Errors != nil {
+			value = float64(*row.Errors)
+		}
+	case "error_rate":
+		if row.ErrorRate != nil {
+			value = *row.ErrorRate
+		}
+	case "avg_latency_ms":
+		if row.AvgLatencyMs != nil {
+			value = *row.AvgLatencyMs
+		}
+	case "max_latency_ms":
+		if row.MaxLatencyMs != nil {
+			value = float64(*row.MaxLatencyMs)
+		}
+	case "endpoint":
+		return ""
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// cursorComparison is the operator selecting rows after the cursor
+func cursorComparison(order string) string {
+	if order == "asc" {
+		return ">"
+	}
+	return "<"
+}
+
+func encodeEndpointCursor(cursor endpointCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// parseEndpointCursor decodes a cursor issued for the given sort and order
+func parseEndpointCursor(value, sort, order string) (*endpointCursor, bool) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, false
+	}
+	var cursor endpointCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort != sort+":"+order {
+		return nil, false
+	}
+	if sort != "endpoint" {
+		cursor.value, err = strconv.ParseFloat(cursor.Value, 64)
+		if err != nil || math.IsNaN(cursor.value) || math.IsInf(cursor.value, 0) {
+			return nil, false
+		}
+	}
+	return &cursor, true
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func int64p(v int64) *int64       { return &v }
+func float64p(v float64) *float64 { return &v }
+func uint32p(v uint32) *uint32    { return &v }
+
+func TestEndpointCursorEncoding(t *testing.T) {
+	cursor := endpointCursor{Sort: "error_rate:desc", Value: "0.125", Endpoint: "/users/:id?x=ä"}
+	encoded := encodeEndpointCursor(cursor)
+	if strings.ContainsAny(encoded, "+/=") {
+		t.Errorf("cursor %q is not URL safe", encoded)
+	}
+
+	parsed, ok := parseEndpointCursor(encoded, "error_rate", "desc")
+	if !ok {
+		t.Fatal("cursor was rejected")
+	}
+	if parsed.Sort != cursor.Sort || parsed.Value != cursor.Value || parsed.Endpoint != cursor.Endpoint || parsed.value != 0.125 {
+		t.Errorf("parsed %+v, want %+v", *parsed, cursor)
+	}
+
+	endpointOnly := encodeEndpointCursor(endpointCursor{Sort: "endpoint:asc", Endpoint: "/b"})
+	if parsed, ok := parseEndpointCursor(endpointOnly, "endpoint", "asc"); !ok || parsed.Endpoint != "/b" {
+		t.Errorf("endpoint cursor = %+v, %v", parsed, ok)
+	}
+
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	invalid := []struct {
+		name, value, sort, order string
+	}{
+		{"other order", encoded, "error_rate", "asc"},
+		{"other sort", encoded, "requests", "desc"},
+		{"not base64", "not*base64", "error_rate", "desc"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"s":"requests:desc","v":"1","e":"/a"}`)), "requests", "desc"},
+		{"not JSON", encode("requests:desc"), "requests", "desc"},
+		{"value not a number", encode(`{"s":"requests:desc","v":"many","e":"/a"}`), "requests", "desc"},
+		{"value not finite", encode(`{"s":"requests:desc","v":"NaN","e":"/a"}`), "requests", "desc"},
+		{"missing value", encode(`{"s":"requests:desc","e":"/a"}`), "requests", "desc"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := parseEndpointCursor(tt.value, tt.sort, tt.order); ok {
+				t.Errorf("cursor %q was accepted for %s %s", tt.value, tt.sort, tt.order)
+			}
+		})
+	}
+}
+
+func TestEndpointSortValue(t *testing.T) {
+	full := EndpointUsage{
+		Endpoint:     "/a",
+		Requests:     120,
+		Errors:       int64p(3),
+		ErrorRate:    float64p(0.025),
+		AvgLatencyMs: float64p(12.5),
+		MaxLatencyMs: uint32p(900),
+	}
+	bare := EndpointUsage{Endpoint: "/b", Requests: 7}
+
+	tests := []struct {
+		sort       string
+		full, bare string
+	}{
+		{"requests", "120", "7"},
+		{"errors", "3", "0"},
+		{"error_rate", "0.025", "-1"},
+		{"avg_latency_ms", "12.5", "-1"},
+		{"max_latency_ms", "900", "-1"},
+		{"endpoint", "", ""},
+	}
+	for _, tt := range tests {
+		if got := endpointSortValue(full, tt.sort); got != tt.full {
+			t.Errorf("%s of a row with all data = %q, want %q", tt.sort, got, tt.full)
+		}
+		if got := endpointSortValue(bare, tt.sort); got != tt.bare {
+			t.Errorf("%s of a row without status or latency = %q, want %q", tt.sort, got, tt.bare)
+		}
+	}
+}
+
+func TestEndpointPage(t *testing.T) {
+	rows := []endpointRow{
+		{EndpointUsage: EndpointUsage{Endpoint: "/a", Requests: 50, Errors: int64p(5), ErrorRate: float64p(0.1)}, WithStatus: 50},
+		{EndpointUsage: EndpointUsage{Endpoint: "/b", Requests: 30, Errors: int64p(0), ServerErrors: int64p(0)}},
+		{EndpointUsage: EndpointUsage{Endpoint: "/c", Requests: 30}},
+	}
+
+	page, next := endpointPage(rows, 2, 200, "requests", "desc")
+	if len(page) != 2 || page[0].Endpoint != "/a" || page[1].Endpoint != "/b" {
+		t.Fatalf("page = %+v", page)
+	}
+	if page[0].Share != 0.25 || page[1].Share != 0.15 {
+		t.Errorf("shares = %v, %v", page[0].Share, page[1].Share)
+	}
+	if page[0].Errors == nil || page[1].Errors != nil || page[1].ServerErrors != nil {
+		t.Error("errors should only be set for endpoints with status codes")
+	}
+
+	cursor, ok := parseEndpointCursor(next, "requests", "desc")
+	if !ok {
+		t.Fatalf("next cursor %q is invalid", next)
+	}
+	if cursor.value != 30 || cursor.Endpoint != "/b" {
+		t.Errorf("next cursor = %+v, want after /b at 30 requests", *cursor)
+	}
+
+	page, next = endpointPage(rows[2:], 2, 200, "requests", "desc")
+	if len(page) != 1 || next != "" {
+		t.Errorf("last page = %+v, next cursor %q", page, next)
+	}
+
+	page, next = endpointPage(nil, 2, 0, "requests", "desc")
+	if page == nil || len(page) != 0 || next != "" {
+		t.Errorf("empty page = %#v, next cursor %q", page, next)
+	}
+}
+
+func TestEndpointPageQuery(t *testing.T) {
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "test@tcp(127.0.0.1:1)/test", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, by, sort, order string
+		cursor                *endpointCursor
+		groupBy, having       string
+		orderBy               string
+		vars                  []interface{}
+	}{
+		{
+			name: "first page", by: "template", sort: "requests", order: "desc",
+			groupBy: "GROUP BY COALESCE(NULLIF(endpoint_template, ''), endpoint)",
+			orderBy: "ORDER BY requests desc,endpoint",
+		},
+		{
+			name: "ties continue by endpoint", by: "template", sort: "requests", order: "desc",
+			groupBy: "GROUP BY COALESCE(NULLIF(endpoint_template, ''), endpoint)",
+			cursor:  &endpointCursor{Endpoint: "/b", value: 30},
+			having:  "HAVING (requests < ? OR (requests = ? AND endpoint > ?))",
+			orderBy: "ORDER BY requests desc,endpoint",
+			vars:    []interface{}{30.0, 30.0, "/b"},
+		},
+		{
+			name: "ascending by a nullable key", by: "endpoint", sort: "avg_latency_ms", order: "asc",
+			groupBy: "GROUP BY `endpoint`",
+			cursor:  &endpointCursor{Endpoint: "/a", value: -1},
+			having:  "HAVING (COALESCE(avg_latency_ms, -1) > ? OR (COALESCE(avg_latency_ms, -1) = ? AND endpoint > ?))",
+			orderBy: "ORDER BY COALESCE(avg_latency_ms, -1) asc,endpoint",
+			vars:    []interface{}{-1.0, -1.0, "/a"},
+		},
+		{
+			name: "by endpoint", by: "template", sort: "endpoint", order: "desc",
+			groupBy: "GROUP BY COALESCE(NULLIF(endpoint_template, ''), endpoint)",
+			cursor:  &endpointCursor{Endpoint: "/m"},
+			having:  "HAVING endpoint < ?",
+			orderBy: "ORDER BY endpoint desc",
+			vars:    []interface{}{"/m"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rows []endpointRow
+			stmt := endpointPageQuery(db.Table("api_logs"), tt.by, tt.sort, tt.order, tt.cursor).Find(&rows).Statement
+			sql := stmt.SQL.String()
+
+			if !strings.HasPrefix(sql, "SELECT "+endpointGroups[tt.by]+" AS endpoint, ") || !strings.Contains(sql, tt.groupBy+" ") {
+				t.Errorf("SQL = %s, want it to select and %s", sql, tt.groupBy)
+			}
+			if tt.having == "" && strings.Contains(sql, "HAVING") || !strings.Contains(sql, tt.having) {
+				t.Errorf("SQL = %s, want %q", sql, tt.having)
+			}
+			if !strings.HasSuffix(sql, tt.orderBy) {
+				t.Errorf("SQL = %s, want it to end with %q", sql, tt.orderBy)
+			}
+			if len(tt.vars) > 0 && !reflect.DeepEqual(stmt.Vars, tt.vars) {
+				t.Errorf("vars = %#v, want %#v", stmt.Vars, tt.vars)
+			}
+		})
+	}
+}
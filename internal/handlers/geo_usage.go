@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"user-activity-tracker/configs"
+	"user-activity-tracker/internal/database"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxUsageRangeDays bounds date ranges of breakdown queries on raw hits
+	maxUsageRangeDays = 366
+
+	defaultBreakdownLimit = 50
+	maxBreakdownLimit     = 500
+)
+
+// geoDimensions maps the by parameter onto the columns a breakdown groups by
+var geoDimensions = map[string]string{
+	"country": "COALESCE(country, '') AS country",
+	"region":  "COALESCE(country, '') AS country, COALESCE(region, '') AS region",
+	"asn":     "COALESCE(asn, 0) AS asn, MAX(COALESCE(as_org, '')) AS as_org",
+}
+
+// geoGroupColumns holds the expressions of geoDimensions; a lone column
+// ordinal would be quoted as a column name
+var geoGroupColumns = map[string]string{
+	"country": "COALESCE(country, '')",
+	"region":  "COALESCE(country, ''), COALESCE(region, '')",
+	"asn":     "COALESCE(asn, 0)",
+}
+
+type GeoUsage struct {
+	Country  string  `json:"country,omitempty"`
+	Region   string  `json:"region,omitempty"`
+	ASN      uint32  `json:"asn,omitempty" gorm:"column:asn"`
+	ASOrg    string  `json:"as_org,omitempty" gorm:"column:as_org"`
+	Requests int64   `json:"requests"`
+	Share    float64 `json:"share"`
+}
+
+type GeoUsageResponse struct {
+	ClientID      string     `json:"client_id"`
+	StartDate     string     `json:"start_date"`
+	EndDate       string     `json:"end_date"`
+	By            string     `json:"by"`
+	TotalRequests int64      `json:"total_requests"`
+	Breakdown     []GeoUsage `json:"breakdown"`
+}
+
+// GetGeoUsage returns where the client's traffic comes from
+// @Summary Get geographic usage breakdown
+// @Description Requests per country, region or autonomous system over a date range (default the last 7 days), largest first. Empty values stand for addresses the GeoIP databases do not cover.
+// @Tags usage
+// @Produce json
+// @Param by query string false "country (default), region or asn"
+// @Param start_date query string false "First day, YYYY-MM-DD"
+// @Param end_date query string false "Last day, YYYY-MM-DD"
+// @Param limit query int false "Maximum rows (default 50, max 500)"
+// @Security ApiKeyAuth
+// @Success 200 {object} GeoUsageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/usage/geo [get]
+func (h *ClientHandler) GetGeoUsage(c *gin.Context) {
+	by := c.DefaultQuery("by", "country")
+	if _, ok := geoDimensions[by]; !ok {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "by must be one of: country, region, asn"})
+		return
+	}
+
+	startDate, endDate, errMsg := parseDateRange(c)
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: errMsg})
+		return
+	}
+	limit, errMsg := parseBreakdownLimit(c)
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: errMsg})
+		return
+	}
+
+	clientID := c.GetString("client_id")
+	cacheKey := h.cache.UsageKey(clientID, "geo", fmt.Sprintf("%s:%s:%s:%d", by,
+		startDate.Format("2006-01-02"), endDate.Format("2006-01-02"), limit))
+
+	var response GeoUsageResponse
+	if found, err := h.cache.Get(cacheKey, &response); found && err == nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response = GeoUsageResponse{
+		ClientID:  clientID,
+		StartDate: startDate.Format("2006-01-02"),
+		EndDate:   endDate.Format("2006-01-02"),
+		By:        by,
+		Breakdown: []GeoUsage{},
+	}
+
+	readDB := database.GetDBManager().GetReadDB()
+	query := readDB.Table("api_logs").
+		Where("client_id = ? AND timestamp >= ? AND timestamp < ?", clientID, startDate, endDate.AddDate(0, 0, 1))
+
+	err := query.Session(&gorm.Session{}).
+		Select("COALESCE(CAST(ROUND(SUM(sample_weight)) AS SIGNED), 0)").
+		Scan(&response.TotalRequests).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to fetch usage data"})
+		return
+	}
+
+	var rows []GeoUsage
+	err = geoBreakdownQuery(query, by, limit).Scan(&rows).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to fetch usage data"})
+		return
+	}
+
+	for _, row := range rows {
+		if response.TotalRequests > 0 {
+			row.Share = float64(row.Requests) / float64(response.TotalRequests)
+		}
+		response.Breakdown = append(response.Breakdown, row)
+	}
+
+	h.cache.Set(cacheKey, response, configs.AppConfig.CacheTTL)
+	c.JSON(http.StatusOK, response)
+}
+
+// geoBreakdownQuery selects the largest rows of a geographic breakdown
+func geoBreakdownQuery(query *gorm.DB, by string, limit int) *gorm.DB {
+	return query.Select(geoDimensions[by] + ", CAST(ROUND(SUM(sample_weight)) AS SIGNED) AS requests").
+		Group(geoGroupColumns[by]).
+		Order("requests DESC").
+		Limit(limit)
+}
+
+// parseDateRange reads the start_date and end_date query parameters. Both are
+// inclusive days; the default is the last 7 days.
+func parseDateRange(c *gin.Context) (time.Time, time.Time, string) {
+	now := time.Now()
+	endDate := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if value := c.Query("end_date"); value != "" {
+		day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, "end_date must be a date like 2024-01-31"
+		}
+		endDate = day
+	}
+
+	startDate := endDate.AddDate(0, 0, -6)
+	if value := c.Query("start_date"); value != "" {
+		day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, "start_date must be a date like 2024-01-01"
+		}
+		startDate = day
+	}
+
+	if startDate.After(endDate) {
+		return time.Time{}, time.Time{}, "start_date must not be after end_date"
+	}
+	if endDate.Sub(startDate) >= maxUsageRangeDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Sprintf("date range must not exceed %d days", maxUsageRangeDays)
+	}
+	return startDate, endDate, ""
+}
+
+// parseBreakdownLimit reads the limit query parameter of breakdown endpoints
+func parseBreakdownLimit(c *gin.Context) (int, string) {
+	value := c.Query("limit")
+	if value == "" {
+		return defaultBreakdownLimit, ""
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxBreakdownLimit {
+		return 0, fmt.Sprintf("limit must be between 1 and %d", maxBreakdownLimit)
+	}
+	return limit, ""
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// dryRunDB renders queries without a database
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "test@tcp(127.0.0.1:1)/test", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestGeoBreakdownQuery(t *testing.T) {
+	db := dryRunDB(t)
+	tests := map[string]string{
+		"country": "GROUP BY COALESCE(country, '') ",
+		"region":  "GROUP BY COALESCE(country, ''), COALESCE(region, '') ",
+		"asn":     "GROUP BY COALESCE(asn, 0) ",
+	}
+	for by, groupBy := range tests {
+		t.Run(by, func(t *testing.T) {
+			var rows []GeoUsage
+			stmt := geoBreakdownQuery(db.Table("api_logs"), by, 10).Scan(&rows).Statement
+			sql := stmt.SQL.String()
+			if !strings.HasPrefix(sql, "SELECT "+geoDimensions[by]+", ") {
+				t.Errorf("SQL = %s, want it to select %s", sql, geoDimensions[by])
+			}
+			if !strings.Contains(sql, groupBy) || strings.Contains(sql, "`1`") {
+				t.Errorf("SQL = %s, want %q", sql, groupBy)
+			}
+			if !strings.HasSuffix(sql, "ORDER BY requests DESC LIMIT ?") || !reflect.DeepEqual(stmt.Vars, []interface{}{10}) {
+				t.Errorf("SQL = %s with %v, want the largest 10 rows", sql, stmt.Vars)
+			}
+		})
+	}
+}
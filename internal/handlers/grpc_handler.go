@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	trackerv1 "user-activity-tracker/api/proto/tracker/v1"
+	"user-activity-tracker/internal/cache"
+	"user-activity-tracker/internal/middleware"
+	"user-activity-tracker/internal/services"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type grpcContextKey string
+
+const (
+	grpcClientIDKey grpcContextKey = "client_id"
+	grpcPeerIPKey   grpcContextKey = "peer_ip"
+)
+
+// GRPCHandler serves tracker.v1.TrackerService on top of the HTTP handler logic
+type GRPCHandler struct {
+	trackerv1.UnimplementedTrackerServiceServer
+
+	clientHandler *ClientHandler
+	authService   *services.AuthService
+	cache         *cache.CacheManager
+}
+
+func NewGRPCHandler(authService *services.AuthService, clientHandler *ClientHandler) *GRPCHandler {
+	return &GRPCHandler{
+		clientHandler: clientHandler,
+		authService:   authService,
+		cache:         cache.GetCacheManager(),
+	}
+}
+
+// Server builds a gRPC server with TrackerService registered behind the auth interceptors
+func (h *GRPCHandler) Server() *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(h.unaryInterceptor),
+		grpc.ChainStreamInterceptor(h.streamInterceptor),
+	)
+	trackerv1.RegisterTrackerServiceServer(srv, h)
+	return srv
+}
+
+func (h *GRPCHandler) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := h.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := handler(ctx, req)
+	logGRPCError(info.FullMethod, err)
+	return resp, err
+}
+
+func (h *GRPCHandler) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := h.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+
+	err = handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	logGRPCError(info.FullMethod, err)
+	return err
+}
+
+// authenticatedStream carries the caller stored by authenticate into stream handlers
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticate applies the same API key/JWT auth and rate limiting as the HTTP routes
+func (h *GRPCHandler) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	apiKey := firstMetadata(md, "x-api-key")
+
+	var tokenString string
+	if authHeader := firstMetadata(md, "authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+		tokenString = strings.TrimPrefix(authHeader, "Bearer ")
+	}
+
+	var peerIP string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		peerIP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(peerIP); err == nil {
+			peerIP = host
+		}
+	}
+	peerIP = h.authService.NormalizeIPv4(peerIP)
+
+	clientID, err := middleware.Authenticate(h.authService, apiKey, tokenString, peerIP)
+	if err != nil {
+		if errors.Is(err, middleware.ErrIPNotWhitelisted) {
+			return ctx, status.Error(codes.PermissionDenied, err.Error())
+		}
+		return ctx, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if _, allowed := middleware.CheckRateLimit(h.cache, clientID); !allowed {
+		return ctx, status.Error(codes.ResourceExhausted, "Rate limit exceeded")
+	}
+
+	ctx = context.WithValue(ctx, grpcClientIDKey, clientID)
+	ctx = context.WithValue(ctx, grpcPeerIPKey, peerIP)
+	return ctx, nil
+}
+
+// firstMetadata returns the first value of a metadata key, or ""
+func firstMetadata(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// logGRPCError logs calls that failed on the server side
+func logGRPCError(method string, err error) {
+	if err == nil {
+		return
+	}
+	if code := status.Code(err); code == codes.Unknown || code == codes.Internal {
+		log.Printf("gRPC %s failed: %v", method, err)
+	}
+}
+
+func (h *GRPCHandler) RecordLog(ctx context.Context, msg *trackerv1.LogEntry) (*trackerv1.RecordLogResponse, error) {
+	clientID, peerIP := grpcCaller(ctx)
+	entry := logEntryFromProto(msg)
+
+	now := time.Now()
+	if errMsg := validateBatchEntry(entry, now, false); errMsg != "" {
+		return nil, status.Error(codes.InvalidArgument, errMsg)
+	}
+
+	hit, store := h.clientHandler.buildEntryHit(clientID, peerIP, entry, now)
+	hitID, duplicate, err := h.clientHandler.ingestHit(hit, store, entry.EventID)
+	if err != nil {
+		return nil, grpcIngestError(err)
+	}
+
+	return &trackerv1.RecordLogResponse{HitId: hitID, Duplicate: duplicate}, nil
+}
+
+func (h *GRPCHandler) StreamLogs(stream grpc.ClientStreamingServer[trackerv1.LogEntry, trackerv1.StreamLogsResponse]) error {
+	ctx := stream.Context()
+	clientID, peerIP := grpcCaller(ctx)
+	ingester := h.clientHandler.newStreamIngester(ctx, clientID, peerIP)
+
+	index := 0
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			ingester.finish()
+			return err
+		}
+		index++
+
+		if err := ingester.add(index, logEntryFromProto(msg)); err != nil {
+			ingester.finish()
+			return grpcIngestError(err)
+		}
+	}
+
+	summary := ingester.finish()
+	resp := &trackerv1.StreamLogsResponse{
+		Received:   uint32(index),
+		Accepted:   uint32(summary.Accepted),
+		Duplicates: uint32(summary.Duplicates),
+		Rejected:   uint32(summary.Rejected),
+	}
+	for _, e := range summary.Errors {
+		resp.Errors = append(resp.Errors, &trackerv1.StreamError{Index: uint32(e.Line), Error: e.Error})
+	}
+	return stream.SendAndClose(resp)
+}
+
+func (h *GRPCHandler) GetDailyUsage(ctx context.Context, msg *trackerv1.GetDailyUsageRequest) (*trackerv1.DailyUsageResponse, error) {
+	filter := UsageFilter{Labels: msg.Labels, GroupBy: msg.GroupBy}
+	if errMsg := validateUsageFilter(filter); errMsg != "" {
+		return nil, status.Error(codes.InvalidArgument, errMsg)
+	}
+
+	usageRange, errMsg := parseUsageRange(msg.From, msg.To, msg.Tz, msg.Granularity)
+	if errMsg != "" {
+		return nil, status.Error(codes.InvalidArgument, errMsg)
+	}
+
+	clientID, _ := grpcCaller(ctx)
+	usage, err := h.clientHandler.dailyUsage(clientID, filter, usageRange)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "Failed to fetch usage data")
+	}
+
+	resp := &trackerv1.DailyUsageResponse{
+		ClientId:    usage.ClientID,
+		StartDate:   usage.StartDate,
+		EndDate:     usage.EndDate,
+		Usage:       dayUsageToProto(usage.Usage),
+		Labels:      usage.Labels,
+		GroupBy:     usage.GroupBy,
+		Tz:          usage.Timezone,
+		Granularity: usage.Granularity,
+	}
+	for _, group := range usage.Groups {
+		resp.Groups = append(resp.Groups, &trackerv1.LabelUsage{Value: group.Value, Usage: dayUsageToProto(group.Usage)})
+	}
+	return resp, nil
+}
+
+func (h *GRPCHandler) GetTopClients(ctx context.Context, msg *trackerv1.GetTopClientsRequest) (*trackerv1.TopClientsResponse, error) {
+	filter := UsageFilter{Labels: msg.Labels}
+	if errMsg := validateUsageFilter(filter); errMsg != "" {
+		return nil, status.Error(codes.InvalidArgument, errMsg)
+	}
+
+	var limit string
+	if msg.Limit != 0 {
+		limit = strconv.Itoa(int(msg.Limit))
+	}
+	query, errMsg := parseLeaderboardQuery(limit, msg.Window, msg.Metric, defaultTopClients, services.LeaderboardMetrics)
+	if errMsg != "" {
+		return nil, status.Error(codes.InvalidArgument, errMsg)
+	}
+
+	top, err := h.clientHandler.topClients(filter, query)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "Failed to fetch top clients")
+	}
+
+	resp := &trackerv1.TopClientsResponse{
+		Period:          top.Period,
+		GeneratedAtUnix: top.GeneratedAt.Unix(),
+		TotalClients:    int32(top.TotalClients),
+		Labels:          top.Labels,
+		Window:          top.Window,
+		Metric:          top.Metric,
+	}
+	for _, client := range top.TopClients {
+		resp.TopClients = append(resp.TopClients, &trackerv1.TopClient{
+			ClientId:     client.ClientID,
+			Name:         client.Name,
+			RequestCount: client.RequestCount,
+			Value:        client.Value,
+		})
+	}
+	return resp, nil
+}
+
+func dayUsageToProto(usage []DayUsage) []*trackerv1.DayUsage {
+	days := make([]*trackerv1.DayUsage, 0, len(usage))
+	for _, day := range usage {
+		days = append(days, &trackerv1.DayUsage{Date: day.Date, RequestCount: day.RequestCount})
+	}
+	return days
+}
+
+// grpcCaller returns the authenticated client ID and peer IP stored by authenticate
+func grpcCaller(ctx context.Context) (string, string) {
+	clientID, _ := ctx.Value(grpcClientIDKey).(string)
+	peerIP, _ := ctx.Value(grpcPeerIPKey).(string)
+	return clientID, peerIP
+}
+
+// grpcIngestError maps ingestion failures onto gRPC status codes
+func grpcIngestError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrQueueFull):
+		return status.Error(codes.Unavailable, "Ingestion queue is full, retry later")
+	case errors.Is(err, services.ErrIngestStopped):
+		return status.Error(codes.Unavailable, "Ingestion is unavailable")
+	case errors.Is(err, errIdempotencyCheck):
+		return status.Error(codes.Internal, "Failed to check idempotency key")
+	}
+	return status.Error(codes.Internal, "Failed to record log")
+}
+
+// logEntryFromProto converts a protobuf LogEntry into the JSON batch entry shape
+func logEntryFromProto(msg *trackerv1.LogEntry) LogBatchEntry {
+	entry := LogBatchEntry{
+		EventID:   msg.EventId,
+		Endpoint:  msg.Endpoint,
+		IPAddress: msg.IpAddress,
+		HitDetails: HitDetails{
+			Method:        msg.Method,
+			LatencyMs:     msg.LatencyMs,
+			RequestBytes:  msg.RequestBytes,
+			ResponseBytes: msg.ResponseBytes,
+			UserAgent:     msg.UserAgent,
+			UserID:        msg.UserId,
+			Labels:        msg.Labels,
+		},
+	}
+
+	if msg.TimestampUnixMs != nil {
+		ts := time.UnixMilli(*msg.TimestampUnixMs)
+		entry.Timestamp = &ts
+	}
+	if msg.StatusCode != nil {
+		code := uint16(min(*msg.StatusCode, 65535))
+		entry.StatusCode = &code
+	}
+	return entry
+}
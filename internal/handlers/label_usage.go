@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"user-activity-tracker/configs"
+	"user-activity-tracker/internal/database"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var labelKeyPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.-]{0,63}$`)
+
+// UsageFilter restricts usage queries to hits carrying the given labels and
+// optionally splits the result by the values of one label
+type UsageFilter struct {
+	Labels  map[string]string
+	GroupBy string
+}
+
+// parseUsageFilter reads repeated label=key:value and group_by=key query parameters
+func parseUsageFilter(c *gin.Context) (UsageFilter, string) {
+	filter := UsageFilter{GroupBy: c.Query("group_by")}
+
+	for _, param := range c.QueryArray("label") {
+		key, value, ok := strings.Cut(param, ":")
+		if !ok {
+			return UsageFilter{}, "label filters must look like label=key:value"
+		}
+		if filter.Labels == nil {
+			filter.Labels = make(map[string]string)
+		}
+		filter.Labels[key] = value
+	}
+
+	return filter, validateUsageFilter(filter)
+}
+
+// validateUsageFilter checks label filters and the group_by key
+func validateUsageFilter(filter UsageFilter) string {
+	if filter.GroupBy != "" && !labelKeyPattern.MatchString(filter.GroupBy) {
+		return "group_by must be a valid label key"
+	}
+	if len(filter.Labels) > configs.AppConfig.LabelMaxPerHit {
+		return fmt.Sprintf("at most %d label filters are allowed", configs.AppConfig.LabelMaxPerHit)
+	}
+	for key, value := range filter.Labels {
+		if !labelKeyPattern.MatchString(key) || value == "" {
+			return "label filters must look like label=key:value"
+		}
+	}
+	return ""
+}
+
+// IsZero reports whether the filter selects all hits without grouping
+func (f UsageFilter) IsZero() bool {
+	return len(f.Labels) == 0 && f.GroupBy == ""
+}
+
+// cacheSuffix identifies the filter in usage cache keys
+func (f UsageFilter) cacheSuffix() string {
+	if f.IsZero() {
+		return ""
+	}
+
+	parts := make([]string, 0, len(f.Labels))
+	for _, key := range f.sortedKeys() {
+		parts = append(parts, key+"="+f.Labels[key])
+	}
+	return fmt.Sprintf(":labels:%s:group:%s", strings.Join(parts, ","), f.GroupBy)
+}
+
+func (f UsageFilter) sortedKeys() []string {
+	keys := make([]string, 0, len(f.Labels))
+	for key := range f.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// labelQuery selects label rows of hits in [since, until) matching the filter.
+// Alias l0 holds the group_by label, or the first filter label when not grouping.
+func labelQuery(db *gorm.DB, filter UsageFilter, since, until time.Time) *gorm.DB {
+	keys := filter.sortedKeys()
+
+	baseKey := filter.GroupBy
+	if baseKey == "" {
+		baseKey = keys[0]
+	}
+
+	query := db.Table("api_log_labels AS l0").
+		Where("l0.label_key = ? AND l0.timestamp >= ? AND l0.timestamp < ?", baseKey, since, until)
+	if value, ok := filter.Labels[baseKey]; ok {
+		query = query.Where("l0.label_value = ?", value)
+	}
+
+	joined := 0
+	for _, key := range keys {
+		if key == baseKey {
+			continue
+		}
+		joined++
+		alias := fmt.Sprintf("l%d", joined)
+		query = query.Joins(
+			fmt.Sprintf("JOIN api_log_labels AS %[1]s ON %[1]s.log_id = l0.log_id AND %[1]s.label_key = ? AND %[1]s.label_value = ?", alias),
+			key, filter.Labels[key])
+	}
+	return query
+}
+
+// labelDailyUsage computes usage from labelled hits, split per group_by value when requested
+func (h *ClientHandler) labelDailyUsage(clientID string, filter UsageFilter, r usageRange) (DailyUsageResponse, error) {
+	var rows []struct {
+		Slot         string
+		LabelValue   string
+		RequestCount int64
+	}
+	err := labelQuery(database.GetDBManager().GetReadDB(), filter, r.start(), r.end).
+		Select(r.slotExpr("l0.timestamp")+" AS slot, l0.label_value AS label_value, CAST(ROUND(SUM(l0.sample_weight)) AS SIGNED) AS request_count").
+		Where("l0.client_id = ?", clientID).
+		Group("slot, label_value").
+		Scan(&rows).Error
+	if err != nil {
+		return DailyUsageResponse{}, fmt.Errorf("fetch labelled usage: %w", err)
+	}
+
+	total := make([]int64, len(r.starts))
+	groups := make(map[string][]int64)
+	for _, row := range rows {
+		slot := []slotCount{{Slot: row.Slot, RequestCount: row.RequestCount}}
+		r.addSlots(total, slot)
+
+		if groups[row.LabelValue] == nil {
+			groups[row.LabelValue] = make([]int64, len(r.starts))
+		}
+		r.addSlots(groups[row.LabelValue], slot)
+	}
+
+	response := r.response(clientID, total)
+	response.Labels = filter.Labels
+	response.GroupBy = filter.GroupBy
+
+	if filter.GroupBy != "" {
+		values := make([]string, 0, len(groups))
+		for value := range groups {
+			values = append(values, value)
+		}
+		sort.Strings(values)
+
+		for _, value := range values {
+			response.Groups = append(response.Groups, LabelUsage{Value: value, Usage: r.series(groups[value])})
+		}
+	}
+
+	return response, nil
+}
+
+// validateLabels checks the custom labels of a hit
+func validateLabels(labels map[string]string) string {
+	if len(labels) > configs.AppConfig.LabelMaxPerHit {
+		return fmt.Sprintf("at most %d labels are allowed", configs.AppConfig.LabelMaxPerHit)
+	}
+	for key, value := range labels {
+		if !labelKeyPattern.MatchString(key) {
+			return fmt.Sprintf("label key %q is invalid", key)
+		}
+		if value == "" || len(value) > 128 {
+			return fmt.Sprintf("label %q must have a value of 1 to 128 characters", key)
+		}
+	}
+	return ""
+}
+
+type LabelUsage struct {
+	Value string     `json:"value"`
+	Usage []DayUsage `json:"usage"`
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"user-activity-tracker/configs"
+	"user-activity-tracker/internal/otlp"
+	"user-activity-tracker/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+)
+
+// rpcCodes maps HTTP statuses onto the google.rpc.Code carried in OTLP errors
+var rpcCodes = map[int]int32{
+	http.StatusBadRequest:         3,  // INVALID_ARGUMENT
+	http.StatusUnauthorized:       16, // UNAUTHENTICATED
+	http.StatusServiceUnavailable: 14, // UNAVAILABLE
+}
+
+// OTLPHandler receives OpenTelemetry traces over OTLP/HTTP and records every
+// server span as an API hit. The client is read from a resource attribute.
+type OTLPHandler struct {
+	clientHandler *ClientHandler
+	authService   *services.AuthService
+}
+
+func NewOTLPHandler(authService *services.AuthService, clientHandler *ClientHandler) *OTLPHandler {
+	return &OTLPHandler{
+		clientHandler: clientHandler,
+		authService:   authService,
+	}
+}
+
+// ExportTraces handles OTLP/HTTP trace exports
+// @Summary Ingest OpenTelemetry traces
+// @Description OTLP/HTTP trace export (application/x-protobuf or application/json, optionally gzip-encoded). Server spans become API hits for the client named by the OTLP_CLIENT_ATTRIBUTE resource attribute; other spans are ignored. Requires "Authorization: Bearer <OTLP_AUTH_TOKEN>".
+// @Tags logs
+// @Accept application/x-protobuf
+// @Accept json
+// @Produce application/x-protobuf
+// @Produce json
+// @Success 200 {object} otlp.Response
+// @Failure 400 {object} otlp.Status
+// @Failure 401 {object} otlp.Status
+// @Failure 415 {object} otlp.Status
+// @Failure 503 {object} otlp.Status
+// @Router /otlp/v1/traces [post]
+func (h *OTLPHandler) ExportTraces(c *gin.Context) {
+	contentType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if contentType != contentTypeProtobuf && contentType != contentTypeJSON {
+		c.Data(http.StatusUnsupportedMediaType, "text/plain", []byte("Content-Type must be application/x-protobuf or application/json"))
+		return
+	}
+
+	cfg := configs.AppConfig
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.OTLPAuthToken)) != 1 {
+		h.status(c, contentType, http.StatusUnauthorized, "Invalid OTLP token")
+		return
+	}
+
+	body, errMsg := h.readBody(c)
+	if errMsg != "" {
+		h.status(c, contentType, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	var resources []otlp.ResourceSpans
+	var err error
+	if contentType == contentTypeProtobuf {
+		resources, err = otlp.UnmarshalProto(body)
+	} else {
+		resources, err = otlp.UnmarshalJSON(body)
+	}
+	if err != nil {
+		h.status(c, contentType, http.StatusBadRequest, fmt.Sprintf("Invalid trace export: %v", err))
+		return
+	}
+
+	now := time.Now()
+	clients := make(map[string]bool) // client ID -> registered
+	var entries []clientEntry
+	var rejected int64
+	var firstError string
+	reject := func(msg string) {
+		rejected++
+		if firstError == "" {
+			firstError = msg
+		}
+	}
+
+	for _, rs := range resources {
+		clientID := rs.Resource[cfg.OTLPClientAttribute]
+		registered, ok := clients[clientID]
+		if !ok && clientID != "" {
+			_, err := h.authService.GetClient(clientID)
+			registered = err == nil
+			clients[clientID] = registered
+		}
+
+		for _, span := range rs.Spans {
+			if span.Kind != otlp.SpanKindServer {
+				continue
+			}
+			if !registered {
+				reject(fmt.Sprintf("resource attribute %s does not name a registered client", cfg.OTLPClientAttribute))
+				continue
+			}
+
+			entry, errMsg := spanEntry(span)
+			if errMsg == "" {
+				errMsg = validateBatchEntry(entry, now, false)
+			}
+			if errMsg != "" {
+				reject(fmt.Sprintf("span %x: %s", span.SpanID, errMsg))
+				continue
+			}
+			entries = append(entries, clientEntry{ClientID: clientID, Entry: entry, Received: now})
+		}
+	}
+
+	if _, _, err := h.clientHandler.storeClientEntries(entries); err != nil {
+		log.Printf("Failed to store %d OTLP spans: %v", len(entries), err)
+		// 503 tells exporters to retry; span IDs keep the retry idempotent
+		h.status(c, contentType, http.StatusServiceUnavailable, "Failed to record spans")
+		return
+	}
+
+	if contentType == contentTypeProtobuf {
+		c.Data(http.StatusOK, contentTypeProtobuf, otlp.MarshalResponseProto(rejected, firstError))
+		return
+	}
+	c.JSON(http.StatusOK, otlp.NewResponse(rejected, firstError))
+}
+
+// readBody reads the request body, decompressing it when gzip-encoded. It
+// returns an error message when the body cannot be read.
+func (h *OTLPHandler) readBody(c *gin.Context) ([]byte, string) {
+	limit := int64(configs.AppConfig.OTLPMaxBodyBytes)
+	var body io.Reader = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+
+	if strings.EqualFold(c.GetHeader("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, "Invalid gzip body"
+		}
+		defer gz.Close()
+		// Bound the decompressed size as well
+		body = io.LimitReader(gz, limit+1)
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, "Failed to read request body"
+	}
+	if int64(len(data)) > limit {
+		return nil, "Request body too large"
+	}
+	return data, ""
+}
+
+// status writes an OTLP error response in the request's encoding
+func (h *OTLPHandler) status(c *gin.Context, contentType string, code int, message string) {
+	status := otlp.Status{Code: rpcCodes[code], Message: message}
+	if contentType == contentTypeProtobuf {
+		c.Data(code, contentTypeProtobuf, otlp.MarshalStatusProto(status.Code, status.Message))
+		return
+	}
+	c.JSON(code, status)
+}
+
+// spanEntry maps a server span onto a log entry using the OpenTelemetry HTTP
+// semantic conventions, accepting both current and legacy attribute names
+func spanEntry(span otlp.Span) (LogBatchEntry, string) {
+	attr := func(keys ...string) string {
+		for _, key := range keys {
+			if v := span.Attributes[key]; v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	entry := LogBatchEntry{
+		Endpoint: attr("http.route", "url.path"),
+	}
+	if entry.Endpoint == "" {
+		entry.Endpoint, _, _ = strings.Cut(attr("http.target"), "?")
+	}
+	if entry.Endpoint == "" {
+		return entry, "span has no http.route"
+	}
+
+	if len(span.TraceID) > 0 && len(span.SpanID) > 0 {
+		entry.EventID = hex.EncodeToString(span.TraceID) + hex.EncodeToString(span.SpanID)
+	}
+	if span.StartTimeUnixNano > 0 && span.StartTimeUnixNano <= math.MaxInt64 {
+		ts := time.Unix(0, int64(span.StartTimeUnixNano))
+		entry.Timestamp = &ts
+
+		if span.EndTimeUnixNano >= span.StartTimeUnixNano {
+			latency := min((span.EndTimeUnixNano-span.StartTimeUnixNano)/uint64(time.Millisecond), math.MaxUint32)
+			latencyMs := uint32(latency)
+			entry.LatencyMs = &latencyMs
+		}
+	}
+
+	if ip := attr("client.address", "http.client_ip", "net.sock.peer.addr", "net.peer.ip"); net.ParseIP(ip) != nil {
+		entry.IPAddress = ip
+	}
+
+	entry.Method = attr("http.request.method", "http.method")
+	entry.UserAgent = attr("user_agent.original", "http.user_agent")
+	if userID := attr("enduser.id"); len(userID) <= 128 {
+		entry.UserID = userID
+	}
+
+	if v, err := strconv.ParseUint(attr("http.response.status_code", "http.status_code"), 10, 16); err == nil {
+		code := uint16(v)
+		entry.StatusCode = &code
+	}
+	if v, err := strconv.ParseUint(attr("http.request.body.size", "http.request_content_length"), 10, 64); err == nil {
+		entry.RequestBytes = &v
+	}
+	if v, err := strconv.ParseUint(attr("http.response.body.size", "http.response_content_length"), 10, 64); err == nil {
+		entry.ResponseBytes = &v
+	}
+	return entry, ""
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"user-activity-tracker/configs"
+	"user-activity-tracker/internal/otlp"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOTLPRequiresToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := *configs.AppConfig
+	cfg.OTLPAuthToken = "secret"
+	original := configs.AppConfig
+	configs.AppConfig = &cfg
+	t.Cleanup(func() { configs.AppConfig = original })
+
+	router := gin.New()
+	router.POST("/otlp/v1/traces", (&OTLPHandler{}).ExportTraces)
+
+	for _, auth := range []string{"", "Bearer", "Bearer wrong", "secret", "Basic secret"} {
+		req := httptest.NewRequest(http.MethodPost, "/otlp/v1/traces", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: status %d, want 401", auth, w.Code)
+		}
+	}
+}
+
+func TestSpanEntry(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	span := func(attrs map[string]string) otlp.Span {
+		return otlp.Span{
+			TraceID:           []byte{0xab},
+			SpanID:            []byte{0xcd},
+			Kind:              otlp.SpanKindServer,
+			StartTimeUnixNano: uint64(start.UnixNano()),
+			EndTimeUnixNano:   uint64(start.Add(1500 * time.Millisecond).UnixNano()),
+			Attributes:        attrs,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		span     otlp.Span
+		endpoint string
+		method   string
+		status   uint16
+		ip       string
+		errMsg   string
+	}{
+		{
+			name: "current attribute names",
+			span: span(map[string]string{
+				"http.route": "/users/{id}", "http.request.method": "GET",
+				"http.response.status_code": "200", "client.address": "203.0.113.7",
+			}),
+			endpoint: "/users/{id}",
+			method:   "GET",
+			status:   200,
+			ip:       "203.0.113.7",
+		},
+		{
+			name: "legacy attribute names",
+			span: span(map[string]string{
+				"http.target": "/search?q=x", "http.method": "POST",
+				"http.status_code": "500", "net.peer.ip": "2001:db8::1",
+			}),
+			endpoint: "/search",
+			method:   "POST",
+			status:   500,
+			ip:       "2001:db8::1",
+		},
+		{
+			name:     "invalid client address",
+			span:     span(map[string]string{"url.path": "/a", "client.address": "not-an-ip"}),
+			endpoint: "/a",
+		},
+		{
+			name:   "no route",
+			span:   span(map[string]string{"http.request.method": "GET"}),
+			errMsg: "span has no http.route",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, errMsg := spanEntry(tt.span)
+			if errMsg != tt.errMsg {
+				t.Fatalf("error = %q, want %q", errMsg, tt.errMsg)
+			}
+			if tt.errMsg != "" {
+				return
+			}
+			if entry.Endpoint != tt.endpoint || entry.Method != tt.method || entry.IPAddress != tt.ip {
+				t.Errorf("entry = %+v", entry)
+			}
+			if (entry.StatusCode == nil) != (tt.status == 0) || (entry.StatusCode != nil && *entry.StatusCode != tt.status) {
+				t.Errorf("status = %v, want %d", entry.StatusCode, tt.status)
+			}
+			if entry.EventID != "abcd" {
+				t.Errorf("event_id = %q, want trace ID + span ID", entry.EventID)
+			}
+			if entry.Timestamp == nil || !entry.Timestamp.Equal(start) {
+				t.Errorf("timestamp = %v, want %v", entry.Timestamp, start)
+			}
+			if entry.LatencyMs == nil || *entry.LatencyMs != 1500 {
+				t.Errorf("latency = %v, want 1500ms", entry.LatencyMs)
+			}
+		})
+	}
+}
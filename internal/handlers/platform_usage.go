@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"user-activity-tracker/configs"
+	"user-activity-tracker/internal/database"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// platformDimensions maps the by parameter onto the columns a breakdown groups by
+var platformDimensions = map[string]string{
+	"browser":         "COALESCE(browser, '') AS browser",
+	"browser_version": "COALESCE(browser, '') AS browser, COALESCE(browser_version, '') AS browser_version",
+	"os":              "COALESCE(os, '') AS os",
+	"os_version":      "COALESCE(os, '') AS os, COALESCE(os_version, '') AS os_version",
+	"device":          "COALESCE(device_type, '') AS device_type",
+	"bot":             "is_bot",
+}
+
+// platformGroupColumns holds the expressions of platformDimensions; a lone
+// column ordinal would be quoted as a column name
+var platformGroupColumns = map[string]string{
+	"browser":         "COALESCE(browser, '')",
+	"browser_version": "COALESCE(browser, ''), COALESCE(browser_version, '')",
+	"os":              "COALESCE(os, '')",
+	"os_version":      "COALESCE(os, ''), COALESCE(os_version, '')",
+	"device":          "COALESCE(device_type, '')",
+	"bot":             "is_bot",
+}
+
+type PlatformUsage struct {
+	Browser        string  `json:"browser,omitempty"`
+	BrowserVersion string  `json:"browser_version,omitempty"`
+	OS             string  `json:"os,omitempty" gorm:"column:os"`
+	OSVersion      string  `json:"os_version,omitempty" gorm:"column:os_version"`
+	Device         string  `json:"device,omitempty" gorm:"column:device_type"`
+	Bot            *bool   `json:"bot,omitempty" gorm:"column:is_bot"`
+	Requests       int64   `json:"requests"`
+	Share          float64 `json:"share"`
+}
+
+type PlatformUsageResponse struct {
+	ClientID         string          `json:"client_id"`
+	StartDate        string          `json:"start_date"`
+	EndDate          string          `json:"end_date"`
+	By               string          `json:"by"`
+	EndpointTemplate string          `json:"endpoint_template,omitempty"`
+	TotalRequests    int64           `json:"total_requests"`
+	Breakdown        []PlatformUsage `json:"breakdown"`
+}
+
+// GetPlatformUsage returns which browsers, operating systems, devices and SDKs
+// the client's traffic comes from
+// @Summary Get platform usage breakdown
+// @Description Requests per browser (or library/SDK), OS, device type or bot flag over a date range (default the last 7 days), largest first. Filter by endpoint_template to see which platforms and SDK versions still call an endpoint.
+// @Tags usage
+// @Produce json
+// @Param by query string false "browser (default), browser_version, os, os_version, device or bot"
+// @Param endpoint_template query string false "Only count hits on this route template, e.g. /v1/orders/:id"
+// @Param start_date query string false "First day, YYYY-MM-DD"
+// @Param end_date query string false "Last day, YYYY-MM-DD"
+// @Param limit query int false "Maximum rows (default 50, max 500)"
+// @Security ApiKeyAuth
+// @Success 200 {object} PlatformUsageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/usage/platforms [get]
+func (h *ClientHandler) GetPlatformUsage(c *gin.Context) {
+	by := c.DefaultQuery("by", "browser")
+	if _, ok := platformDimensions[by]; !ok {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "by must be one of: browser, browser_version, os, os_version, device, bot"})
+		return
+	}
+
+	startDate, endDate, errMsg := parseDateRange(c)
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: errMsg})
+		return
+	}
+	limit, errMsg := parseBreakdownLimit(c)
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: errMsg})
+		return
+	}
+	template := c.Query("endpoint_template")
+
+	clientID := c.GetString("client_id")
+	cacheKey := h.cache.UsageKey(clientID, "platforms", fmt.Sprintf("%s:%s:%s:%d:%s", by,
+		startDate.Format("2006-01-02"), endDate.Format("2006-01-02"), limit, template))
+
+	var response PlatformUsageResponse
+	if found, err := h.cache.Get(cacheKey, &response); found && err == nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response = PlatformUsageResponse{
+		ClientID:         clientID,
+		StartDate:        startDate.Format("2006-01-02"),
+		EndDate:          endDate.Format("2006-01-02"),
+		By:               by,
+		EndpointTemplate: template,
+		Breakdown:        []PlatformUsage{},
+	}
+
+	readDB := database.GetDBManager().GetReadDB()
+	query := readDB.Table("api_logs").
+		Where("client_id = ? AND timestamp >= ? AND timestamp < ?", clientID, startDate, endDate.AddDate(0, 0, 1))
+	if template != "" {
+		query = query.Where("endpoint_template = ?", template)
+	}
+
+	err := query.Session(&gorm.Session{}).
+		Select("COALESCE(CAST(ROUND(SUM(sample_weight)) AS SIGNED), 0)").
+		Scan(&response.TotalRequests).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to fetch usage data"})
+		return
+	}
+
+	var rows []PlatformUsage
+	err = platformBreakdownQuery(query, by, limit).Scan(&rows).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to fetch usage data"})
+		return
+	}
+
+	for _, row := range rows {
+		if response.TotalRequests > 0 {
+			row.Share = float64(row.Requests) / float64(response.TotalRequests)
+		}
+		response.Breakdown = append(response.Breakdown, row)
+	}
+
+	h.cache.Set(cacheKey, response, configs.AppConfig.CacheTTL)
+	c.JSON(http.StatusOK, response)
+}
+
+// platformBreakdownQuery selects the largest rows of a platform breakdown
+func platformBreakdownQuery(query *gorm.DB, by string, limit int) *gorm.DB {
+	return query.Select(platformDimensions[by] + ", CAST(ROUND(SUM(sample_weight)) AS SIGNED) AS requests").
+		Group(platformGroupColumns[by]).
+		Order("requests DESC").
+		Limit(limit)
+}
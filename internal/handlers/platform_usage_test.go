@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlatformBreakdownQuery(t *testing.T) {
+	db := dryRunDB(t)
+	tests := map[string]string{
+		"browser":         "GROUP BY COALESCE(browser, '') ",
+		"browser_version": "GROUP BY COALESCE(browser, ''), COALESCE(browser_version, '') ",
+		"os":              "GROUP BY COALESCE(os, '') ",
+		"os_version":      "GROUP BY COALESCE(os, ''), COALESCE(os_version, '') ",
+		"device":          "GROUP BY COALESCE(device_type, '') ",
+		"bot":             "GROUP BY `is_bot` ",
+	}
+	for by, groupBy := range tests {
+		t.Run(by, func(t *testing.T) {
+			var rows []PlatformUsage
+			query := db.Table("api_logs").Where("endpoint_template = ?", "/v1/orders/:id")
+			stmt := platformBreakdownQuery(query, by, 5).Scan(&rows).Statement
+			sql := stmt.SQL.String()
+			if !strings.HasPrefix(sql, "SELECT "+platformDimensions[by]+", ") {
+				t.Errorf("SQL = %s, want it to select %s", sql, platformDimensions[by])
+			}
+			if !strings.Contains(sql, groupBy) || strings.Contains(sql, "`1`") {
+				t.Errorf("SQL = %s, want %q", sql, groupBy)
+			}
+			if !strings.HasSuffix(sql, "ORDER BY requests DESC LIMIT ?") || !reflect.DeepEqual(stmt.Vars, []interface{}{"/v1/orders/:id", 5}) {
+				t.Errorf("SQL = %s with %v, want the largest 5 rows of the template", sql, stmt.Vars)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"user-activity-tracker/configs"
+	"user-activity-tracker/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrivacyHandler manages how the client's IP addresses are stored
+type PrivacyHandler struct {
+	ipPrivacy *services.IPPrivacyService
+}
+
+func NewPrivacyHandler(ipPrivacy *services.IPPrivacyService) *PrivacyHandler {
+	return &PrivacyHandler{ipPrivacy: ipPrivacy}
+}
+
+// GetIPPrivacy returns the client's IP privacy mode
+// @Summary Get IP privacy mode
+// @Description How IP addresses of hits are stored: full (the default), truncated to /24 for IPv4 and /48 for IPv6, hashed with a keyed hash, or none. Also returns the age after which full addresses are anonymized, if configured.
+// @Tags privacy
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} IPPrivacySettings
+// @Failure 401 {object} ErrorResponse
+// @Router /api/settings/ip-privacy [get]
+func (h *PrivacyHandler) GetIPPrivacy(c *gin.Context) {
+	clientID := c.GetString("client_id")
+	c.JSON(http.StatusOK, ipPrivacySettings(clientID, h.ipPrivacy.Mode(clientID)))
+}
+
+// SetIPPrivacy changes how the client's IP addresses are stored
+// @Summary Set IP privacy mode
+// @Description Store IP addresses in the given mode from now on. The mode also applies to WebSocket payloads and to addresses returned by the API, so addresses stored earlier are shown no more precisely than the new mode allows.
+// @Tags privacy
+// @Accept json
+// @Produce json
+// @Param request body IPPrivacySettings true "Privacy mode"
+// @Security ApiKeyAuth
+// @Success 200 {object} IPPrivacySettings
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/settings/ip-privacy [put]
+func (h *PrivacyHandler) SetIPPrivacy(c *gin.Context) {
+	var req IPPrivacySettings
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+
+	clientID := c.GetString("client_id")
+	err := h.ipPrivacy.SetMode(clientID, req.Mode)
+	switch {
+	case errors.Is(err, services.ErrInvalidIPPrivacy):
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to save IP privacy settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ipPrivacySettings(clientID, req.Mode))
+}
+
+type IPPrivacySettings struct {
+	ClientID string `json:"client_id,omitempty"`
+	Mode     string `json:"ip_privacy"`
+
+	// Full addresses older than this are anonymized; empty when disabled
+	AnonymizeAfter string `json:"anonymize_after,omitempty"`
+}
+
+func ipPrivacySettings(clientID, mode string) IPPrivacySettings {
+	settings := IPPrivacySettings{ClientID: clientID, Mode: mode}
+	if after := configs.AppConfig.IPAnonymizeAfter; after > 0 && mode == services.IPPrivacyFull {
+		settings.AnonymizeAfter = after.Round(time.Second).String()
+	}
+	return settings
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"user-activity-tracker/configs"
+	"user-activity-tracker/internal/models"
+	"user-activity-tracker/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RedactionHandler manages the client's endpoint redaction rules
+type RedactionHandler struct {
+	redaction *services.RedactionService
+}
+
+func NewRedactionHandler(redaction *services.RedactionService) *RedactionHandler {
+	return &RedactionHandler{redaction: redaction}
+}
+
+// GetRedactionRules returns the client's redaction rules
+// @Summary Get redaction rules
+// @Description Rules applied to endpoints before hits are stored, broadcast or cached. Clients without rules get the defaults: deny mode with the global parameter deny list, email and JWT detection on.
+// @Tags redaction
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} RedactionRulesResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/redaction-rules [get]
+func (h *RedactionHandler) GetRedactionRules(c *gin.Context) {
+	rules, err := h.redaction.Rules(c.GetString("client_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to fetch redaction rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, redactionRulesResponse(rules))
+}
+
+// SetRedactionRules replaces the client's redaction rules
+// @Summary Set redaction rules
+// @Description In deny mode the listed query parameters are removed in addition to the global deny list; in allow mode only the listed parameters are kept. Patterns are regular expressions whose matches are replaced with [REDACTED]. Rules apply to hits recorded from now on.
+// @Tags redaction
+// @Accept json
+// @Produce json
+// @Param request body RedactionRulesRequest true "Redaction rules"
+// @Security ApiKeyAuth
+// @Success 200 {object} RedactionRulesResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/redaction-rules [put]
+func (h *RedactionHandler) SetRedactionRules(c *gin.Context) {
+	var req RedactionRulesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+
+	rules, err := req.rules(c.GetString("client_id"))
+	if err == nil {
+		var stored *models.RedactionRules
+		stored, err = h.redaction.SetRules(rules)
+		if err == nil {
+			rules = *stored
+		}
+	}
+	switch {
+	case errors.Is(err, services.ErrInvalidRedactionRules):
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to save redaction rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, redactionRulesResponse(rules))
+}
+
+// DeleteRedactionRules resets the client to the default rules
+// @Summary Reset redaction rules
+// @Description Delete the client's rules; the defaults apply again
+// @Tags redaction
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/redaction-rules [delete]
+func (h *RedactionHandler) DeleteRedactionRules(c *gin.Context) {
+	if err := h.redaction.DeleteRules(c.GetString("client_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to reset redaction rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Message: "Redaction rules reset to defaults"})
+}
+
+// TestRedaction shows how an endpoint would be stored
+// @Summary Test redaction rules
+// @Description Redact an endpoint with the given rules, or with the client's current rules when none are given, without storing anything
+// @Tags redaction
+// @Accept json
+// @Produce json
+// @Param request body RedactionTestRequest true "Endpoint and optional rules"
+// @Security ApiKeyAuth
+// @Success 200 {object} RedactionTestResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/redaction-rules/test [post]
+func (h *RedactionHandler) TestRedaction(c *gin.Context) {
+	var req RedactionTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+
+	clientID := c.GetString("client_id")
+	var (
+		rules models.RedactionRules
+		err   error
+	)
+	if req.Rules != nil {
+		rules, err = req.Rules.rules(clientID)
+	} else {
+		rules, err = h.redaction.Rules(clientID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to fetch redaction rules"})
+			return
+		}
+	}
+
+	var redactor *services.Redactor
+	if err == nil {
+		redactor, err = services.NewRedactor(rules)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, RedactionTestResponse{
+		Endpoint: req.Endpoint,
+		Redacted: redactor.Redact(req.Endpoint),
+	})
+}
+
+type RedactionRulesRequest struct {
+	QueryMode    string   `json:"query_mode"`
+	QueryParams  []string `json:"query_params"`
+	Patterns     []string `json:"patterns"`
+	DetectEmails *bool    `json:"detect_emails"`
+	DetectJWTs   *bool    `json:"detect_jwts"`
+}
+
+// rules converts the request into the stored form; detectors default to on
+func (r RedactionRulesRequest) rules(clientID string) (models.RedactionRules, error) {
+	rules := models.RedactionRules{
+		ClientID:     clientID,
+		QueryMode:    r.QueryMode,
+		DetectEmails: r.DetectEmails == nil || *r.DetectEmails,
+		DetectJWTs:   r.DetectJWTs == nil || *r.DetectJWTs,
+	}
+	if rules.QueryMode == "" {
+		rules.QueryMode = services.QueryModeDeny
+	}
+
+	if len(r.QueryParams) > 0 {
+		params, err := json.Marshal(r.QueryParams)
+		if err != nil {
+			return rules, err
+		}
+		rules.QueryParams = string(params)
+	}
+	if len(r.Patterns) > 0 {
+		patterns, err := json.Marshal(r.Patterns)
+		if err != nil {
+			return rules, err
+		}
+		rules.Patterns = string(patterns)
+	}
+	return rules, nil
+}
+
+type RedactionRulesResponse struct {
+	ClientID           string     `json:"client_id"`
+	QueryMode          string     `json:"query_mode"`
+	QueryParams        []string   `json:"query_params"`
+	Patterns           []string   `json:"patterns"`
+	DetectEmails       bool       `json:"detect_emails"`
+	DetectJWTs         bool       `json:"detect_jwts"`
+	DefaultQueryParams []string   `json:"default_query_params,omitempty"`
+	UpdatedAt          *time.Time `json:"updated_at,omitempty"`
+}
+
+type RedactionTestRequest struct {
+	Endpoint string                 `json:"endpoint" binding:"required"`
+	Rules    *RedactionRulesRequest `json:"rules,omitempty"`
+}
+
+type RedactionTestResponse struct {
+	Endpoint string `json:"endpoint"`
+	Redacted string `json:"redacted"`
+}
+
+func redactionRulesResponse(rules models.RedactionRules) RedactionRulesResponse {
+	resp := RedactionRulesResponse{
+		ClientID:     rules.ClientID,
+		QueryMode:    rules.QueryMode,
+		QueryParams:  []string{},
+		Patterns:     []string{},
+		DetectEmails: rules.DetectEmails,
+		DetectJWTs:   rules.DetectJWTs,
+	}
+	if !rules.UpdatedAt.IsZero() {
+		resp.UpdatedAt = &rules.UpdatedAt
+	}
+	if rules.QueryParams != "" {
+		json.Unmarshal([]byte(rules.QueryParams), &resp.QueryParams)
+	}
+	if rules.Patterns != "" {
+		json.Unmarshal([]byte(rules.Patterns), &resp.Patterns)
+	}
+	if rules.QueryMode == services.QueryModeDeny {
+		resp.DefaultQueryParams = configs.AppConfig.RedactQueryParams
+	}
+	return resp
+}
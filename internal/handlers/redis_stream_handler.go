@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"user-activity-tracker/configs"
+	"user-activity-tracker/internal/services"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisStreamConsumer ingests hits that producers XADD to a Redis stream.
+// Each entry carries an "api_key" and a "data" field holding the same JSON
+// object as an /api/logs/batch entry. Clients listed in
+// REDIS_STREAM_TRUSTED_CLIENTS may give their "client_id" instead of a key.
+type RedisStreamConsumer struct {
+	redis *redis.Client
+
+	// store persists entries; validateAPIKey resolves an API key to a client ID
+	store          func(entries []clientEntry) (accepted, duplicates int, err error)
+	validateAPIKey func(apiKey string) (string, error)
+	trusted        map[string]bool
+
+	stream     string
+	group      string
+	consumer   string
+	count      int64
+	block      time.Duration
+	minIdle    time.Duration
+	claimEvery time.Duration
+
+	processed atomic.Uint64
+	rejected  atomic.Uint64
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewRedisStreamConsumer(authService *services.AuthService, clientHandler *ClientHandler, client *redis.Client) *RedisStreamConsumer {
+	cfg := configs.AppConfig
+	trusted := make(map[string]bool, len(cfg.RedisStreamTrustedClients))
+	for _, clientID := range cfg.RedisStreamTrustedClients {
+		trusted[clientID] = true
+	}
+
+	return &RedisStreamConsumer{
+		redis: client,
+		store: clientHandler.storeClientEntries,
+		validateAPIKey: func(apiKey string) (string, error) {
+			client, err := authService.ValidateAPIKey(apiKey)
+			if err != nil {
+				return "", err
+			}
+			return client.ClientID, nil
+		},
+		trusted:    trusted,
+		stream:     cfg.RedisStreamKey,
+		group:      cfg.RedisStreamGroup,
+		consumer:   cfg.RedisStreamConsumer,
+		count:      int64(max(cfg.RedisStreamReadCount, 1)),
+		block:      cfg.RedisStreamBlock,
+		minIdle:    cfg.RedisStreamClaimMinIdle,
+		claimEvery: cfg.RedisStreamClaimInterval,
+	}
+}
+
+// Start creates the consumer group if needed and begins consuming
+func (r *RedisStreamConsumer) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	err := r.redis.XGroupCreateMkStream(ctx, r.stream, r.group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		cancel()
+		return err
+	}
+
+	r.wg.Add(2)
+	go r.consume(ctx)
+	go r.reclaim(ctx)
+	log.Printf("Redis stream consumer %s started on %s (group %s)", r.consumer, r.stream, r.group)
+	return nil
+}
+
+// Stop finishes the batch in progress and stops consuming
+func (r *RedisStreamConsumer) Stop() {
+	if r.cancel == nil {
+		return
+	}
+	r.cancel()
+	r.wg.Wait()
+}
+
+// Stats returns how many entries were stored and rejected
+func (r *RedisStreamConsumer) Stats() (uint64, uint64) {
+	return r.processed.Load(), r.rejected.Load()
+}
+
+// consume first re-reads this consumer's own pending entries, then new ones
+func (r *RedisStreamConsumer) consume(ctx context.Context) {
+	defer r.wg.Done()
+
+	start := "0"
+	for ctx.Err() == nil {
+		streams, err := r.redis.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    r.group,
+			Consumer: r.consumer,
+			Streams:  []string{r.stream, start},
+			Count:    r.count,
+			Block:    r.block,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			// Nothing new; a history read ending this way has no pending entries left
+			start = ">"
+			continue
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Failed to read Redis stream %s: %v", r.stream, err)
+				sleepCtx(ctx, time.Second)
+			}
+			continue
+		}
+
+		var messages []redis.XMessage
+		for _, stream := range streams {
+			messages = append(messages, stream.Messages...)
+		}
+
+		// Own pending history is exhausted, switch to new entries
+		if start != ">" && len(messages) == 0 {
+			start = ">"
+			continue
+		}
+
+		if err := r.process(ctx, messages); err != nil {
+			log.Printf("Failed to store %d stream entries, they stay pending: %v", len(messages), err)
+			sleepCtx(ctx, time.Second)
+			// Read this consumer's pending entries again before new ones
+			start = "0"
+			continue
+		}
+
+		// Pending entries are re-read until stored; advance past them once acked
+		if start != ">" && len(messages) > 0 {
+			start = messages[len(messages)-1].ID
+		}
+	}
+}
+
+// reclaim takes over entries left pending by consumers that stopped
+func (r *RedisStreamConsumer) reclaim(ctx context.Context) {
+	defer r.wg.Done()
+
+	interval := r.claimEvery
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		start := "0-0"
+		for ctx.Err() == nil {
+			messages, next, err := r.autoClaim(ctx, start)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Failed to reclaim pending stream entries: %v", err)
+				}
+				break
+			}
+
+			if len(messages) > 0 {
+				log.Printf("Reclaimed %d pending stream entries", len(messages))
+				if err := r.process(ctx, messages); err != nil {
+					log.Printf("Failed to store reclaimed stream entries: %v", err)
+					break
+				}
+			}
+			if next == "0-0" || next == "" {
+				break
+			}
+			start = next
+		}
+	}
+}
+
+// autoClaim runs XAUTOCLAIM from start. The reply is parsed here because
+// go-redis v8 only understands the two-element reply of Redis 6.2; Redis 7
+// adds a third element listing deleted entries.
+func (r *RedisStreamConsumer) autoClaim(ctx context.Context, start string) ([]redis.XMessage, string, error) {
+	reply, err := r.redis.Do(ctx, "XAUTOCLAIM", r.stream, r.group, r.consumer,
+		r.minIdle.Milliseconds(), start, "COUNT", r.count).Result()
+	if err != nil {
+		return nil, "", err
+	}
+
+	parts, ok := reply.([]interface{})
+	if !ok || len(parts) < 2 {
+		return nil, "", fmt.Errorf("unexpected XAUTOCLAIM reply %v", reply)
+	}
+	next, _ := parts[0].(string)
+	entries, _ := parts[1].([]interface{})
+
+	messages := make([]redis.XMessage, 0, len(entries))
+	for _, e := range entries {
+		entry, ok := e.([]interface{})
+		if !ok || len(entry) != 2 {
+			continue
+		}
+		id, _ := entry[0].(string)
+		fields, ok := entry[1].([]interface{})
+		if id == "" || !ok {
+			continue // deleted from the stream while pending (Redis 6.2)
+		}
+
+		values := make(map[string]interface{}, len(fields)/2)
+		for i := 0; i+1 < len(fields); i += 2 {
+			if key, ok := fields[i].(string); ok {
+				values[key] = fields[i+1]
+			}
+		}
+		messages = append(messages, redis.XMessage{ID: id, Values: values})
+	}
+	return messages, next, nil
+}
+
+// process validates and stores a batch of entries, then acks them. Invalid
+// entries are acked and dropped; nothing is acked when storing fails.
+func (r *RedisStreamConsumer) process(ctx context.Context, messages []redis.XMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	clients := make(map[string]string) // api_key -> client ID, "" when invalid
+	ids := make([]string, 0, len(messages))
+	entries := make([]clientEntry, 0, len(messages))
+	rejected := 0
+
+	for _, msg := range messages {
+		ids = append(ids, msg.ID)
+
+		// The stream entry time is when the producer recorded the hit
+		received := streamEntryTime(msg.ID)
+		clientID, entry, errMsg := r.decode(msg, clients)
+		if errMsg == "" {
+			errMsg = validateBatchEntry(entry, received, false)
+		}
+		if errMsg != "" {
+			log.Printf("Rejected stream entry %s: %s", msg.ID, errMsg)
+			rejected++
+			continue
+		}
+
+		// Entry IDs double as idempotency keys, so re-delivered entries are not stored twice
+		if entry.EventID == "" {
+			entry.EventID = msg.ID
+		}
+		entries = append(entries, clientEntry{ClientID: clientID, Entry: entry, Received: received})
+	}
+
+	accepted, _, err := r.store(entries)
+	if err != nil {
+		return err
+	}
+
+	if err := r.redis.XAck(ctx, r.stream, r.group, ids...).Err(); err != nil {
+		// Unacked entries are re-delivered and then skipped as duplicates
+		log.Printf("Failed to ack %d stream entries: %v", len(ids), err)
+	}
+
+	r.processed.Add(uint64(accepted))
+	r.rejected.Add(uint64(rejected))
+	return nil
+}
+
+// decode authenticates the entry and parses its data. clients caches API key
+// lookups for the batch.
+func (r *RedisStreamConsumer) decode(msg redis.XMessage, clients map[string]string) (string, LogBatchEntry, string) {
+	var entry LogBatchEntry
+
+	var clientID string
+	if apiKey, _ := msg.Values["api_key"].(string); apiKey != "" {
+		var ok bool
+		if clientID, ok = clients[apiKey]; !ok {
+			clientID, _ = r.validateAPIKey(apiKey)
+			clients[apiKey] = clientID
+		}
+		if clientID == "" {
+			return "", entry, "invalid api_key"
+		}
+	} else if id, _ := msg.Values["client_id"].(string); id != "" {
+		if !r.trusted[id] {
+			return "", entry, "client_id is not trusted on this stream, send api_key"
+		}
+		clientID = id
+	} else {
+		return "", entry, "api_key is required"
+	}
+
+	data, _ := msg.Values["data"].(string)
+	if err := json.Unmarshal([]byte(data), &entry); err != nil {
+		return "", entry, "data must be a JSON log entry"
+	}
+	return clientID, entry, ""
+}
+
+// streamEntryTime returns the time encoded in a stream entry ID such as 1700000000000-0
+func streamEntryTime(id string) time.Time {
+	ms, _, _ := strings.Cut(id, "-")
+	if n, err := strconv.ParseInt(ms, 10, 64); err == nil {
+		return time.UnixMilli(n)
+	}
+	return time.Now()
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	testStream = "tracker:hits"
+	testGroup  = "tracker-ingest"
+)
+
+// fakeStore records stored entries and fails while failing is set
+type fakeStore struct {
+	mu      sync.Mutex
+	entries []clientEntry
+	failing bool
+	calls   int
+}
+
+func (f *fakeStore) store(entries []clientEntry) (int, int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.failing {
+		return 0, 0, errors.New("database unavailable")
+	}
+	f.entries = append(f.entries, entries...)
+	return len(entries), 0, nil
+}
+
+func (f *fakeStore) setFailing(failing bool) {
+	f.mu.Lock()
+	f.failing = failing
+	f.mu.Unlock()
+}
+
+func (f *fakeStore) stored() []clientEntry {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]clientEntry(nil), f.entries...)
+}
+
+func newTestConsumer(t *testing.T, consumer string) (*RedisStreamConsumer, *fakeStore, *redis.Client, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	r, store := consumerOn(client, consumer)
+	return r, store, client, mr
+}
+
+func consumerOn(client *redis.Client, consumer string) (*RedisStreamConsumer, *fakeStore) {
+	store := &fakeStore{}
+	return &RedisStreamConsumer{
+		redis: client,
+		store: store.store,
+		validateAPIKey: func(apiKey string) (string, error) {
+			if apiKey == "key-a" {
+				return "client-a", nil
+			}
+			return "", errors.New("invalid API key")
+		},
+		trusted:    map[string]bool{"client-trusted": true},
+		stream:     testStream,
+		group:      testGroup,
+		consumer:   consumer,
+		count:      10,
+		block:      20 * time.Millisecond,
+		minIdle:    time.Minute,
+		claimEvery: 20 * time.Millisecond,
+	}, store
+}
+
+func addEntry(t *testing.T, client *redis.Client, values map[string]interface{}) string {
+	t.Helper()
+	id, err := client.XAdd(context.Background(), &redis.XAddArgs{Stream: testStream, Values: values}).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func pendingCount(t *testing.T, client *redis.Client) int64 {
+	t.Helper()
+	pending, err := client.XPending(context.Background(), testStream, testGroup).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pending.Count
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRedisStreamStartCreatesGroup(t *testing.T) {
+	r, _, client, _ := newTestConsumer(t, "c1")
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	r.Stop()
+
+	if err := client.XGroupCreate(context.Background(), testStream, testGroup, "0").Err(); err == nil ||
+		!strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		t.Fatalf("creating the group again = %v, want BUSYGROUP", err)
+	}
+
+	// A second instance finds the group already there
+	other, _ := consumerOn(client, "c2")
+	if err := other.Start(); err != nil {
+		t.Fatalf("Start with existing group: %v", err)
+	}
+	other.Stop()
+}
+
+func TestRedisStreamStoresAndAcks(t *testing.T) {
+	r, store, client, _ := newTestConsumer(t, "c1")
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	id := addEntry(t, client, map[string]interface{}{"api_key": "key-a", "data": `{"endpoint":"/a"}`})
+	addEntry(t, client, map[string]interface{}{"api_key": "key-a", "data": `{"endpoint":"/b","event_id":"evt-1"}`})
+
+	waitFor(t, "entries to be stored", func() bool { return len(store.stored()) == 2 })
+	waitFor(t, "entries to be acked", func() bool { return pendingCount(t, client) == 0 })
+
+	entries := store.stored()
+	if entries[0].ClientID != "client-a" || entries[0].Entry.EventID != id {
+		t.Errorf("first entry = %+v, want client-a with the stream ID %s as event_id", entries[0], id)
+	}
+	if entries[1].Entry.EventID != "evt-1" {
+		t.Errorf("second entry event_id = %q, want evt-1", entries[1].Entry.EventID)
+	}
+	if processed, rejected := r.Stats(); processed != 2 || rejected != 0 {
+		t.Errorf("Stats = %d, %d, want 2, 0", processed, rejected)
+	}
+}
+
+func TestRedisStreamAuthentication(t *testing.T) {
+	r, store, client, _ := newTestConsumer(t, "c1")
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+		stored bool
+	}{
+		{"valid api_key", map[string]interface{}{"api_key": "key-a", "data": `{"endpoint":"/a"}`}, true},
+		{"invalid api_key", map[string]interface{}{"api_key": "nope", "data": `{"endpoint":"/a"}`}, false},
+		{"bare client_id", map[string]interface{}{"client_id": "client-a", "data": `{"endpoint":"/a"}`}, false},
+		{"trusted client_id", map[string]interface{}{"client_id": "client-trusted", "data": `{"endpoint":"/a"}`}, true},
+		{"no credential", map[string]interface{}{"data": `{"endpoint":"/a"}`}, false},
+		{"invalid data", map[string]interface{}{"api_key": "key-a", "data": `{`}, false},
+	}
+
+	want := 0
+	for _, tt := range tests {
+		addEntry(t, client, tt.values)
+		if tt.stored {
+			want++
+		}
+	}
+
+	waitFor(t, "all entries to be handled", func() bool {
+		processed, rejected := r.Stats()
+		return int(processed+rejected) == len(tests)
+	})
+
+	stored := store.stored()
+	if len(stored) != want {
+		t.Fatalf("stored %d entries, want %d", len(stored), want)
+	}
+	if stored[0].ClientID != "client-a" || stored[1].ClientID != "client-trusted" {
+		t.Errorf("stored clients = %s, %s, want client-a, client-trusted", stored[0].ClientID, stored[1].ClientID)
+	}
+	if pending := pendingCount(t, client); pending != 0 {
+		t.Errorf("%d entries left pending, rejected entries should be acked", pending)
+	}
+}
+
+func TestRedisStreamRetriesPendingAfterStoreFailure(t *testing.T) {
+	r, store, client, _ := newTestConsumer(t, "c1")
+	store.setFailing(true)
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	addEntry(t, client, map[string]interface{}{"api_key": "key-a", "data": `{"endpoint":"/a"}`})
+
+	waitFor(t, "a failed store", func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return store.calls > 0
+	})
+	if pending := pendingCount(t, client); pending != 1 {
+		t.Fatalf("pending = %d after a failed store, want 1", pending)
+	}
+
+	store.setFailing(false)
+	waitFor(t, "the pending entry to be stored", func() bool { return len(store.stored()) == 1 })
+	waitFor(t, "the pending entry to be acked", func() bool { return pendingCount(t, client) == 0 })
+}
+
+func TestRedisStreamRereadsOwnPendingOnStart(t *testing.T) {
+	_, _, client, _ := newTestConsumer(t, "c1")
+	ctx := context.Background()
+	if err := client.XGroupCreateMkStream(ctx, testStream, testGroup, "0").Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A previous run of c1 read two entries and stopped before acking them
+	addEntry(t, client, map[string]interface{}{"api_key": "key-a", "data": `{"endpoint":"/a"}`})
+	addEntry(t, client, map[string]interface{}{"api_key": "key-a", "data": `{"endpoint":"/b"}`})
+	if err := client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: testGroup, Consumer: "c1", Streams: []string{testStream, ">"}, Count: 10,
+	}).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if pending := pendingCount(t, client); pending != 2 {
+		t.Fatalf("pending = %d, want 2", pending)
+	}
+
+	r, store := consumerOn(client, "c1")
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	addEntry(t, client, map[string]interface{}{"api_key": "key-a", "data": `{"endpoint":"/c"}`})
+
+	waitFor(t, "pending and new entries to be stored", func() bool { return len(store.stored()) == 3 })
+	waitFor(t, "entries to be acked", func() bool { return pendingCount(t, client) == 0 })
+
+	var endpoints []string
+	for _, entry := range store.stored() {
+		endpoints = append(endpoints, entry.Entry.Endpoint)
+	}
+	if endpoints[0] != "/a" || endpoints[1] != "/b" || endpoints[2] != "/c" {
+		t.Errorf("stored %v, want pending entries first", endpoints)
+	}
+}
+
+func TestRedisStreamReclaimsFromDeadConsumer(t *testing.T) {
+	_, _, client, mr := newTestConsumer(t, "live")
+	ctx := context.Background()
+	if err := client.XGroupCreateMkStream(ctx, testStream, testGroup, "0").Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// "dead" read an entry and never acked it
+	addEntry(t, client, map[string]interface{}{"api_key": "key-a", "data": `{"endpoint":"/orphan"}`})
+	if err := client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: testGroup, Consumer: "dead", Streams: []string{testStream, ">"}, Count: 10,
+	}).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, store := consumerOn(client, "live")
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	// Not idle long enough yet
+	time.Sleep(100 * time.Millisecond)
+	if n := len(store.stored()); n != 0 {
+		t.Fatalf("stored %d entries before they were idle for minIdle", n)
+	}
+
+	mr.SetTime(time.Now().Add(2 * time.Minute))
+	waitFor(t, "the orphaned entry to be reclaimed", func() bool { return len(store.stored()) == 1 })
+	waitFor(t, "the reclaimed entry to be acked", func() bool { return pendingCount(t, client) == 0 })
+
+	if got := store.stored()[0].Entry.Endpoint; got != "/orphan" {
+		t.Errorf("reclaimed %s, want /orphan", got)
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"user-activity-tracker/internal/models"
+	"user-activity-tracker/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RouteHandler manages per-client route templates used for endpoint normalization
+type RouteHandler struct {
+	normalizer *services.EndpointNormalizer
+}
+
+func NewRouteHandler(normalizer *services.EndpointNormalizer) *RouteHandler {
+	return &RouteHandler{normalizer: normalizer}
+}
+
+// ListRouteTemplates returns the client's route templates
+// @Summary List route templates
+// @Description List the route templates used to group raw endpoints, e.g. /users/:id
+// @Tags routes
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} RouteTemplatesResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/route-templates [get]
+func (h *RouteHandler) ListRouteTemplates(c *gin.Context) {
+	clientID := c.GetString("client_id")
+
+	templates, err := h.normalizer.Templates(clientID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to fetch route templates"})
+		return
+	}
+
+	resp := RouteTemplatesResponse{ClientID: clientID, Templates: make([]RouteTemplateResponse, 0, len(templates))}
+	for _, template := range templates {
+		resp.Templates = append(resp.Templates, routeTemplateResponse(template))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// CreateRouteTemplate adds a route template for the client
+// @Summary Create a route template
+// @Description Register a template such as /users/:id or /files/* so matching endpoints are aggregated together
+// @Tags routes
+// @Accept json
+// @Produce json
+// @Param request body RouteTemplateRequest true "Route template"
+// @Security ApiKeyAuth
+// @Success 201 {object} RouteTemplateResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/route-templates [post]
+func (h *RouteHandler) CreateRouteTemplate(c *gin.Context) {
+	var req RouteTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+
+	template, err := h.normalizer.AddTemplate(c.GetString("client_id"), req.Template)
+	switch {
+	case errors.Is(err, services.ErrInvalidRouteTemplate), errors.Is(err, services.ErrTooManyRouteTemplates):
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrRouteTemplateDuplicate):
+		c.JSON(http.StatusConflict, ErrorResponse{Error: err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to create route template"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, routeTemplateResponse(*template))
+}
+
+// DeleteRouteTemplate removes one of the client's route templates
+// @Summary Delete a route template
+// @Description Delete a route template; hits already recorded keep their stored template
+// @Tags routes
+// @Produce json
+// @Param id path int true "Route template ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/route-templates/{id} [delete]
+func (h *RouteHandler) DeleteRouteTemplate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid route template ID"})
+		return
+	}
+
+	err = h.normalizer.DeleteTemplate(c.GetString("client_id"), uint(id))
+	switch {
+	case errors.Is(err, services.ErrRouteTemplateNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to delete route template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Message: "Route template deleted"})
+}
+
+type RouteTemplateRequest struct {
+	Template string `json:"template" binding:"required"`
+}
+
+type RouteTemplateResponse struct {
+	ID        uint      `json:"id"`
+	Template  string    `json:"template"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RouteTemplatesResponse struct {
+	ClientID  string                  `json:"client_id"`
+	Templates []RouteTemplateResponse `json:"templates"`
+}
+
+func routeTemplateResponse(template models.RouteTemplate) RouteTemplateResponse {
+	return RouteTemplateResponse{ID: template.ID, Template: template.Template, CreatedAt: template.CreatedAt}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"user-activity-tracker/internal/models"
+	"user-activity-tracker/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SamplingHandler manages the client's sampling policies
+type SamplingHandler struct {
+	sampling *services.SamplingService
+}
+
+func NewSamplingHandler(sampling *services.SamplingService) *SamplingHandler {
+	return &SamplingHandler{sampling: sampling}
+}
+
+// ListSamplingPolicies returns the client's sampling policies
+// @Summary List sampling policies
+// @Description List the sample rates applied at ingestion; an empty endpoint_template is the client default
+// @Tags sampling
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} SamplingPoliciesResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/sampling-policies [get]
+func (h *SamplingHandler) ListSamplingPolicies(c *gin.Context) {
+	clientID := c.GetString("client_id")
+
+	policies, err := h.sampling.Policies(clientID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to fetch sampling policies"})
+		return
+	}
+
+	resp := SamplingPoliciesResponse{ClientID: clientID, Policies: make([]SamplingPolicyResponse, 0, len(policies))}
+	for _, policy := range policies {
+		resp.Policies = append(resp.Policies, samplingPolicyResponse(policy))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// SetSamplingPolicy creates or updates a sampling policy
+// @Summary Set a sampling policy
+// @Description Store only a fraction of hits, for all endpoints or for one endpoint template. Stored rows are weighted so usage totals stay accurate.
+// @Tags sampling
+// @Accept json
+// @Produce json
+// @Param request body SamplingPolicyRequest true "Sampling policy"
+// @Security ApiKeyAuth
+// @Success 200 {object} SamplingPolicyResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/sampling-policies [put]
+func (h *SamplingHandler) SetSamplingPolicy(c *gin.Context) {
+	var req SamplingPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+
+	policy, err := h.sampling.SetPolicy(c.GetString("client_id"), req.EndpointTemplate, req.Rate)
+	switch {
+	case errors.Is(err, services.ErrInvalidSampleRate), errors.Is(err, services.ErrInvalidRouteTemplate):
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to save sampling policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, samplingPolicyResponse(*policy))
+}
+
+// DeleteSamplingPolicy removes a sampling policy
+// @Summary Delete a sampling policy
+// @Description Delete a sampling policy; matching hits fall back to the client default or are all stored
+// @Tags sampling
+// @Produce json
+// @Param id path int true "Sampling policy ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/sampling-policies/{id} [delete]
+func (h *SamplingHandler) DeleteSamplingPolicy(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid sampling policy ID"})
+		return
+	}
+
+	err = h.sampling.DeletePolicy(c.GetString("client_id"), uint(id))
+	switch {
+	case errors.Is(err, services.ErrSamplingPolicyNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to delete sampling policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Message: "Sampling policy deleted"})
+}
+
+type SamplingPolicyRequest struct {
+	EndpointTemplate string  `json:"endpoint_template"`
+	Rate             float64 `json:"rate" binding:"required"`
+}
+
+type SamplingPolicyResponse struct {
+	ID               uint      `json:"id"`
+	EndpointTemplate string    `json:"endpoint_template"`
+	Rate             float64   `json:"rate"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+type SamplingPoliciesResponse struct {
+	ClientID string                   `json:"client_id"`
+	Policies []SamplingPolicyResponse `json:"policies"`
+}
+
+func samplingPolicyResponse(policy models.SamplingPolicy) SamplingPolicyResponse {
+	return SamplingPolicyResponse{
+		ID:               policy.ID,
+		EndpointTemplate: policy.EndpointTemplate,
+		Rate:             policy.Rate,
+		UpdatedAt:        policy.UpdatedAt,
+	}
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"user-activity-tracker/configs"
+	"user-activity-tracker/internal/database"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// sessionLengthBuckets are the exclusive upper bounds, in seconds, of the session
+// length histogram; the first bucket holds sessions of a single instant
+var sessionLengthBuckets = []struct {
+	label string
+	max   int64
+}{
+	{"0s", 0},
+	{"1-59s", 60},
+	{"1-5m", 5 * 60},
+	{"5-15m", 15 * 60},
+	{"15-30m", 30 * 60},
+	{"30-60m", 60 * 60},
+	{"1-3h", 3 * 60 * 60},
+}
+
+const sessionLengthOverflow = ">=3h"
+
+type DaySessions struct {
+	Date               string  `json:"date"`
+	Sessions           int64   `json:"sessions"`
+	Visitors           int64   `json:"visitors"`
+	AvgDurationSeconds float64 `json:"avg_duration_seconds"`
+	AvgHits            float64 `json:"avg_hits"`
+}
+
+type SessionDailyResponse struct {
+	ClientID   string        `json:"client_id"`
+	StartDate  string        `json:"start_date"`
+	EndDate    string        `json:"end_date"`
+	Visitor    string        `json:"visitor,omitempty"`
+	SessionGap string        `json:"session_gap"`
+	Days       []DaySessions `json:"days"`
+}
+
+type SessionLengthBucket struct {
+	Length   string `json:"length"`
+	Sessions int64  `json:"sessions"`
+}
+
+type SessionLengthsResponse struct {
+	ClientID           string                `json:"client_id"`
+	StartDate          string                `json:"start_date"`
+	EndDate            string                `json:"end_date"`
+	Visitor            string                `json:"visitor,omitempty"`
+	SessionGap         string                `json:"session_gap"`
+	TotalSessions      int64                 `json:"total_sessions"`
+	SingleHitSessions  int64                 `json:"single_hit_sessions"`
+	AvgDurationSeconds float64               `json:"avg_duration_seconds"`
+	AvgHits            float64               `json:"avg_hits"`
+	Buckets            []SessionLengthBucket `json:"buckets"`
+}
+
+// GetSessionDaily returns the number and average length of sessions per day
+// @Summary Get sessions per day
+// @Description Sessions started per day over a date range (default the last 7 days), with distinct visitors, average duration and hits. A visitor is the end user when hits carry a user_id, otherwise the IP address. Sessions are built in the background, so the last minutes may be missing.
+// @Tags sessions
+// @Produce json
+// @Param visitor query string false "Only user (hits with a user_id) or ip (hits without) sessions"
+// @Param start_date query string false "First day, YYYY-MM-DD"
+// @Param end_date query string false "Last day, YYYY-MM-DD"
+// @Security ApiKeyAuth
+// @Success 200 {object} SessionDailyResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/sessions/daily [get]
+func (h *ClientHandler) GetSessionDaily(c *gin.Context) {
+	visitor, startDate, endDate, errMsg := parseSessionQuery(c)
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: errMsg})
+		return
+	}
+
+	clientID := c.GetString("client_id")
+	cacheKey := fmt.Sprintf("sessions:daily:%s:%s:%s:%s", clientID, visitor,
+		startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+
+	var response SessionDailyResponse
+	if found, err := h.cache.Get(cacheKey, &response); found && err == nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response = SessionDailyResponse{
+		ClientID:   clientID,
+		StartDate:  startDate.Format("2006-01-02"),
+		EndDate:    endDate.Format("2006-01-02"),
+		Visitor:    visitor,
+		SessionGap: configs.AppConfig.SessionGap.String(),
+		Days:       []DaySessions{},
+	}
+
+	var rows []DaySessions
+	day := "DATE_FORMAT(start_time, '%Y-%m-%d')"
+	err := sessionQuery(clientID, visitor, startDate, endDate).
+		Select(day + " AS date, COUNT(*) AS sessions, " +
+			"COUNT(DISTINCT visitor_key) AS visitors, " +
+			"AVG(TIMESTAMPDIFF(SECOND, start_time, end_time)) AS avg_duration_seconds, " +
+			"AVG(hit_count) AS avg_hits").
+		Group(day).
+		Scan(&rows).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to fetch session data"})
+		return
+	}
+
+	byDate := make(map[string]DaySessions, len(rows))
+	for _, row := range rows {
+		byDate[row.Date] = row
+	}
+	for d := startDate; !d.After(endDate); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		day, ok := byDate[date]
+		if !ok {
+			day = DaySessions{Date: date}
+		}
+		response.Days = append(response.Days, day)
+	}
+
+	h.cache.Set(cacheKey, response, configs.AppConfig.CacheTTL)
+	c.JSON(http.StatusOK, response)
+}
+
+// GetSessionLengths returns how long sessions last
+// @Summary Get session length distribution
+// @Description Histogram of session durations for sessions started in a date range (default the last 7 days), with averages and the number of single-hit sessions
+// @Tags sessions
+// @Produce json
+// @Param visitor query string false "Only user (hits with a user_id) or ip (hits without) sessions"
+// @Param start_date query string false "First day, YYYY-MM-DD"
+// @Param end_date query string false "Last day, YYYY-MM-DD"
+// @Security ApiKeyAuth
+// @Success 200 {object} SessionLengthsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/sessions/lengths [get]
+func (h *ClientHandler) GetSessionLengths(c *gin.Context) {
+	visitor, startDate, endDate, errMsg := parseSessionQuery(c)
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: errMsg})
+		return
+	}
+
+	clientID := c.GetString("client_id")
+	cacheKey := fmt.Sprintf("sessions:lengths:%s:%s:%s:%s", clientID, visitor,
+		startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+
+	var response SessionLengthsResponse
+	if found, err := h.cache.Get(cacheKey, &response); found && err == nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response = SessionLengthsResponse{
+		ClientID:   clientID,
+		StartDate:  startDate.Format("2006-01-02"),
+		EndDate:    endDate.Format("2006-01-02"),
+		Visitor:    visitor,
+		SessionGap: configs.AppConfig.SessionGap.String(),
+		Buckets:    []SessionLengthBucket{},
+	}
+
+	query := sessionQuery(clientID, visitor, startDate, endDate)
+
+	var summary struct {
+		TotalSessions      int64
+		SingleHitSessions  int64
+		AvgDurationSeconds float64
+		AvgHits            float64
+	}
+	err := query.Session(&gorm.Session{}).
+		Select("COUNT(*) AS total_sessions, COALESCE(SUM(hit_count = 1), 0) AS single_hit_sessions, " +
+			"COALESCE(AVG(TIMESTAMPDIFF(SECOND, start_time, end_time)), 0) AS avg_duration_seconds, " +
+			"COALESCE(AVG(hit_count), 0) AS avg_hits").
+		Scan(&summary).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to fetch session data"})
+		return
+	}
+	response.TotalSessions = summary.TotalSessions
+	response.SingleHitSessions = summary.SingleHitSessions
+	response.AvgDurationSeconds = summary.AvgDurationSeconds
+	response.AvgHits = summary.AvgHits
+
+	bucket := "CASE"
+	for _, b := range sessionLengthBuckets {
+		if b.max == 0 {
+			bucket += " WHEN TIMESTAMPDIFF(SECOND, start_time, end_time) = 0 THEN '" + b.label + "'"
+			continue
+		}
+		bucket += fmt.Sprintf(" WHEN TIMESTAMPDIFF(SECOND, start_time, end_time) < %d THEN '%s'", b.max, b.label)
+	}
+	bucket += " ELSE '" + sessionLengthOverflow + "' END"
+
+	var rows []SessionLengthBucket
+	err = query.Select(bucket + " AS length, COUNT(*) AS sessions").
+		Group(bucket).
+		Scan(&rows).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to fetch session data"})
+		return
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Length] = row.Sessions
+	}
+	for _, b := range sessionLengthBuckets {
+		response.Buckets = append(response.Buckets, SessionLengthBucket{Length: b.label, Sessions: counts[b.label]})
+	}
+	response.Buckets = append(response.Buckets, SessionLengthBucket{
+		Length:   sessionLengthOverflow,
+		Sessions: counts[sessionLengthOverflow],
+	})
+
+	h.cache.Set(cacheKey, response, configs.AppConfig.CacheTTL)
+	c.JSON(http.StatusOK, response)
+}
+
+// parseSessionQuery reads the visitor filter and date range of session endpoints
+func parseSessionQuery(c *gin.Context) (string, time.Time, time.Time, string) {
+	visitor := c.Query("visitor")
+	if visitor != "" && visitor != "user" && visitor != "ip" {
+		return "", time.Time{}, time.Time{}, "visitor must be user or ip"
+	}
+	startDate, endDate, errMsg := parseDateRange(c)
+	return visitor, startDate, endDate, errMsg
+}
+
+// sessionQuery selects the client's sessions started within a date range
+func sessionQuery(clientID, visitor string, startDate, endDate time.Time) *gorm.DB {
+	query := database.GetDBManager().GetReadDB().Table("sessions").
+		Where("client_id = ? AND start_time >= ? AND start_time < ?", clientID, startDate, endDate.AddDate(0, 0, 1))
+	switch visitor {
+	case "user":
+		query = query.Where("visitor_key LIKE 'u:%'")
+	case "ip":
+		query = query.Where("visitor_key LIKE 'ip:%'")
+	}
+	return query
+}
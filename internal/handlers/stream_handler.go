@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"user-activity-tracker/configs"
+	"user-activity-tracker/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// streamCounterFlushEvery controls how often cache counters and broadcasts are emitted
+	streamCounterFlushEvery = 500
+	// streamMaxReportedErrors caps the per-line errors included in the response
+	streamMaxReportedErrors = 100
+)
+
+type LogStreamError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+type LogStreamResponse struct {
+	Lines      int              `json:"lines"`
+	Accepted   int              `json:"accepted"`
+	Duplicates int              `json:"duplicates"`
+	Rejected   int              `json:"rejected"`
+	Errors     []LogStreamError `json:"errors,omitempty"`
+	Aborted    string           `json:"aborted,omitempty"`
+}
+
+// RecordLogStream handles NDJSON streaming ingestion
+// @Summary Stream API hits as NDJSON
+// @Description Accepts one JSON log entry per line (application/x-ndjson, optionally gzip-encoded). Records are queued as they arrive; the response summarises the whole stream.
+// @Tags logs
+// @Accept application/x-ndjson
+// @Produce json
+// @Param Content-Encoding header string false "Set to gzip for compressed bodies"
+// @Security ApiKeyAuth
+// @Success 202 {object} LogStreamResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 503 {object} LogStreamResponse
+// @Router /api/logs/stream [post]
+func (h *ClientHandler) RecordLogStream(c *gin.Context) {
+	body, err := decodeLogStream(c.Request.Body, c.GetHeader("Content-Encoding"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid gzip body"})
+		return
+	}
+	defer body.Close()
+
+	cfg := configs.AppConfig
+	ingester := h.newStreamIngester(c.Request.Context(), c.GetString("client_id"), c.ClientIP())
+
+	lines, ingestErr, readErr := scanLogStream(body, max(cfg.StreamMaxLineBytes, 1024), ingester)
+
+	response := ingester.finish()
+	response.Lines = lines
+
+	if ingestErr != nil {
+		response.Aborted = ingestErr.Error()
+		if errors.Is(ingestErr, services.ErrQueueFull) {
+			c.Header("Retry-After", "1")
+		}
+		c.JSON(http.StatusServiceUnavailable, response)
+		return
+	}
+
+	if readErr != nil {
+		if errors.Is(readErr, bufio.ErrTooLong) {
+			response.Aborted = "line exceeds maximum length"
+		} else {
+			response.Aborted = "failed to read request body"
+		}
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, response)
+}
+
+// logStreamSink receives the entries read from an NDJSON stream
+type logStreamSink interface {
+	add(line int, entry LogBatchEntry) error
+	reject(line int, msg string)
+}
+
+// decodeLogStream unwraps a gzip-encoded body
+func decodeLogStream(body io.ReadCloser, contentEncoding string) (io.ReadCloser, error) {
+	if !strings.EqualFold(contentEncoding, "gzip") {
+		return body, nil
+	}
+	return gzip.NewReader(body)
+}
+
+// scanLogStream feeds every non-blank line to the sink and returns the number of
+// lines read. It stops at the first error from the sink (ingestErr) or from
+// reading the body (readErr, bufio.ErrTooLong for lines over maxLineBytes).
+func scanLogStream(body io.Reader, maxLineBytes int, sink logStreamSink) (lines int, ingestErr, readErr error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, min(64*1024, maxLineBytes)), maxLineBytes)
+
+	for scanner.Scan() {
+		lines++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var entry LogBatchEntry
+		if err := json.Unmarshal([]byte(text), &entry); err != nil {
+			sink.reject(lines, "invalid JSON")
+			continue
+		}
+
+		if err := sink.add(lines, entry); err != nil {
+			return lines, err, nil
+		}
+	}
+	return lines, nil, scanner.Err()
+}
+
+// streamIngester queues a sequence of entries from one long-lived request,
+// batching counter updates and broadcasts
+type streamIngester struct {
+	h           *ClientHandler
+	ctx         context.Context
+	clientID    string
+	requestIP   string
+	response    LogStreamResponse
+	dailyCounts map[string]int64
+	pending     int
+}
+
+func (h *ClientHandler) newStreamIngester(ctx context.Context, clientID, requestIP string) *streamIngester {
+	return &streamIngester{
+		h:           h,
+		ctx:         ctx,
+		clientID:    clientID,
+		requestIP:   requestIP,
+		dailyCounts: make(map[string]int64),
+	}
+}
+
+// reject records an invalid entry
+func (s *streamIngester) reject(line int, msg string) {
+	s.response.Rejected++
+	if len(s.response.Errors) < streamMaxReportedErrors {
+		s.response.Errors = append(s.response.Errors, LogStreamError{Line: line, Error: msg})
+	}
+}
+
+// add validates and queues one entry. Invalid entries are recorded as rejected;
+// an error is only returned when the pipeline cannot take more hits.
+func (s *streamIngester) add(line int, entry LogBatchEntry) error {
+	now := time.Now()
+	if errMsg := validateBatchEntry(entry, now, false); errMsg != "" {
+		s.reject(line, errMsg)
+		return nil
+	}
+
+	if entry.EventID != "" {
+		_, duplicate, err := s.h.ingestService.ClaimIdempotencyKey(s.clientID, entry.EventID, entry.EventID)
+		if err != nil {
+			s.reject(line, "failed to check event_id")
+			return nil
+		}
+		if duplicate {
+			s.response.Duplicates++
+			return nil
+		}
+	}
+
+	hit, store := s.h.buildEntryHit(s.clientID, s.requestIP, entry, now)
+	hit.IdempotencyKey = entry.EventID
+
+	// Apply backpressure: wait for queue capacity instead of rejecting immediately
+	if store {
+		ctx, cancel := context.WithTimeout(s.ctx, configs.AppConfig.StreamEnqueueWait)
+		err := s.h.ingestService.EnqueueWait(ctx, hit)
+		cancel()
+		if err != nil {
+			if entry.EventID != "" {
+				s.h.ingestService.ReleaseIdempotencyKey(s.clientID, entry.EventID)
+			}
+			return err
+		}
+	} else if hit.IdempotencyKey != "" {
+		s.h.ingestService.CommitIdempotencyKeys(hit)
+	}
+
+	s.response.Accepted++
+	// buildEntryHit has put the timestamp in server time, as MySQL stores it
+	s.dailyCounts[hit.Timestamp.Format("2006-01-02")]++
+	if store {
+		s.h.leaderboards.Record(hit)
+	}
+	s.pending++
+	if s.pending >= streamCounterFlushEvery {
+		s.flush()
+	}
+	return nil
+}
+
+// flush updates counters and broadcasts for the hits queued since the last flush
+func (s *streamIngester) flush() {
+	if s.pending == 0 {
+		return
+	}
+	s.h.incrementCounters(s.clientID, s.dailyCounts)
+	s.h.cache.PublishUpdate(s.clientID)
+	if s.h.wsHandler != nil {
+		s.h.wsHandler.BroadcastUpdate(s.clientID, map[string]interface{}{
+			"batch_size": s.pending,
+			"timestamp":  time.Now().Unix(),
+		})
+	}
+	s.dailyCounts = make(map[string]int64)
+	s.pending = 0
+}
+
+// finish flushes outstanding updates and returns the stream summary
+func (s *streamIngester) finish() LogStreamResponse {
+	s.flush()
+	return s.response
+}
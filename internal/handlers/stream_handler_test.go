@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"user-activity-tracker/internal/services"
+)
+
+// recordingSink collects what scanLogStream hands to the ingester
+type recordingSink struct {
+	added    []int
+	rejected []int
+	failAt   int
+}
+
+func (s *recordingSink) add(line int, entry LogBatchEntry) error {
+	if line == s.failAt {
+		return services.ErrQueueFull
+	}
+	s.added = append(s.added, line)
+	return nil
+}
+
+func (s *recordingSink) reject(line int, msg string) {
+	s.rejected = append(s.rejected, line)
+}
+
+func gzipBytes(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestScanLogStream(t *testing.T) {
+	entry := `{"endpoint":"/api/users","method":"GET"}`
+	long := `{"endpoint":"/` + strings.Repeat("a", 2048) + `"}`
+
+	tests := []struct {
+		name         string
+		body         string
+		maxLineBytes int
+		failAt       int
+		wantLines    int
+		wantAdded    []int
+		wantRejected []int
+		wantIngest   error
+		wantRead     error
+	}{
+		{
+			name:         "entries",
+			body:         entry + "\n" + entry + "\n",
+			maxLineBytes: 1024,
+			wantLines:    2,
+			wantAdded:    []int{1, 2},
+		},
+		{
+			name:         "last line without newline",
+			body:         entry + "\n" + entry,
+			maxLineBytes: 1024,
+			wantLines:    2,
+			wantAdded:    []int{1, 2},
+		},
+		{
+			name:         "blank and CRLF lines",
+			body:         entry + "\r\n\r\n   \n" + entry + "\r\n",
+			maxLineBytes: 1024,
+			wantLines:    4,
+			wantAdded:    []int{1, 4},
+		},
+		{
+			name:         "invalid JSON is rejected per line",
+			body:         entry + "\n{not json\n" + entry + "\n",
+			maxLineBytes: 1024,
+			wantLines:    3,
+			wantAdded:    []int{1, 3},
+			wantRejected: []int{2},
+		},
+		{
+			name:         "line over the limit stops the stream",
+			body:         entry + "\n" + long + "\n" + entry + "\n",
+			maxLineBytes: 1024,
+			wantLines:    1,
+			wantAdded:    []int{1},
+			wantRead:     bufio.ErrTooLong,
+		},
+		{
+			name:         "line filling the limit with its newline is read",
+			body:         strings.Repeat(" ", 1023-len(entry)) + entry + "\n",
+			maxLineBytes: 1024,
+			wantLines:    1,
+			wantAdded:    []int{1},
+		},
+		{
+			name:         "ingest failure aborts",
+			body:         entry + "\n" + entry + "\n" + entry + "\n",
+			maxLineBytes: 1024,
+			failAt:       2,
+			wantLines:    2,
+			wantAdded:    []int{1},
+			wantIngest:   services.ErrQueueFull,
+		},
+		{
+			name:         "empty body",
+			body:         "",
+			maxLineBytes: 1024,
+			wantLines:    0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &recordingSink{failAt: tt.failAt}
+			lines, ingestErr, readErr := scanLogStream(strings.NewReader(tt.body), tt.maxLineBytes, sink)
+
+			if lines != tt.wantLines {
+				t.Errorf("lines = %d, want %d", lines, tt.wantLines)
+			}
+			if !slices.Equal(sink.added, tt.wantAdded) {
+				t.Errorf("added lines = %v, want %v", sink.added, tt.wantAdded)
+			}
+			if !slices.Equal(sink.rejected, tt.wantRejected) {
+				t.Errorf("rejected lines = %v, want %v", sink.rejected, tt.wantRejected)
+			}
+			if !errors.Is(ingestErr, tt.wantIngest) {
+				t.Errorf("ingestErr = %v, want %v", ingestErr, tt.wantIngest)
+			}
+			if !errors.Is(readErr, tt.wantRead) {
+				t.Errorf("readErr = %v, want %v", readErr, tt.wantRead)
+			}
+		})
+	}
+}
+
+func TestDecodeLogStream(t *testing.T) {
+	entry := `{"endpoint":"/api/users"}` + "\n"
+	compressed := gzipBytes(t, entry+entry)
+
+	tests := []struct {
+		name        string
+		body        []byte
+		encoding    string
+		wantOpenErr bool
+		wantLines   int
+		wantReadErr bool
+	}{
+		{name: "identity", body: []byte(entry), encoding: "", wantLines: 1},
+		{name: "gzip", body: compressed, encoding: "gzip", wantLines: 2},
+		{name: "gzip header is case-insensitive", body: compressed, encoding: "GZIP", wantLines: 2},
+		{name: "not gzip", body: []byte(entry), encoding: "gzip", wantOpenErr: true},
+		{name: "truncated gzip", body: compressed[:len(compressed)-6], encoding: "gzip", wantReadErr: true, wantLines: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := decodeLogStream(io.NopCloser(bytes.NewReader(tt.body)), tt.encoding)
+			if tt.wantOpenErr {
+				if err == nil {
+					t.Fatal("expected an error opening the stream")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeLogStream: %v", err)
+			}
+			defer body.Close()
+
+			sink := &recordingSink{}
+			lines, _, readErr := scanLogStream(body, 1<<20, sink)
+			if lines != tt.wantLines {
+				t.Errorf("lines = %d, want %d", lines, tt.wantLines)
+			}
+			if (readErr != nil) != tt.wantReadErr {
+				t.Errorf("readErr = %v, want error: %v", readErr, tt.wantReadErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"user-activity-tracker/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultTopClients = 3
+	defaultTopEntries = 10
+)
+
+// leaderboardQuery selects a leaderboard
+type leaderboardQuery struct {
+	limit  int
+	window services.Window
+	metric string
+}
+
+type TopEndpointsResponse struct {
+	ClientID    string                  `json:"client_id"`
+	Period      string                  `json:"period"`
+	Window      string                  `json:"window"`
+	Metric      string                  `json:"metric"`
+	GeneratedAt time.Time               `json:"generated_at"`
+	Endpoints   []services.EndpointRank `json:"endpoints"`
+}
+
+type TopIPsResponse struct {
+	ClientID    string            `json:"client_id"`
+	Period      string            `json:"period"`
+	Window      string            `json:"window"`
+	Metric      string            `json:"metric"`
+	IPPrivacy   string            `json:"ip_privacy"`
+	GeneratedAt time.Time         `json:"generated_at"`
+	IPs         []services.IPRank `json:"ips"`
+}
+
+// GetTopEndpoints returns the client's busiest endpoints
+// @Summary Get top endpoints
+// @Description The client's route templates (raw endpoints for hits recorded before normalization) ranked by requests, distinct IP addresses or errors (4xx/5xx) over a window ending now. Request leaderboards of whole-minute windows up to 24h come from real-time counters and carry no ips or errors.
+// @Tags usage
+// @Produce json
+// @Param limit query int false "Number of endpoints (default 10, max 100)"
+// @Param window query string false "1h, 24h (default), 7d, 30d or a custom duration such as 90m, 6h or 14d (max 90d)"
+// @Param metric query string false "requests (default), ips or errors"
+// @Security ApiKeyAuth
+// @Success 200 {object} TopEndpointsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/usage/top/endpoints [get]
+func (h *ClientHandler) GetTopEndpoints(c *gin.Context) {
+	query, errMsg := parseLeaderboardQuery(c.Query("limit"), c.Query("window"), c.Query("metric"), defaultTopEntries, services.LeaderboardMetrics)
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: errMsg})
+		return
+	}
+
+	clientID := c.GetString("client_id")
+	board, err := h.leaderboards.TopEndpoints(clientID, query.window, query.metric)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to fetch top endpoints"})
+		return
+	}
+
+	endpoints := board.Endpoints
+	if len(endpoints) > query.limit {
+		endpoints = endpoints[:query.limit]
+	}
+	if endpoints == nil {
+		endpoints = []services.EndpointRank{}
+	}
+
+	c.JSON(http.StatusOK, TopEndpointsResponse{
+		ClientID:    clientID,
+		Period:      query.window.Period,
+		Window:      query.window.Name,
+		Metric:      query.metric,
+		GeneratedAt: board.GeneratedAt,
+		Endpoints:   endpoints,
+	})
+}
+
+// GetTopIPs returns the IP addresses the client's traffic comes from most
+// @Summary Get top IP addresses
+// @Description The client's IP addresses ranked by requests or errors (4xx/5xx) over a window ending now. Addresses are shown as the client's ip_privacy mode allows, so under truncated they are /24 or /48 networks and under none the list is empty.
+// @Tags usage
+// @Produce json
+// @Param limit query int false "Number of addresses (default 10, max 100)"
+// @Param window query string false "1h, 24h (default), 7d, 30d or a custom duration such as 90m, 6h or 14d (max 90d)"
+// @Param metric query string false "requests (default) or errors"
+// @Security ApiKeyAuth
+// @Success 200 {object} TopIPsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/usage/top/ips [get]
+func (h *ClientHandler) GetTopIPs(c *gin.Context) {
+	query, errMsg := parseLeaderboardQuery(c.Query("limit"), c.Query("window"), c.Query("metric"), defaultTopEntries,
+		services.IPLeaderboardMetrics)
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: errMsg})
+		return
+	}
+
+	clientID := c.GetString("client_id")
+	board, err := h.leaderboards.TopIPs(clientID, query.window, query.metric)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to fetch top IP addresses"})
+		return
+	}
+
+	mode := h.ipPrivacy.Mode(clientID)
+	response := TopIPsResponse{
+		ClientID:    clientID,
+		Period:      query.window.Period,
+		Window:      query.window.Name,
+		Metric:      query.metric,
+		IPPrivacy:   mode,
+		GeneratedAt: board.GeneratedAt,
+		IPs:         []services.IPRank{},
+	}
+
+	// Addresses stored before the mode was tightened are masked now, so several
+	// stored addresses may become one; the board holds more rows than requested
+	merged := make(map[string]*services.IPRank)
+	for _, row := range board.IPs {
+		ip := services.AnonymizeIP(mode, clientID, row.IPAddress)
+		if ip == "" {
+			continue
+		}
+		rank, ok := merged[ip]
+		if !ok {
+			rank = &services.IPRank{IPAddress: ip}
+			merged[ip] = rank
+		}
+		rank.RequestCount += row.RequestCount
+		rank.Errors += row.Errors
+	}
+	for _, rank := range merged {
+		rank.Value = services.MetricValue(query.metric, rank.RequestCount, 0, rank.Errors)
+		response.IPs = append(response.IPs, *rank)
+	}
+	sort.Slice(response.IPs, func(i, j int) bool {
+		if response.IPs[i].Value != response.IPs[j].Value {
+			return response.IPs[i].Value > response.IPs[j].Value
+		}
+		return response.IPs[i].IPAddress < response.IPs[j].IPAddress
+	})
+	if len(response.IPs) > query.limit {
+		response.IPs = response.IPs[:query.limit]
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// parseLeaderboardQuery reads the limit, window and metric of a leaderboard;
+// the window defaults to 24h and the metric to requests
+func parseLeaderboardQuery(limitValue, windowValue, metric string, defaultLimit int, metrics []string) (leaderboardQuery, string) {
+	query := leaderboardQuery{limit: defaultLimit, metric: metric}
+
+	if limitValue != "" {
+		n, err := strconv.Atoi(limitValue)
+		if err != nil || n < 1 || n > services.MaxLeaderboardSize {
+			return leaderboardQuery{}, fmt.Sprintf("limit must be between 1 and %d", services.MaxLeaderboardSize)
+		}
+		query.limit = n
+	}
+
+	if windowValue == "" {
+		windowValue = "24h"
+	}
+	window, err := services.ParseWindow(windowValue)
+	if err != nil {
+		return leaderboardQuery{}, err.Error()
+	}
+	query.window = window
+
+	if query.metric == "" {
+		query.metric = services.MetricRequests
+	}
+	for _, m := range metrics {
+		if m == query.metric {
+			return query, ""
+		}
+	}
+	return leaderboardQuery{}, "metric must be one of: " + strings.Join(metrics, ", ")
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Usage series granularities
+const (
+	GranularityHour  = "hour"
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+// usageBucketLimits holds the default and maximum number of buckets of a
+// usage series per granularity
+var usageBucketLimits = map[string]struct{ defaults, max int }{
+	GranularityHour:  {24, 7 * 24},
+	GranularityDay:   {7, 366},
+	GranularityWeek:  {12, 104},
+	GranularityMonth: {12, 60},
+}
+
+// slotLayout is the format of the time slots usage is grouped by in SQL.
+// Stored timestamps are in server local time (loc=Local in the DSN).
+const slotLayout = "2006-01-02 15:04"
+
+// usageRange is the period and bucketing of a usage series. Buckets start at
+// the requested granularity's boundaries in the requested time zone; weeks
+// start on Monday.
+type usageRange struct {
+	granularity string
+	loc         *time.Location
+	starts      []time.Time
+	end         time.Time
+}
+
+// slotCount is the weighted number of hits in one time slot
+type slotCount struct {
+	Slot         string
+	RequestCount int64
+}
+
+// parseUsageRange reads the from, to, tz and granularity parameters of usage
+// series. from and to are dates (YYYY-MM-DD, to inclusive) or RFC 3339 times
+// and are widened to whole buckets; by default the series ends with the
+// current bucket.
+func parseUsageRange(from, to, tz, granularity string) (usageRange, string) {
+	r := usageRange{granularity: granularity, loc: time.Local}
+	if r.granularity == "" {
+		r.granularity = GranularityDay
+	}
+	limits, ok := usageBucketLimits[r.granularity]
+	if !ok {
+		return usageRange{}, "granularity must be hour, day, week or month"
+	}
+	if tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return usageRange{}, "tz must be an IANA time zone such as Europe/Berlin"
+		}
+		r.loc = loc
+	}
+
+	if to == "" {
+		r.end = r.next(r.floor(time.Now()))
+	} else {
+		t, dateOnly, err := parseUsageTime(to, r.loc)
+		if err != nil {
+			return usageRange{}, "to must be a date (YYYY-MM-DD) or an RFC 3339 time"
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		r.end = r.ceil(t)
+	}
+
+	var start time.Time
+	if from == "" {
+		start = r.end
+		for i := 0; i < limits.defaults; i++ {
+			start = r.floor(start.Add(-time.Nanosecond))
+		}
+	} else {
+		t, _, err := parseUsageTime(from, r.loc)
+		if err != nil {
+			return usageRange{}, "from must be a date (YYYY-MM-DD) or an RFC 3339 time"
+		}
+		start = r.floor(t)
+	}
+	if !start.Before(r.end) {
+		return usageRange{}, "from must be before to"
+	}
+
+	for b := start; b.Before(r.end); b = r.next(b) {
+		if len(r.starts) == limits.max {
+			return usageRange{}, fmt.Sprintf("at most %d %s buckets can be requested", limits.max, r.granularity)
+		}
+		r.starts = append(r.starts, b)
+	}
+	return r, ""
+}
+
+// parseUsageTime parses a date in loc or an RFC 3339 time
+func parseUsageTime(value string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
+
+// floor returns the start of the bucket containing t
+func (r usageRange) floor(t time.Time) time.Time {
+	t = t.In(r.loc)
+	switch r.granularity {
+	case GranularityHour:
+		// Subtracting rather than rebuilding the time keeps the repeated hour of a DST change apart
+		return t.Add(-time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	case GranularityWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, r.loc)
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case GranularityMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, r.loc)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, r.loc)
+}
+
+// ceil returns t when it is a bucket boundary, otherwise the next boundary
+func (r usageRange) ceil(t time.Time) time.Time {
+	if start := r.floor(t); !start.Equal(t) {
+		return r.next(start)
+	}
+	return t
+}
+
+// next returns the start of the bucket after the one starting at b
+func (r usageRange) next(b time.Time) time.Time {
+	switch r.granularity {
+	case GranularityHour:
+		return b.Add(time.Hour)
+	case GranularityWeek:
+		return b.AddDate(0, 0, 7)
+	case GranularityMonth:
+		return b.AddDate(0, 1, 0)
+	}
+	return b.AddDate(0, 0, 1)
+}
+
+func (r usageRange) start() time.Time {
+	return r.starts[0]
+}
+
+// label names the bucket starting at b; hours carry their UTC offset so the
+// repeated hour of a DST change stays distinct
+func (r usageRange) label(b time.Time) string {
+	switch r.granularity {
+	case GranularityHour:
+		return b.Format(time.RFC3339)
+	case GranularityMonth:
+		return b.Format("2006-01")
+	}
+	return b.Format("2006-01-02")
+}
+
+// add counts hits at t into their bucket
+func (r usageRange) add(counts []int64, t time.Time, n int64) {
+	if t.Before(r.start()) || !t.Before(r.end) {
+		return
+	}
+	i := sort.Search(len(r.starts), func(i int) bool { return r.starts[i].After(t) })
+	counts[i-1] += n
+}
+
+// addSlots counts grouped slot rows into their buckets
+func (r usageRange) addSlots(counts []int64, slots []slotCount) {
+	for _, slot := range slots {
+		t, err := time.ParseInLocation(slotLayout, slot.Slot, time.Local)
+		if err == nil {
+			r.add(counts, t, slot.RequestCount)
+		}
+	}
+}
+
+// boundaries returns every bucket boundary, including the end
+func (r usageRange) boundaries() []time.Time {
+	return append(r.starts[:len(r.starts):len(r.starts)], r.end)
+}
+
+// alignedWithDays reports whether every bucket is made of whole server-local
+// days, so pre-aggregated daily_usage rows can be counted into it
+func (r usageRange) alignedWithDays() bool {
+	if r.granularity == GranularityHour {
+		return false
+	}
+	for _, b := range r.boundaries() {
+		local := b.In(time.Local)
+		if local.Hour() != 0 || local.Minute() != 0 || local.Second() != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// slotExpr groups a timestamp column into slots no bucket boundary falls
+// within: hours, or quarter hours for time zones offset by a fraction of an
+// hour from the server
+func (r usageRange) slotExpr(column string) string {
+	for _, b := range r.boundaries() {
+		if b.In(time.Local).Minute() != 0 {
+			return fmt.Sprintf("CONCAT(DATE_FORMAT(%[1]s, '%%Y-%%m-%%d %%H:'), LPAD(FLOOR(MINUTE(%[1]s) / 15) * 15, 2, '0'))", column)
+		}
+	}
+	return fmt.Sprintf("DATE_FORMAT(%s, '%%Y-%%m-%%d %%H:00')", column)
+}
+
+// cacheKey identifies the range in usage cache keys
+func (r usageRange) cacheKey() string {
+	return r.granularity + ":" + r.loc.String() + ":" +
+		strconv.FormatInt(r.start().Unix(), 10) + ":" + strconv.FormatInt(r.end.Unix(), 10)
+}
+
+// series turns bucket counts into usage entries
+func (r usageRange) series(counts []int64) []DayUsage {
+	usage := make([]DayUsage, 0, len(r.starts))
+	for i, b := range r.starts {
+		usage = append(usage, DayUsage{Date: r.label(b), RequestCount: counts[i]})
+	}
+	return usage
+}
+
+// response builds a usage response without label groups
+func (r usageRange) response(clientID string, counts []int64) DailyUsageResponse {
+	return DailyUsageResponse{
+		ClientID:    clientID,
+		StartDate:   r.start().Format("2006-01-02"),
+		EndDate:     r.end.Add(-time.Nanosecond).In(r.loc).Format("2006-01-02"),
+		From:        r.start(),
+		To:          r.end.In(r.loc),
+		Timezone:    r.loc.String(),
+		Granularity: r.granularity,
+		Usage:       r.series(counts),
+	}
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// withLocal runs the test with loc as the server time zone
+func withLocal(t *testing.T, loc *time.Location) {
+	t.Helper()
+	saved := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = saved })
+}
+
+func labels(r usageRange) []string {
+	var out []string
+	for _, b := range r.starts {
+		out = append(out, r.label(b))
+	}
+	return out
+}
+
+func TestParseUsageRange(t *testing.T) {
+	withLocal(t, time.UTC)
+
+	tests := []struct {
+		name                      string
+		from, to, tz, granularity string
+		labels                    []string
+		end                       string // RFC 3339
+	}{
+		{
+			name: "days, to inclusive",
+			from: "2024-03-01", to: "2024-03-03",
+			labels: []string{"2024-03-01", "2024-03-02", "2024-03-03"},
+			end:    "2024-03-04T00:00:00Z",
+		},
+		{
+			name: "hours widened to whole buckets",
+			from: "2024-03-01T10:30:00Z", to: "2024-03-01T12:10:00Z", granularity: GranularityHour,
+			labels: []string{"2024-03-01T10:00:00Z", "2024-03-01T11:00:00Z", "2024-03-01T12:00:00Z"},
+			end:    "2024-03-01T13:00:00Z",
+		},
+		{
+			name: "weeks start on Monday",
+			from: "2024-03-06", to: "2024-03-12", granularity: GranularityWeek,
+			labels: []string{"2024-03-04", "2024-03-11"},
+			end:    "2024-03-18T00:00:00Z",
+		},
+		{
+			name: "months",
+			from: "2024-01-15", to: "2024-03-02", granularity: GranularityMonth,
+			labels: []string{"2024-01", "2024-02", "2024-03"},
+			end:    "2024-04-01T00:00:00Z",
+		},
+		{
+			name: "dates are read in the requested zone",
+			from: "2024-03-01", to: "2024-03-01", tz: "America/New_York",
+			labels: []string{"2024-03-01"},
+			end:    "2024-03-02T05:00:00Z",
+		},
+		{
+			name: "RFC 3339 times are bucketed in the requested zone",
+			from: "2024-03-01T20:00:00Z", to: "2024-03-01T20:00:00Z", tz: "Asia/Tokyo",
+			labels: []string{"2024-03-02"},
+			end:    "2024-03-02T15:00:00Z",
+		},
+		{
+			name: "half-hour offset",
+			from: "2024-03-01", to: "2024-03-02", tz: "Asia/Kolkata",
+			labels: []string{"2024-03-01", "2024-03-02"},
+			end:    "2024-03-02T18:30:00Z",
+		},
+		{
+			name: "quarter-hour offset",
+			from: "2024-03-01T18:10:00Z", to: "2024-03-01T18:20:00Z", tz: "Asia/Kathmandu",
+			labels: []string{"2024-03-01", "2024-03-02"},
+			end:    "2024-03-02T18:15:00Z",
+		},
+		{
+			name: "hours keep the offset of a half-hour zone",
+			from: "2024-03-01T00:00:00Z", to: "2024-03-01T01:00:00Z", tz: "Asia/Kolkata", granularity: GranularityHour,
+			labels: []string{"2024-03-01T05:00:00+05:30", "2024-03-01T06:00:00+05:30"},
+			end:    "2024-03-01T01:30:00Z",
+		},
+		{
+			name: "the repeated hour of a DST change is its own bucket",
+			from: "2024-10-27T00:00:00Z", to: "2024-10-27T02:00:00Z", tz: "Europe/Berlin", granularity: GranularityHour,
+			labels: []string{"2024-10-27T02:00:00+02:00", "2024-10-27T02:00:00+01:00"},
+			end:    "2024-10-27T02:00:00Z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, msg := parseUsageRange(tt.from, tt.to, tt.tz, tt.granularity)
+			if msg != "" {
+				t.Fatalf("parseUsageRange: %s", msg)
+			}
+			if got := labels(r); strings.Join(got, ",") != strings.Join(tt.labels, ",") {
+				t.Errorf("buckets = %v, want %v", got, tt.labels)
+			}
+			end, _ := time.Parse(time.RFC3339, tt.end)
+			if !r.end.Equal(end) {
+				t.Errorf("end = %s, want %s", r.end.UTC().Format(time.RFC3339), tt.end)
+			}
+		})
+	}
+}
+
+func TestParseUsageRangeDefaults(t *testing.T) {
+	for granularity, limits := range usageBucketLimits {
+		t.Run(granularity, func(t *testing.T) {
+			r, msg := parseUsageRange("", "", "", granularity)
+			if msg != "" {
+				t.Fatalf("parseUsageRange: %s", msg)
+			}
+			if len(r.starts) != limits.defaults {
+				t.Errorf("%d buckets, want %d", len(r.starts), limits.defaults)
+			}
+			if now := time.Now(); r.end.Before(now) || !r.starts[len(r.starts)-1].Before(now) {
+				t.Errorf("the series does not end with the current bucket: %v - %v", r.starts[len(r.starts)-1], r.end)
+			}
+		})
+	}
+
+	if r, _ := parseUsageRange("", "", "", ""); r.granularity != GranularityDay {
+		t.Errorf("default granularity = %q, want day", r.granularity)
+	}
+}
+
+func TestParseUsageRangeErrors(t *testing.T) {
+	tests := []struct {
+		name                      string
+		from, to, tz, granularity string
+		want                      string
+	}{
+		{"unknown granularity", "", "", "", "minute", "granularity must be"},
+		{"unknown time zone", "", "", "Mars/Olympus", "", "tz must be"},
+		{"bad from", "yesterday", "2024-03-01", "", "", "from must be a date"},
+		{"bad to", "2024-03-01", "03/02/2024", "", "", "to must be a date"},
+		{"from after to", "2024-03-05", "2024-03-01", "", "", "from must be before to"},
+		{"empty range", "2024-03-01T10:00:00Z", "2024-03-01T10:00:00Z", "UTC", GranularityHour, "from must be before to"},
+		{"too many buckets", "2023-01-01", "2024-03-01", "", "", "at most 366 day buckets"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, msg := parseUsageRange(tt.from, tt.to, tt.tz, tt.granularity)
+			if !strings.HasPrefix(msg, tt.want) {
+				t.Errorf("message = %q, want it to start with %q", msg, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsageRangeSlots(t *testing.T) {
+	withLocal(t, time.UTC)
+
+	tests := []struct {
+		tz      string
+		aligned bool
+		quarter bool
+		slots   []slotCount
+		want    []int64
+	}{
+		{
+			tz:      "UTC",
+			aligned: true,
+			slots: []slotCount{
+				{"2024-02-29 23:00", 100}, // before the range
+				{"2024-03-01 00:00", 1},
+				{"2024-03-01 23:00", 2},
+				{"2024-03-02 00:00", 4},
+				{"2024-03-03 00:00", 100}, // at the end
+			},
+			want: []int64{3, 4},
+		},
+		{
+			tz:      "Asia/Kolkata",
+			quarter: true,
+			slots: []slotCount{
+				{"2024-02-29 18:15", 100},
+				{"2024-02-29 18:30", 1},
+				{"2024-03-01 18:15", 2},
+				{"2024-03-01 18:30", 4},
+				{"2024-03-02 18:30", 100},
+			},
+			want: []int64{3, 4},
+		},
+		{
+			tz:      "Asia/Kathmandu",
+			quarter: true,
+			slots: []slotCount{
+				{"2024-02-29 18:00", 100},
+				{"2024-02-29 18:15", 1},
+				{"2024-03-01 18:00", 2},
+				{"2024-03-01 18:15", 4},
+				{"2024-03-02 18:15", 100},
+				{"not a slot", 100},
+			},
+			want: []int64{3, 4},
+		},
+		{
+			tz: "America/New_York",
+			slots: []slotCount{
+				{"2024-03-01 04:00", 100},
+				{"2024-03-01 05:00", 1},
+				{"2024-03-02 04:00", 2},
+				{"2024-03-02 05:00", 4},
+			},
+			want: []int64{3, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tz, func(t *testing.T) {
+			r, msg := parseUsageRange("2024-03-01", "2024-03-02", tt.tz, GranularityDay)
+			if msg != "" {
+				t.Fatalf("parseUsageRange: %s", msg)
+			}
+			if got := r.alignedWithDays(); got != tt.aligned {
+				t.Errorf("alignedWithDays = %v, want %v", got, tt.aligned)
+			}
+			if got := strings.Contains(r.slotExpr("timestamp"), "MINUTE("); got != tt.quarter {
+				t.Errorf("slotExpr uses quarter hours = %v, want %v", got, tt.quarter)
+			}
+
+			counts := make([]int64, len(r.starts))
+			r.addSlots(counts, tt.slots)
+			for i := range tt.want {
+				if counts[i] != tt.want[i] {
+					t.Errorf("counts = %v, want %v", counts, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestUsageRangeCacheKey(t *testing.T) {
+	utc, _ := parseUsageRange("2024-03-01", "2024-03-02", "UTC", "")
+	kolkata, _ := parseUsageRange("2024-03-01", "2024-03-02", "Asia/Kolkata", "")
+	weeks, _ := parseUsageRange("2024-03-01", "2024-03-02", "UTC", GranularityWeek)
+
+	if utc.cacheKey() == kolkata.cacheKey() || utc.cacheKey() == weeks.cacheKey() {
+		t.Errorf("cache keys collide: %q, %q, %q", utc.cacheKey(), kolkata.cacheKey(), weeks.cacheKey())
+	}
+	if resp := kolkata.response("client", []int64{1, 2}); resp.StartDate != "2024-03-01" || resp.EndDate != "2024-03-02" || resp.Timezone != "Asia/Kolkata" {
+		t.Errorf("response = %+v", resp)
+	}
+}
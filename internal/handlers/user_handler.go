@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"user-activity-tracker/configs"
+	"user-activity-tracker/internal/cache"
+	"user-activity-tracker/internal/database"
+	"user-activity-tracker/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultTimelineLimit = 100
+	maxTimelineLimit     = 1000
+)
+
+// UserHandler reports on the end users of a client
+type UserHandler struct {
+	users     *services.UserIdentityService
+	ipPrivacy *services.IPPrivacyService
+	cache     *cache.CacheManager
+}
+
+func NewUserHandler(users *services.UserIdentityService, ipPrivacy *services.IPPrivacyService) *UserHandler {
+	return &UserHandler{
+		users:     users,
+		ipPrivacy: ipPrivacy,
+		cache:     cache.GetCacheManager(),
+	}
+}
+
+// GetUserIDSettings returns how the client's end-user IDs are stored
+// @Summary Get user ID storage
+// @Description Whether user_id values sent with hits are stored hashed (the default) or raw
+// @Tags users
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} UserIDSettings
+// @Failure 401 {object} ErrorResponse
+// @Router /api/settings/user-ids [get]
+func (h *UserHandler) GetUserIDSettings(c *gin.Context) {
+	clientID := c.GetString("client_id")
+	c.JSON(http.StatusOK, UserIDSettings{ClientID: clientID, Storage: h.users.Storage(clientID)})
+}
+
+// SetUserIDSettings changes how the client's end-user IDs are stored
+// @Summary Set user ID storage
+// @Description Store user_id values hashed or raw from now on. IDs already stored keep their previous form.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body UserIDSettings true "Storage setting"
+// @Security ApiKeyAuth
+// @Success 200 {object} UserIDSettings
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/settings/user-ids [put]
+func (h *UserHandler) SetUserIDSettings(c *gin.Context) {
+	var req UserIDSettings
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+
+	clientID := c.GetString("client_id")
+	err := h.users.SetStorage(clientID, req.Storage)
+	switch {
+	case errors.Is(err, services.ErrInvalidUserIDStorage):
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to save user ID settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, UserIDSettings{ClientID: clientID, Storage: req.Storage})
+}
+
+// GetTopUsers returns the client's busiest end users
+// @Summary Get top users
+// @Description End users with the most requests over a date range (default the last 7 days). IDs are returned in their stored form, hashed unless the client stores raw IDs.
+// @Tags users
+// @Produce json
+// @Param start_date query string false "First day, YYYY-MM-DD"
+// @Param end_date query string false "Last day, YYYY-MM-DD"
+// @Param limit query int false "Maximum rows (default 50, max 500)"
+// @Security ApiKeyAuth
+// @Success 200 {object} TopUsersResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/users/top [get]
+func (h *UserHandler) GetTopUsers(c *gin.Context) {
+	startDate, endDate, errMsg := parseDateRange(c)
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: errMsg})
+		return
+	}
+	limit, errMsg := parseBreakdownLimit(c)
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: errMsg})
+		return
+	}
+
+	clientID := c.GetString("client_id")
+	cacheKey := fmt.Sprintf("users:top:%s:%s:%s:%d", clientID,
+		startDate.Format("2006-01-02"), endDate.Format("2006-01-02"), limit)
+
+	var response TopUsersResponse
+	if found, err := h.cache.Get(cacheKey, &response); found && err == nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response = TopUsersResponse{
+		ClientID:  clientID,
+		StartDate: startDate.Format("2006-01-02"),
+		EndDate:   endDate.Format("2006-01-02"),
+		Users:     []UserUsage{},
+	}
+
+	readDB := database.GetDBManager().GetReadDB()
+	err := userHits(readDB, clientID, startDate, endDate).
+		Select("user_id, CAST(ROUND(SUM(sample_weight)) AS SIGNED) AS requests, MIN(timestamp) AS first_seen, MAX(timestamp) AS last_seen").
+		Group("user_id").
+		Order("requests DESC").
+		Limit(limit).
+		Scan(&response.Users).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to fetch user activity"})
+		return
+	}
+
+	h.cache.Set(cacheKey, response, configs.AppConfig.CacheTTL)
+	c.JSON(http.StatusOK, response)
+}
+
+// GetActiveUsers returns the number of distinct end users per day
+// @Summary Get daily active users
+// @Description Distinct end users per day over a date range (default the last 7 days). Under sampling, only users with a stored hit are counted.
+// @Tags users
+// @Produce json
+// @Param start_date query string false "First day, YYYY-MM-DD"
+// @Param end_date query string false "Last day, YYYY-MM-DD"
+// @Security ApiKeyAuth
+// @Success 200 {object} ActiveUsersResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/users/active [get]
+func (h *UserHandler) GetActiveUsers(c *gin.Context) {
+	startDate, endDate, errMsg := parseDateRange(c)
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: errMsg})
+		return
+	}
+
+	clientID := c.GetString("client_id")
+	cacheKey := fmt.Sprintf("users:active:%s:%s:%s", clientID,
+		startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+
+	var response ActiveUsersResponse
+	if found, err := h.cache.Get(cacheKey, &response); found && err == nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response = ActiveUsersResponse{
+		ClientID:  clientID,
+		StartDate: startDate.Format("2006-01-02"),
+		EndDate:   endDate.Format("2006-01-02"),
+		Days:      []DayActiveUsers{},
+	}
+
+	readDB := database.GetDBManager().GetReadDB()
+	query := userHits(readDB, clientID, startDate, endDate)
+
+	err := query.Session(&gorm.Session{}).
+		Select("COUNT(DISTINCT user_id)").
+		Scan(&response.TotalUsers).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to fetch user activity"})
+		return
+	}
+
+	var rows []DayActiveUsers
+	day := "DATE_FORMAT(timestamp, '%Y-%m-%d')"
+	err = query.Select(day + " AS date, COUNT(DISTINCT user_id) AS active_users").
+		Group(day).
+		Scan(&rows).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to fetch user activity"})
+		return
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Date] = row.ActiveUsers
+	}
+	for d := startDate; !d.After(endDate); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		response.Days = append(response.Days, DayActiveUsers{Date: date, ActiveUsers: counts[date]})
+	}
+
+	h.cache.Set(cacheKey, response, configs.AppConfig.CacheTTL)
+	c.JSON(http.StatusOK, response)
+}
+
+// GetUserTimeline returns one end user's hits, newest first
+// @Summary Get a user's activity timeline
+// @Description Hits of one end user over a date range (default the last 7 days), newest first. Pass the user_id as it was sent with the hits, or its stored hash with hashed=true. IP addresses follow the client's current ip_privacy mode. Pass next_cursor back as cursor for older hits.
+// @Tags users
+// @Produce json
+// @Param user_id query string true "End-user ID"
+// @Param hashed query bool false "user_id is already the stored hash, e.g. from /api/users/top"
+// @Param start_date query string false "First day, YYYY-MM-DD"
+// @Param end_date query string false "Last day, YYYY-MM-DD"
+// @Param limit query int false "Maximum hits (default 100, max 1000)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Security ApiKeyAuth
+// @Success 200 {object} UserTimelineResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/users/timeline [get]
+func (h *UserHandler) GetUserTimeline(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" || len(userID) > 128 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "user_id is required and must not exceed 128 characters"})
+		return
+	}
+
+	startDate, endDate, errMsg := parseDateRange(c)
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: errMsg})
+		return
+	}
+
+	limit := defaultTimelineLimit
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxTimelineLimit {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("limit must be between 1 and %d", maxTimelineLimit)})
+			return
+		}
+		limit = n
+	}
+
+	clientID := c.GetString("client_id")
+	storedID := userID
+	if c.Query("hashed") != "true" {
+		storedID = h.users.StoredUserID(clientID, userID)
+	}
+
+	readDB := database.GetDBManager().GetReadDB()
+	query := readDB.Table("api_logs").
+		Select("id, event_id, timestamp, endpoint, COALESCE(endpoint_template, '') AS endpoint_template, "+
+			"COALESCE(method, '') AS method, status_code, latency_ms, ip_address, COALESCE(user_agent, '') AS user_agent").
+		Where("client_id = ? AND user_id = ? AND timestamp >= ? AND timestamp < ?",
+			clientID, storedID, startDate, endDate.AddDate(0, 0, 1))
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		before, id, ok := parseTimelineCursor(cursor)
+		if !ok {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "cursor is invalid"})
+			return
+		}
+		query = query.Where("(timestamp < ? OR (timestamp = ? AND id < ?))", before, before, id)
+	}
+
+	// One extra row tells whether there is another page
+	var hits []TimelineHit
+	err := query.Order("timestamp DESC, id DESC").Limit(limit + 1).Scan(&hits).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to fetch user activity"})
+		return
+	}
+
+	response := UserTimelineResponse{
+		ClientID:  clientID,
+		UserID:    storedID,
+		StartDate: startDate.Format("2006-01-02"),
+		EndDate:   endDate.Format("2006-01-02"),
+		Hits:      hits,
+	}
+	if response.Hits == nil {
+		response.Hits = []TimelineHit{}
+	}
+	if len(hits) > limit {
+		last := hits[limit-1]
+		response.Hits = hits[:limit]
+		response.NextCursor = fmt.Sprintf("%d-%d", last.Timestamp.Unix(), last.ID)
+	}
+	for i := range response.Hits {
+		response.Hits[i].IPAddress = h.ipPrivacy.Mask(clientID, response.Hits[i].IPAddress)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// userHits selects the client's hits that carry a user ID within a date range
+func userHits(db *gorm.DB, clientID string, startDate, endDate time.Time) *gorm.DB {
+	return db.Table("api_logs").
+		Where("client_id = ? AND user_id IS NOT NULL AND user_id <> '' AND timestamp >= ? AND timestamp < ?",
+			clientID, startDate, endDate.AddDate(0, 0, 1))
+}
+
+// parseTimelineCursor splits a "<unix seconds>-<id>" cursor
+func parseTimelineCursor(cursor string) (time.Time, uint64, bool) {
+	secs, id, ok := strings.Cut(cursor, "-")
+	if !ok {
+		return time.Time{}, 0, false
+	}
+	s, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	return time.Unix(s, 0), n, true
+}
+
+type UserIDSettings struct {
+	ClientID string `json:"client_id,omitempty"`
+	Storage  string `json:"user_id_storage"`
+}
+
+type UserUsage struct {
+	UserID    string    `json:"user_id"`
+	Requests  int64     `json:"requests"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+type TopUsersResponse struct {
+	ClientID  string      `json:"client_id"`
+	StartDate string      `json:"start_date"`
+	EndDate   string      `json:"end_date"`
+	Users     []UserUsage `json:"users"`
+}
+
+type DayActiveUsers struct {
+	Date        string `json:"date"`
+	ActiveUsers int64  `json:"active_users"`
+}
+
+type ActiveUsersResponse struct {
+	ClientID   string           `json:"client_id"`
+	StartDate  string           `json:"start_date"`
+	EndDate    string           `json:"end_date"`
+	TotalUsers int64            `json:"total_users"`
+	Days       []DayActiveUsers `json:"days"`
+}
+
+type TimelineHit struct {
+	ID               uint64    `json:"-"`
+	EventID          string    `json:"hit_id"`
+	Timestamp        time.Time `json:"timestamp"`
+	Endpoint         string    `json:"endpoint"`
+	EndpointTemplate string    `json:"endpoint_template,omitempty"`
+	Method           string    `json:"method,omitempty"`
+	StatusCode       *uint16   `json:"status_code,omitempty"`
+	LatencyMs        *uint32   `json:"latency_ms,omitempty"`
+	IPAddress        string    `json:"ip_address"`
+	UserAgent        string    `json:"user_agent,omitempty"`
+}
+
+type UserTimelineResponse struct {
+	ClientID   string        `json:"client_id"`
+	UserID     string        `json:"user_id"`
+	StartDate  string        `json:"start_date"`
+	EndDate    string        `json:"end_date"`
+	Hits       []TimelineHit `json:"hits"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
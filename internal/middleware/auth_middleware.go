@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -14,6 +15,47 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+var (
+	ErrAuthRequired     = errors.New("Authentication required")
+	ErrInvalidAPIKey    = errors.New("Invalid API key")
+	ErrInvalidToken     = errors.New("Invalid token")
+	ErrIPNotWhitelisted = errors.New("IP not whitelisted")
+)
+
+// Authenticate validates either an API key or a JWT token and returns the client ID.
+// clientIP is only consulted when the IP whitelist is enabled.
+func Authenticate(authService *services.AuthService, apiKey, tokenString, clientIP string) (string, error) {
+	if apiKey != "" {
+		client, err := authService.ValidateAPIKey(apiKey)
+		if err != nil {
+			return "", ErrInvalidAPIKey
+		}
+
+		// Check IP whitelist if enabled
+		if configs.AppConfig.EnableIPWhitelist {
+			// Consider validating IP format
+			if net.ParseIP(clientIP) == nil {
+				clientIP = strings.Split(clientIP, ":")[0] // Handle port if present
+			}
+			if !authService.CheckIPWhitelist(client, clientIP) {
+				return "", ErrIPNotWhitelisted
+			}
+		}
+
+		return client.ClientID, nil
+	}
+
+	if tokenString != "" {
+		claims, err := authService.ValidateToken(tokenString)
+		if err != nil {
+			return "", ErrInvalidToken
+		}
+		return claims.ClientID, nil
+	}
+
+	return "", ErrAuthRequired
+}
+
 func AuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get API key from header or query parameter
@@ -30,40 +72,13 @@ func AuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
 		}
 
 		// Validate either API key or JWT token
-		var clientID string
-		if apiKey != "" {
-			client, err := authService.ValidateAPIKey(apiKey)
-			if err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
-				c.Abort()
-				return
-			}
-
-			// Check IP whitelist if enabled
-			if configs.AppConfig.EnableIPWhitelist {
-				clientIP := authService.GetClientIPv4(c)
-				// Consider validating IP format
-				if net.ParseIP(clientIP) == nil {
-					clientIP = strings.Split(clientIP, ":")[0] // Handle port if present
-				}
-				if !authService.CheckIPWhitelist(client, clientIP) {
-					c.JSON(http.StatusForbidden, gin.H{"error": "IP not whitelisted"})
-					c.Abort()
-					return
-				}
-			}
-
-			clientID = client.ClientID
-		} else if tokenString != "" {
-			claims, err := authService.ValidateToken(tokenString)
-			if err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-				c.Abort()
-				return
+		clientID, err := Authenticate(authService, apiKey, tokenString, authService.GetClientIPv4(c))
+		if err != nil {
+			status := http.StatusUnauthorized
+			if errors.Is(err, ErrIPNotWhitelisted) {
+				status = http.StatusForbidden
 			}
-			clientID = claims.ClientID
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			c.JSON(status, gin.H{"error": err.Error()})
 			c.Abort()
 			return
 		}
@@ -76,6 +91,29 @@ func AuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
 	}
 }
 
+// CheckRateLimit counts one request for the client in the current hour and
+// reports how many remain. If the cache fails the request is allowed.
+func CheckRateLimit(cache *cache.CacheManager, clientID string) (int, bool) {
+	limit := configs.AppConfig.RateLimitPerHour
+	key := fmt.Sprintf("rate_limit:%s:%s", clientID, time.Now().Format("2006-01-02-15"))
+
+	count, err := cache.Increment(key, 1)
+	if err != nil {
+		// If cache fails, continue without rate limiting
+		return limit, true
+	}
+
+	// Set expiration if this is the first request
+	if count == 1 {
+		cache.Set(key, count, time.Hour)
+	}
+
+	if count > int64(limit) {
+		return 0, false
+	}
+	return limit - int(count), true
+}
+
 func RateLimitMiddleware(cache *cache.CacheManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		clientID, exists := c.Get("client_id")
@@ -85,21 +123,8 @@ func RateLimitMiddleware(cache *cache.CacheManager) gin.HandlerFunc {
 			return
 		}
 
-		key := fmt.Sprintf("rate_limit:%s:%s", clientID, time.Now().Format("2006-01-02-15"))
-
-		count, err := cache.Increment(key, 1)
-		if err != nil {
-			// If cache fails, continue without rate limiting
-			c.Next()
-			return
-		}
-
-		// Set expiration if this is the first request
-		if count == 1 {
-			cache.Set(key, count, time.Hour)
-		}
-
-		if count > int64(configs.AppConfig.RateLimitPerHour) {
+		remaining, allowed := CheckRateLimit(cache, clientID.(string))
+		if !allowed {
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error":     "Rate limit exceeded",
 				"limit":     configs.AppConfig.RateLimitPerHour,
@@ -110,22 +135,59 @@ func RateLimitMiddleware(cache *cache.CacheManager) gin.HandlerFunc {
 		}
 
 		c.Header("X-RateLimit-Limit", fmt.Sprintf("%d", configs.AppConfig.RateLimitPerHour))
-		c.Header("X-RateLimit-Remaining", fmt.Sprintf("%d", configs.AppConfig.RateLimitPerHour-int(count)))
+		c.Header("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
+
+		c.Next()
+	}
+}
+
+// BackfillPermissionMiddleware only lets through clients allowed to backfill historical hits
+func BackfillPermissionMiddleware(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client, err := authService.GetClient(c.GetString("client_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Client not authenticated"})
+			c.Abort()
+			return
+		}
+
+		if !client.AllowBackfill {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Backfill permission required"})
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
 }
 
-func ValidationMiddleware() gin.HandlerFunc {
+// ValidationMiddleware requires JSON bodies on POST/PUT requests. extraTypes maps
+// route paths to additional content types those routes accept.
+func ValidationMiddleware(extraTypes map[string][]string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Content-Type validation for POST/PUT requests
 		if c.Request.Method == http.MethodPost || c.Request.Method == http.MethodPut {
 			contentType := c.GetHeader("Content-Type")
-			if !strings.Contains(contentType, "application/json") {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Content-Type must be application/json"})
-				c.Abort()
+			if strings.Contains(contentType, "application/json") {
+				c.Next()
 				return
 			}
+
+			allowed := extraTypes[c.FullPath()]
+			for _, t := range allowed {
+				if strings.Contains(contentType, t) {
+					c.Next()
+					return
+				}
+			}
+
+			message := "Content-Type must be application/json"
+			if len(allowed) > 0 {
+				message = fmt.Sprintf("Content-Type must be one of: application/json, %s", strings.Join(allowed, ", "))
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": message})
+			c.Abort()
+			return
 		}
 		c.Next()
 	}
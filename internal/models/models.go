@@ -10,8 +10,18 @@ type Client struct {
 	Email       string `gorm:"type:varchar(255);uniqueIndex;not null"`
 	APIKey      string `gorm:"type:varchar(255);uniqueIndex;not null"`
 	IPWhitelist string `gorm:"type:text"`
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+
+	// Permissions
+	AllowBackfill bool `gorm:"not null;default:false"`
+
+	// How end-user IDs are stored: hashed or raw
+	UserIDStorage string `gorm:"type:varchar(10);not null;default:hashed"`
+
+	// How IP addresses are stored: full, truncated, hashed or none
+	IPPrivacy string `gorm:"column:ip_privacy;type:varchar(10);not null;default:full"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
 
 func (Client) TableName() string {
@@ -22,9 +32,52 @@ func (Client) TableName() string {
 type APILogs struct {
 	ID        uint64    `gorm:"primaryKey;autoIncrement"`
 	ClientID  string    `gorm:"type:varchar(100);index:idx_client_time;not null"`
+	EventID   string    `gorm:"type:varchar(64);index:idx_client_event"`
 	Endpoint  string    `gorm:"type:varchar(500);not null"`
 	IPAddress string    `gorm:"type:varchar(45);not null"`
 	Timestamp time.Time `gorm:"index:idx_timestamp;not null"`
+
+	// Set once IPAddress no longer holds the full address
+	IPAnonymized bool `gorm:"column:ip_anonymized;not null;default:false"`
+
+	// End user of the client the hit was made for, stored hashed or raw
+	UserID string `gorm:"type:varchar(128);index:idx_client_user"`
+
+	// Route template the raw endpoint was normalized into, e.g. /users/:id
+	EndpointTemplate string `gorm:"type:varchar(500);index:idx_client_template"`
+
+	// Number of hits this row stands for when the client is sampled (1 / sample rate)
+	SampleWeight float64 `gorm:"type:double;not null;default:1"`
+
+	// Optional request details reported by the client
+	Method        string  `gorm:"type:varchar(10)"`
+	StatusCode    *uint16 `gorm:"type:smallint unsigned"`
+	LatencyMs     *uint32 `gorm:"type:int unsigned"`
+	RequestBytes  *uint64 `gorm:"type:bigint unsigned"`
+	ResponseBytes *uint64 `gorm:"type:bigint unsigned"`
+	UserAgent     string  `gorm:"type:varchar(512)"`
+
+	// Location of the IP address, filled in from the GeoIP databases
+	Country string  `gorm:"type:char(2)"`
+	Region  string  `gorm:"type:varchar(100)"`
+	ASN     *uint32 `gorm:"column:asn;type:int unsigned"`
+	ASOrg   string  `gorm:"column:as_org;type:varchar(255)"`
+
+	// Families parsed from the user agent
+	Browser        string `gorm:"type:varchar(64)"`
+	BrowserVersion string `gorm:"type:varchar(20)"`
+	OS             string `gorm:"column:os;type:varchar(32)"`
+	OSVersion      string `gorm:"column:os_version;type:varchar(20)"`
+	DeviceType     string `gorm:"type:varchar(16)"`
+	IsBot          bool   `gorm:"not null;default:false"`
+
+	// Custom labels, persisted separately in api_log_labels
+	Labels map[string]string `gorm:"-"`
+
+	// Idempotency key claimed for the hit, committed to ingest_idempotency in
+	// the transaction that stores it
+	IdempotencyKey string `gorm:"-"`
+
 	CreatedAt time.Time
 }
 
@@ -32,6 +85,119 @@ func (APILogs) TableName() string {
 	return "api_logs"
 }
 
+// One custom label of an API hit, e.g. env=prod
+type APILogLabel struct {
+	LogID     uint64    `gorm:"primaryKey;autoIncrement:false"`
+	Key       string    `gorm:"column:label_key;type:varchar(64);primaryKey;index:idx_client_label,priority:2;index:idx_label_time,priority:1"`
+	Value     string    `gorm:"column:label_value;type:varchar(128);not null;index:idx_client_label,priority:3;index:idx_label_time,priority:2"`
+	ClientID  string    `gorm:"type:varchar(100);not null;index:idx_client_label,priority:1"`
+	Timestamp time.Time `gorm:"not null;index:idx_client_label,priority:4;index:idx_label_time,priority:3"`
+
+	SampleWeight float64 `gorm:"type:double;not null;default:1"`
+}
+
+func (APILogLabel) TableName() string {
+	return "api_log_labels"
+}
+
+// Label values seen per client, used to enforce cardinality limits
+type ClientLabelValue struct {
+	ClientID  string `gorm:"type:varchar(100);primaryKey"`
+	Key       string `gorm:"column:label_key;type:varchar(64);primaryKey"`
+	Value     string `gorm:"column:label_value;type:varchar(128);primaryKey"`
+	CreatedAt time.Time
+}
+
+func (ClientLabelValue) TableName() string {
+	return "client_label_values"
+}
+
+// Per-client sampling rate, either the client default (empty template) or for one endpoint template
+type SamplingPolicy struct {
+	ID               uint    `gorm:"primaryKey;autoIncrement"`
+	ClientID         string  `gorm:"type:varchar(100);uniqueIndex:idx_client_template;not null"`
+	EndpointTemplate string  `gorm:"type:varchar(500);uniqueIndex:idx_client_template;not null;default:''"`
+	Rate             float64 `gorm:"type:double;not null"`
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+func (SamplingPolicy) TableName() string {
+	return "sampling_policies"
+}
+
+// Per-client rules for redacting endpoints before they are stored
+type RedactionRules struct {
+	ClientID     string `gorm:"type:varchar(100);primaryKey"`
+	QueryMode    string `gorm:"type:varchar(5);not null;default:deny"` // deny or allow
+	QueryParams  string `gorm:"type:text"`                             // JSON array of parameter names
+	Patterns     string `gorm:"type:text"`                             // JSON array of regular expressions
+	DetectEmails bool   `gorm:"not null"`
+	DetectJWTs   bool   `gorm:"column:detect_jwts;not null"`
+	UpdatedAt    time.Time
+}
+
+func (RedactionRules) TableName() string {
+	return "redaction_rules"
+}
+
+// Per-client route templates used to normalize endpoints
+type RouteTemplate struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement"`
+	ClientID  string `gorm:"type:varchar(100);uniqueIndex:idx_client_template;not null"`
+	Template  string `gorm:"type:varchar(500);uniqueIndex:idx_client_template;not null"`
+	CreatedAt time.Time
+}
+
+func (RouteTemplate) TableName() string {
+	return "route_templates"
+}
+
+// A run of hits by one visitor (end user, else IP address) without an inactivity gap
+type Session struct {
+	ID            uint64    `gorm:"primaryKey;autoIncrement"`
+	ClientID      string    `gorm:"type:varchar(100);index:idx_client_visitor,priority:1;index:idx_client_start,priority:1;not null"`
+	VisitorKey    string    `gorm:"type:varchar(140);index:idx_client_visitor,priority:2;not null"`
+	UserID        string    `gorm:"type:varchar(128)"`
+	IPAddress     string    `gorm:"type:varchar(45)"`
+	IPAnonymized  bool      `gorm:"column:ip_anonymized;not null;default:false"`
+	StartTime     time.Time `gorm:"index:idx_client_start,priority:2;index:idx_client_visitor,priority:3;not null"`
+	EndTime       time.Time `gorm:"not null"`
+	HitCount      float64   `gorm:"type:double;not null;default:0"`
+	EntryEndpoint string    `gorm:"type:varchar(500)"`
+	ExitEndpoint  string    `gorm:"type:varchar(500)"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (Session) TableName() string {
+	return "sessions"
+}
+
+// Position of the sessionizer in api_logs; a single row
+type SessionProgress struct {
+	ID        uint   `gorm:"primaryKey"`
+	LastLogID uint64 `gorm:"not null;default:0"`
+	UpdatedAt time.Time
+}
+
+func (SessionProgress) TableName() string {
+	return "session_progress"
+}
+
+// Idempotency keys used to deduplicate retried log submissions
+type IdempotencyKey struct {
+	ID        uint64 `gorm:"primaryKey;autoIncrement"`
+	ClientID  string `gorm:"type:varchar(100);uniqueIndex:idx_client_key;not null"`
+	Key       string `gorm:"column:idempotency_key;type:varchar(255);uniqueIndex:idx_client_key;not null"`
+	EventID   string `gorm:"type:varchar(64);not null"`
+	CreatedAt time.Time
+}
+
+func (IdempotencyKey) TableName() string {
+	return "ingest_idempotency"
+}
+
 // Daily Usage Aggregation
 type DailyUsage struct {
 	ID           uint      `gorm:"primaryKey;autoIncrement"`
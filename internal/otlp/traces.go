@@ -0,0 +1,291 @@
+// Package otlp decodes OTLP/HTTP trace export requests (opentelemetry.proto.
+// collector.trace.v1) in both their protobuf and JSON encodings, keeping only
+// what is needed to turn spans into API hits. Protobuf bodies are decoded with
+// the generated OTLP types; the JSON encoding differs from protojson (hex trace
+// and span IDs) and is decoded here.
+package otlp
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// SpanKindServer marks spans that describe an incoming request
+const SpanKindServer = int32(tracepb.Span_SPAN_KIND_SERVER)
+
+// ResourceSpans are the spans emitted by one resource, e.g. a service instance
+type ResourceSpans struct {
+	Resource map[string]string
+	Spans    []Span
+}
+
+// Span holds the fields of a span used for hit tracking. Attribute values are
+// rendered as strings; array, map and bytes values are dropped.
+type Span struct {
+	TraceID           []byte
+	SpanID            []byte
+	Name              string
+	Kind              int32
+	StartTimeUnixNano uint64
+	EndTimeUnixNano   uint64
+	Attributes        map[string]string
+	StatusCode        int32
+}
+
+// UnmarshalProto decodes a protobuf ExportTraceServiceRequest
+func UnmarshalProto(b []byte) ([]ResourceSpans, error) {
+	var req coltracepb.ExportTraceServiceRequest
+	if err := proto.Unmarshal(b, &req); err != nil {
+		return nil, err
+	}
+
+	result := make([]ResourceSpans, 0, len(req.GetResourceSpans()))
+	for _, prs := range req.GetResourceSpans() {
+		rs := ResourceSpans{Resource: protoAttributes(prs.GetResource().GetAttributes())}
+		for _, scope := range prs.GetScopeSpans() {
+			for _, ps := range scope.GetSpans() {
+				rs.Spans = append(rs.Spans, Span{
+					TraceID:           ps.GetTraceId(),
+					SpanID:            ps.GetSpanId(),
+					Name:              ps.GetName(),
+					Kind:              int32(ps.GetKind()),
+					StartTimeUnixNano: ps.GetStartTimeUnixNano(),
+					EndTimeUnixNano:   ps.GetEndTimeUnixNano(),
+					Attributes:        protoAttributes(ps.GetAttributes()),
+					StatusCode:        int32(ps.GetStatus().GetCode()),
+				})
+			}
+		}
+		result = append(result, rs)
+	}
+	return result, nil
+}
+
+// protoAttributes renders the scalar attribute values as strings
+func protoAttributes(kvs []*commonpb.KeyValue) map[string]string {
+	attrs := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		switch v := kv.GetValue().GetValue().(type) {
+		case *commonpb.AnyValue_StringValue:
+			attrs[kv.GetKey()] = v.StringValue
+		case *commonpb.AnyValue_BoolValue:
+			attrs[kv.GetKey()] = strconv.FormatBool(v.BoolValue)
+		case *commonpb.AnyValue_IntValue:
+			attrs[kv.GetKey()] = strconv.FormatInt(v.IntValue, 10)
+		case *commonpb.AnyValue_DoubleValue:
+			attrs[kv.GetKey()] = strconv.FormatFloat(v.DoubleValue, 'f', -1, 64)
+		}
+	}
+	return attrs
+}
+
+// JSON encoding, see https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+
+type jsonRequest struct {
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes []jsonKeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []struct {
+			Spans []jsonSpan `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+type jsonSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	Name              string          `json:"name"`
+	Kind              json.RawMessage `json:"kind"`
+	StartTimeUnixNano jsonNumber      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   jsonNumber      `json:"endTimeUnixNano"`
+	Attributes        []jsonKeyValue  `json:"attributes"`
+	Status            struct {
+		Code json.RawMessage `json:"code"`
+	} `json:"status"`
+}
+
+type jsonKeyValue struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue *string     `json:"stringValue"`
+		BoolValue   *bool       `json:"boolValue"`
+		IntValue    *jsonNumber `json:"intValue"`
+		DoubleValue *float64    `json:"doubleValue"`
+	} `json:"value"`
+}
+
+// jsonNumber accepts 64-bit integers encoded either as JSON numbers or strings
+type jsonNumber string
+
+func (n *jsonNumber) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var num json.Number
+		if err := json.Unmarshal(b, &num); err != nil {
+			return err
+		}
+		s = num.String()
+	}
+	*n = jsonNumber(s)
+	return nil
+}
+
+// UnmarshalJSON decodes a JSON ExportTraceServiceRequest
+func UnmarshalJSON(b []byte) ([]ResourceSpans, error) {
+	var req jsonRequest
+	if err := json.Unmarshal(b, &req); err != nil {
+		return nil, err
+	}
+
+	result := make([]ResourceSpans, 0, len(req.ResourceSpans))
+	for _, jrs := range req.ResourceSpans {
+		rs := ResourceSpans{Resource: jsonAttributes(jrs.Resource.Attributes)}
+		for _, scope := range jrs.ScopeSpans {
+			for _, js := range scope.Spans {
+				span, err := js.span()
+				if err != nil {
+					return nil, err
+				}
+				rs.Spans = append(rs.Spans, span)
+			}
+		}
+		result = append(result, rs)
+	}
+	return result, nil
+}
+
+func (js jsonSpan) span() (Span, error) {
+	span := Span{
+		Name:       js.Name,
+		Kind:       jsonEnum(js.Kind, spanKinds),
+		Attributes: jsonAttributes(js.Attributes),
+		StatusCode: jsonEnum(js.Status.Code, statusCodes),
+	}
+
+	var err error
+	if span.TraceID, err = hex.DecodeString(js.TraceID); err != nil {
+		return span, errors.New("traceId must be hex encoded")
+	}
+	if span.SpanID, err = hex.DecodeString(js.SpanID); err != nil {
+		return span, errors.New("spanId must be hex encoded")
+	}
+	if span.StartTimeUnixNano, err = js.StartTimeUnixNano.uint64(); err != nil {
+		return span, errors.New("startTimeUnixNano is invalid")
+	}
+	if span.EndTimeUnixNano, err = js.EndTimeUnixNano.uint64(); err != nil {
+		return span, errors.New("endTimeUnixNano is invalid")
+	}
+	return span, nil
+}
+
+func (n jsonNumber) uint64() (uint64, error) {
+	if n == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(string(n), 10, 64)
+}
+
+func jsonAttributes(kvs []jsonKeyValue) map[string]string {
+	attrs := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		v := kv.Value
+		switch {
+		case v.StringValue != nil:
+			attrs[kv.Key] = *v.StringValue
+		case v.BoolValue != nil:
+			attrs[kv.Key] = strconv.FormatBool(*v.BoolValue)
+		case v.IntValue != nil:
+			attrs[kv.Key] = string(*v.IntValue)
+		case v.DoubleValue != nil:
+			attrs[kv.Key] = strconv.FormatFloat(*v.DoubleValue, 'f', -1, 64)
+		}
+	}
+	return attrs
+}
+
+var (
+	spanKinds = map[string]int32{
+		"SPAN_KIND_UNSPECIFIED": 0,
+		"SPAN_KIND_INTERNAL":    1,
+		"SPAN_KIND_SERVER":      2,
+		"SPAN_KIND_CLIENT":      3,
+		"SPAN_KIND_PRODUCER":    4,
+		"SPAN_KIND_CONSUMER":    5,
+	}
+	statusCodes = map[string]int32{
+		"STATUS_CODE_UNSET": 0,
+		"STATUS_CODE_OK":    1,
+		"STATUS_CODE_ERROR": 2,
+	}
+)
+
+// jsonEnum decodes an enum sent as its number or, leniently, as its name
+func jsonEnum(raw json.RawMessage, names map[string]int32) int32 {
+	var n int32
+	if json.Unmarshal(raw, &n) == nil {
+		return n
+	}
+	var name string
+	if json.Unmarshal(raw, &name) == nil {
+		return names[name]
+	}
+	return 0
+}
+
+// MarshalResponseProto encodes an ExportTraceServiceResponse, reporting a
+// partial success when spans were rejected
+func MarshalResponseProto(rejected int64, message string) []byte {
+	resp := &coltracepb.ExportTraceServiceResponse{}
+	if rejected != 0 || message != "" {
+		resp.PartialSuccess = &coltracepb.ExportTracePartialSuccess{
+			RejectedSpans: rejected,
+			ErrorMessage:  message,
+		}
+	}
+	b, _ := proto.Marshal(resp)
+	return b
+}
+
+// MarshalStatusProto encodes a google.rpc.Status, the body of OTLP error responses
+func MarshalStatusProto(code int32, message string) []byte {
+	b, _ := proto.Marshal(&rpcstatus.Status{Code: code, Message: message})
+	return b
+}
+
+// PartialSuccess is the JSON form of ExportTracePartialSuccess
+type PartialSuccess struct {
+	RejectedSpans string `json:"rejectedSpans,omitempty"`
+	ErrorMessage  string `json:"errorMessage,omitempty"`
+}
+
+// Response is the JSON form of ExportTraceServiceResponse
+type Response struct {
+	PartialSuccess *PartialSuccess `json:"partialSuccess,omitempty"`
+}
+
+// Status is the JSON form of google.rpc.Status
+type Status struct {
+	Code    int32  `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// NewResponse builds the JSON response for an export
+func NewResponse(rejected int64, message string) Response {
+	if rejected == 0 && message == "" {
+		return Response{}
+	}
+	partial := &PartialSuccess{ErrorMessage: message}
+	if rejected != 0 {
+		partial.RejectedSpans = strconv.FormatInt(rejected, 10)
+	}
+	return Response{PartialSuccess: partial}
+}
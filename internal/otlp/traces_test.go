@@ -0,0 +1,208 @@
+package otlp
+
+import (
+	"bytes"
+	"testing"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func stringAttr(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+func TestUnmarshalProto(t *testing.T) {
+	traceID := bytes.Repeat([]byte{0xab}, 16)
+	spanID := bytes.Repeat([]byte{0xcd}, 8)
+
+	req := &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+				stringAttr("tracker.client_id", "client-a"),
+				stringAttr("service.name", "api"),
+			}},
+			ScopeSpans: []*tracepb.ScopeSpans{{
+				Spans: []*tracepb.Span{
+					{
+						TraceId:           traceID,
+						SpanId:            spanID,
+						Name:              "GET /users/{id}",
+						Kind:              tracepb.Span_SPAN_KIND_SERVER,
+						StartTimeUnixNano: 1_700_000_000_000_000_000,
+						EndTimeUnixNano:   1_700_000_000_250_000_000,
+						Attributes: []*commonpb.KeyValue{
+							stringAttr("http.route", "/users/{id}"),
+							{Key: "http.response.status_code", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: 404}}},
+							{Key: "retry", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: true}}},
+							{Key: "ratio", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: 0.5}}},
+							{Key: "tags", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{}}},
+						},
+						Status: &tracepb.Status{Code: tracepb.Status_STATUS_CODE_ERROR},
+					},
+					{Name: "db query", Kind: tracepb.Span_SPAN_KIND_CLIENT},
+				},
+			}},
+		}},
+	}
+	b, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resources, err := UnmarshalProto(b)
+	if err != nil {
+		t.Fatalf("UnmarshalProto: %v", err)
+	}
+	if len(resources) != 1 || len(resources[0].Spans) != 2 {
+		t.Fatalf("decoded %+v, want one resource with two spans", resources)
+	}
+	if got := resources[0].Resource["tracker.client_id"]; got != "client-a" {
+		t.Errorf("resource client = %q, want client-a", got)
+	}
+
+	span := resources[0].Spans[0]
+	if !bytes.Equal(span.TraceID, traceID) || !bytes.Equal(span.SpanID, spanID) {
+		t.Errorf("IDs = %x/%x, want %x/%x", span.TraceID, span.SpanID, traceID, spanID)
+	}
+	if span.Kind != SpanKindServer || span.StatusCode != 2 {
+		t.Errorf("kind = %d, status = %d, want %d and 2", span.Kind, span.StatusCode, SpanKindServer)
+	}
+	if span.StartTimeUnixNano != 1_700_000_000_000_000_000 || span.EndTimeUnixNano != 1_700_000_000_250_000_000 {
+		t.Errorf("times = %d..%d", span.StartTimeUnixNano, span.EndTimeUnixNano)
+	}
+
+	want := map[string]string{
+		"http.route":                "/users/{id}",
+		"http.response.status_code": "404",
+		"retry":                     "true",
+		"ratio":                     "0.5",
+	}
+	if len(span.Attributes) != len(want) {
+		t.Errorf("attributes = %v, want %v", span.Attributes, want)
+	}
+	for key, value := range want {
+		if span.Attributes[key] != value {
+			t.Errorf("attribute %s = %q, want %q", key, span.Attributes[key], value)
+		}
+	}
+
+	if resources[0].Spans[1].Kind == SpanKindServer {
+		t.Error("client span decoded as a server span")
+	}
+}
+
+func TestUnmarshalProtoInvalid(t *testing.T) {
+	for name, body := range map[string][]byte{
+		"truncated":     {0x0a, 0x10, 0x01},
+		"bad wire type": {0x0b},
+	} {
+		if _, err := UnmarshalProto(body); err == nil {
+			t.Errorf("%s: UnmarshalProto succeeded, want an error", name)
+		}
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		kind    int32
+		status  int32
+		start   uint64
+		attrs   map[string]string
+		wantErr bool
+	}{
+		{
+			name: "numeric enums and string integers",
+			body: `{"resourceSpans":[{"resource":{"attributes":[{"key":"tracker.client_id","value":{"stringValue":"client-a"}}]},
+				"scopeSpans":[{"spans":[{"traceId":"abababababababababababababababab","spanId":"cdcdcdcdcdcdcdcd",
+				"kind":2,"startTimeUnixNano":"1700000000000000000","endTimeUnixNano":"1700000000250000000",
+				"attributes":[{"key":"http.response.status_code","value":{"intValue":"404"}}],"status":{"code":2}}]}]}]}`,
+			kind:   SpanKindServer,
+			status: 2,
+			start:  1_700_000_000_000_000_000,
+			attrs:  map[string]string{"http.response.status_code": "404"},
+		},
+		{
+			name: "enum names and number integers",
+			body: `{"resourceSpans":[{"scopeSpans":[{"spans":[{"traceId":"ab","spanId":"cd","kind":"SPAN_KIND_SERVER",
+				"startTimeUnixNano":1700000000,"attributes":[{"key":"http.response.status_code","value":{"intValue":200}},
+				{"key":"retry","value":{"boolValue":false}}],"status":{"code":"STATUS_CODE_OK"}}]}]}]}`,
+			kind:   SpanKindServer,
+			status: 1,
+			start:  1_700_000_000,
+			attrs:  map[string]string{"http.response.status_code": "200", "retry": "false"},
+		},
+		{
+			name:    "base64 trace ID",
+			body:    `{"resourceSpans":[{"scopeSpans":[{"spans":[{"traceId":"q6urq6urq6urq6urq6urqw==","spanId":"cd"}]}]}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid start time",
+			body:    `{"resourceSpans":[{"scopeSpans":[{"spans":[{"startTimeUnixNano":"soon"}]}]}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "not JSON",
+			body:    `{"resourceSpans":`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resources, err := UnmarshalJSON([]byte(tt.body))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("UnmarshalJSON succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UnmarshalJSON: %v", err)
+			}
+			if len(resources) != 1 || len(resources[0].Spans) != 1 {
+				t.Fatalf("decoded %+v, want one span", resources)
+			}
+			span := resources[0].Spans[0]
+			if span.Kind != tt.kind || span.StatusCode != tt.status || span.StartTimeUnixNano != tt.start {
+				t.Errorf("span = %+v", span)
+			}
+			for key, value := range tt.attrs {
+				if span.Attributes[key] != value {
+					t.Errorf("attribute %s = %q, want %q", key, span.Attributes[key], value)
+				}
+			}
+		})
+	}
+}
+
+func TestMarshalResponseProto(t *testing.T) {
+	if b := MarshalResponseProto(0, ""); len(b) != 0 {
+		t.Errorf("full success encoded as %x, want an empty message", b)
+	}
+
+	var resp coltracepb.ExportTraceServiceResponse
+	if err := proto.Unmarshal(MarshalResponseProto(3, "span 01: bad"), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if partial := resp.GetPartialSuccess(); partial.GetRejectedSpans() != 3 || partial.GetErrorMessage() != "span 01: bad" {
+		t.Errorf("partial success = %v, want 3 rejected spans", partial)
+	}
+}
+
+func TestMarshalStatusProto(t *testing.T) {
+	var status rpcstatus.Status
+	if err := proto.Unmarshal(MarshalStatusProto(16, "Invalid OTLP token"), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.GetCode() != 16 || status.GetMessage() != "Invalid OTLP token" {
+		t.Errorf("status = %v", &status)
+	}
+}
@@ -103,6 +103,14 @@ func (s *AuthService) ValidateAPIKey(apiKey string) (*models.Client, error) {
 	return &client, nil
 }
 
+func (s *AuthService) GetClient(clientID string) (*models.Client, error) {
+	var client models.Client
+	if err := s.db.Where("client_id = ?", clientID).First(&client).Error; err != nil {
+		return nil, errors.New("client not found")
+	}
+	return &client, nil
+}
+
 func (s *AuthService) HashAPIKey(apiKey string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(apiKey), bcrypt.DefaultCost)
 	return string(bytes), err
@@ -176,8 +184,11 @@ func (s *AuthService) CheckIPWhitelist(client *models.Client, ip string) bool {
 }
 
 func (s *AuthService) GetClientIPv4(c *gin.Context) string {
-	ip := c.ClientIP()
+	return s.NormalizeIPv4(c.ClientIP())
+}
 
+// NormalizeIPv4 maps loopback and IPv4-mapped IPv6 addresses to plain IPv4
+func (s *AuthService) NormalizeIPv4(ip string) string {
 	switch ip {
 	case "::1":
 		return "127.0.0.1"
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"log"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"user-activity-tracker/configs"
+	"user-activity-tracker/internal/geoip"
+	"user-activity-tracker/internal/models"
+)
+
+// geoDatabase is an MMDB file that is reopened when it changes on disk
+type geoDatabase struct {
+	path    string
+	reader  atomic.Pointer[geoip.Reader]
+	modTime time.Time
+	size    int64
+}
+
+// GeoIPService enriches hits with the country, region and ASN of their IP
+// address. Country/City and ASN data may come from separate databases.
+type GeoIPService struct {
+	databases []*geoDatabase
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewGeoIPService() *GeoIPService {
+	s := &GeoIPService{stop: make(chan struct{})}
+	for _, path := range []string{configs.AppConfig.GeoIPDBPath, configs.AppConfig.GeoIPASNDBPath} {
+		if path == "" {
+			continue
+		}
+		db := &geoDatabase{path: path}
+		if err := db.reload(); err != nil {
+			log.Printf("Failed to load GeoIP database %s: %v", path, err)
+		}
+		s.databases = append(s.databases, db)
+	}
+	return s
+}
+
+// Enabled reports whether any GeoIP database is configured
+func (s *GeoIPService) Enabled() bool {
+	return len(s.databases) > 0
+}
+
+// Start watches the databases and reloads them when their files change
+func (s *GeoIPService) Start() {
+	interval := configs.AppConfig.GeoIPReloadInterval
+	if !s.Enabled() || interval <= 0 {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				for _, db := range s.databases {
+					if err := db.reload(); err != nil {
+						log.Printf("Failed to reload GeoIP database %s, keeping the previous version: %v", db.path, err)
+					}
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops watching the database files
+func (s *GeoIPService) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// Apply is the ingestion stage filling in the geographic fields of a hit
+func (s *GeoIPService) Apply(hit *models.APILogs) bool {
+	if hit.IPAddress == "" || !s.Enabled() {
+		return true
+	}
+	loc := s.Locate(net.ParseIP(hit.IPAddress))
+
+	hit.Country = loc.Country
+	hit.Region = loc.Region
+	hit.ASOrg = loc.ASOrg
+	if loc.ASN != 0 {
+		asn := loc.ASN
+		hit.ASN = &asn
+	}
+	return true
+}
+
+// Locate merges what every configured database knows about ip
+func (s *GeoIPService) Locate(ip net.IP) geoip.Location {
+	var loc geoip.Location
+	if ip == nil {
+		return loc
+	}
+
+	for _, db := range s.databases {
+		reader := db.reader.Load()
+		if reader == nil {
+			continue
+		}
+		found, err := reader.Locate(ip)
+		if err != nil {
+			continue
+		}
+		if loc.Country == "" {
+			loc.Country = found.Country
+		}
+		if loc.Region == "" {
+			loc.Region = found.Region
+		}
+		if loc.ASN == 0 {
+			loc.ASN, loc.ASOrg = found.ASN, found.ASOrg
+		}
+	}
+	return loc
+}
+
+// reload opens the database file again if it changed since it was last loaded.
+// A failed reload keeps serving the previous version.
+func (db *geoDatabase) reload() error {
+	info, err := os.Stat(db.path)
+	if err != nil {
+		return err
+	}
+	if db.reader.Load() != nil && info.ModTime().Equal(db.modTime) && info.Size() == db.size {
+		return nil
+	}
+
+	reader, err := geoip.Open(db.path)
+	if err != nil {
+		return err
+	}
+	db.reader.Store(reader)
+	db.modTime, db.size = info.ModTime(), info.Size()
+	log.Printf("Loaded GeoIP database %s (%s, built %s)", db.path, reader.Metadata.DatabaseType,
+		time.Unix(int64(reader.Metadata.BuildEpoch), 0).UTC().Format("2006-01-02"))
+	return nil
+}
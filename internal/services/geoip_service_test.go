@@ -0,0 +1,79 @@
+package services
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"user-activity-tracker/internal/geoip/geoiptest"
+	"user-activity-tracker/internal/models"
+)
+
+func writeCountryDB(t *testing.T, path, country string, modTime time.Time) {
+	t.Helper()
+	buf, err := geoiptest.Build(geoiptest.Options{IPVersion: 6, RecordSize: 24, DatabaseType: "GeoLite2-Country"}, []geoiptest.Network{
+		{CIDR: "203.0.113.0/24", Record: map[string]interface{}{"country": map[string]interface{}{"iso_code": country}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGeoIPReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "country.mmdb")
+	modTime := time.Now().Add(-time.Hour)
+	writeCountryDB(t, path, "US", modTime)
+
+	db := &geoDatabase{path: path}
+	if err := db.reload(); err != nil {
+		t.Fatalf("initial load: %v", err)
+	}
+	s := &GeoIPService{databases: []*geoDatabase{db}, stop: make(chan struct{})}
+	ip := net.ParseIP("203.0.113.7")
+
+	if got := s.Locate(ip).Country; got != "US" {
+		t.Fatalf("country = %q, want US", got)
+	}
+
+	// An updated file is picked up
+	writeCountryDB(t, path, "CA", modTime.Add(time.Minute))
+	if err := db.reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if got := s.Locate(ip).Country; got != "CA" {
+		t.Errorf("country after reload = %q, want CA", got)
+	}
+
+	// A broken update keeps the previous version
+	if err := os.WriteFile(path, []byte("truncated download"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.reload(); err == nil {
+		t.Error("reload of a broken file succeeded")
+	}
+	if got := s.Locate(ip).Country; got != "CA" {
+		t.Errorf("country after a failed reload = %q, want CA", got)
+	}
+
+	// A missing file too
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.reload(); err == nil {
+		t.Error("reload of a missing file succeeded")
+	}
+
+	hit := models.APILogs{IPAddress: "203.0.113.7"}
+	s.Apply(&hit)
+	if hit.Country != "CA" {
+		t.Errorf("Apply set country %q, want CA", hit.Country)
+	}
+}
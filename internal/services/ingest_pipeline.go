@@ -0,0 +1,48 @@
+package services
+
+// IngestPipeline is the chain of stages every hit goes through before it is
+// stored, whether it arrives live or is imported from an access log. The
+// services with their own API or background work are exposed so the caller
+// can wire and start them.
+type IngestPipeline struct {
+	Redaction    *RedactionService
+	Normalizer   *EndpointNormalizer
+	Sampling     *SamplingService
+	UserIdentity *UserIdentityService
+	GeoIP        *GeoIPService
+	IPPrivacy    *IPPrivacyService
+
+	labels    *LabelService
+	userAgent *UserAgentService
+}
+
+func NewIngestPipeline() *IngestPipeline {
+	return &IngestPipeline{
+		Redaction:    NewRedactionService(),
+		Normalizer:   NewEndpointNormalizer(),
+		Sampling:     NewSamplingService(),
+		UserIdentity: NewUserIdentityService(),
+		GeoIP:        NewGeoIPService(),
+		IPPrivacy:    NewIPPrivacyService(),
+		labels:       NewLabelService(),
+		userAgent:    NewUserAgentService(),
+	}
+}
+
+// Stages returns the stages in the order they run. Hits that sampling drops
+// are still counted and broadcast, so every stage protecting or enriching
+// them runs before it.
+func (p *IngestPipeline) Stages() []IngestStage {
+	return []IngestStage{
+		// Redaction runs first so no other stage sees unredacted endpoints
+		p.Redaction.Apply,
+		p.Normalizer.Apply,
+		p.labels.Apply,
+		p.UserIdentity.Apply,
+		p.userAgent.Apply,
+		p.GeoIP.Apply,
+		// IP privacy runs after enrichment so it still sees the full address
+		p.IPPrivacy.Apply,
+		p.Sampling.Apply,
+	}
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"user-activity-tracker/configs"
+	"user-activity-tracker/internal/cache"
+	"user-activity-tracker/internal/models"
+)
+
+// testCache returns the cache manager without Redis
+func testCache() *cache.CacheManager {
+	// Nothing listens here, so the cache falls back to its local store
+	configs.AppConfig.RedisURL = "127.0.0.1:1"
+	return cache.GetCacheManager()
+}
+
+// testPipeline returns a pipeline whose client settings are all read from the
+// local cache, so no database is needed
+func testPipeline(t *testing.T) (*IngestPipeline, *cache.CacheManager) {
+	t.Helper()
+	cm := testCache()
+
+	defaultRedactor, err := NewRedactor(models.RedactionRules{QueryMode: QueryModeDeny, DetectEmails: true, DetectJWTs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &IngestPipeline{
+		Redaction:    &RedactionService{redactors: map[string]cachedRedactor{"client": {redactor: defaultRedactor, loadedAt: time.Now()}}},
+		Normalizer:   &EndpointNormalizer{cache: cm},
+		Sampling:     &SamplingService{cache: cm},
+		UserIdentity: &UserIdentityService{cache: cm},
+		GeoIP:        &GeoIPService{},
+		IPPrivacy:    &IPPrivacyService{cache: cm},
+		labels:       &LabelService{},
+		userAgent:    NewUserAgentService(),
+	}, cm
+}
+
+func TestSampledOutHitsAreAnonymized(t *testing.T) {
+	p, cm := testPipeline(t)
+	cm.Set(routeTemplateCacheKey("client"), []string{}, time.Minute)
+	cm.Set(userIDStorageCacheKey("client"), UserIDHashed, time.Minute)
+	cm.Set(samplingCacheKey("client"), map[string]float64{"": minSampleRate}, time.Minute)
+
+	s := &IngestService{}
+	for _, stage := range p.Stages() {
+		s.AddStage(stage)
+	}
+
+	const rawIP, rawUser = "203.0.113.7", "user-42"
+	for _, mode := range []string{IPPrivacyTruncated, IPPrivacyHashed, IPPrivacyNone} {
+		t.Run(mode, func(t *testing.T) {
+			cm.Set(ipPrivacyCacheKey("client"), mode, time.Minute)
+
+			hit := models.APILogs{
+				EventID:   "evt-1",
+				ClientID:  "client",
+				Endpoint:  "/users/42?token=secret",
+				IPAddress: rawIP,
+				UserID:    rawUser,
+				UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			}
+			if sampleFraction(hit.EventID) < minSampleRate {
+				t.Fatal("event ID is sampled in; pick another")
+			}
+
+			if s.Prepare(&hit) {
+				t.Fatal("hit was stored, want it sampled out")
+			}
+			if hit.IPAddress == rawIP || !hit.IPAnonymized {
+				t.Errorf("IP address = %q (anonymized %v), want it anonymized", hit.IPAddress, hit.IPAnonymized)
+			}
+			if hit.UserID == rawUser {
+				t.Error("user ID was not hashed")
+			}
+			if hit.Endpoint != "/users/42" || hit.EndpointTemplate != "/users/:id" {
+				t.Errorf("endpoint = %q, template = %q", hit.Endpoint, hit.EndpointTemplate)
+			}
+			if hit.Browser == "" {
+				t.Error("user agent was not parsed")
+			}
+		})
+	}
+}
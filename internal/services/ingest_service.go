@@ -0,0 +1,722 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"user-activity-tracker/configs"
+	"user-activity-tracker/internal/cache"
+	"user-activity-tracker/internal/database"
+	"user-activity-tracker/internal/models"
+	"user-activity-tracker/internal/spool"
+)
+
+// ErrQueueFull is returned when the ingestion queue has no spare capacity
+var ErrQueueFull = errors.New("ingestion queue is full")
+
+// ErrIngestStopped is returned when hits are submitted after shutdown began
+var ErrIngestStopped = errors.New("ingestion pipeline is stopped")
+
+// dropWindow is how long a dropped hit keeps the pipeline reported as degraded
+const dropWindow = 15 * time.Minute
+
+// IngestStage transforms a hit before it is stored, counted or broadcast.
+// Returning false keeps the hit out of storage; it is still counted.
+type IngestStage func(hit *models.APILogs) bool
+
+// IngestService buffers API hits in memory and writes them in batches
+type IngestService struct {
+	stages        []IngestStage
+	dbManager     *database.DBManager
+	cache         *cache.CacheManager
+	queue         chan models.APILogs
+	workers       int
+	batchSize     int
+	flushInterval time.Duration
+
+	// spool holds hits on disk while the write DB is unavailable; nil when disabled
+	spool          *spool.Spool
+	replayInterval time.Duration
+	stopReplay     chan struct{}
+	replayDone     chan struct{}
+
+	// retry holds hits that could neither be stored nor spooled, oldest first,
+	// up to retryMax; hits beyond that are dropped and counted
+	retryMu     sync.Mutex
+	retry       []models.APILogs
+	retryMax    int
+	retryDone   chan struct{}
+	droppedHits atomic.Int64
+	lastDrop    atomic.Int64 // Unix time of the last drop
+
+	purgeDone chan struct{}
+
+	mu      sync.RWMutex
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+func NewIngestService() *IngestService {
+	cfg := configs.AppConfig
+
+	flushInterval := cfg.IngestFlushInterval
+	if flushInterval <= 0 {
+		flushInterval = time.Second
+	}
+
+	s := &IngestService{
+		dbManager:      database.GetDBManager(),
+		cache:          cache.GetCacheManager(),
+		queue:          make(chan models.APILogs, max(cfg.IngestQueueSize, 1)),
+		workers:        max(cfg.IngestWorkers, 1),
+		batchSize:      max(cfg.IngestBatchSize, 1),
+		flushInterval:  flushInterval,
+		replayInterval: cfg.SpoolReplayInterval,
+		stopReplay:     make(chan struct{}),
+		replayDone:     make(chan struct{}),
+		retryMax:       max(cfg.IngestRetryMaxHits, 0),
+		retryDone:      make(chan struct{}),
+		purgeDone:      make(chan struct{}),
+	}
+
+	if cfg.SpoolDir != "" {
+		sp, err := spool.Open(cfg.SpoolDir, int64(max(cfg.SpoolSegmentBytes, 1<<20)), int64(cfg.SpoolMaxBytes))
+		if err != nil {
+			log.Printf("Failed to open spool in %s, hits will be lost during DB outages: %v", cfg.SpoolDir, err)
+		} else {
+			s.spool = sp
+			if pending, size := sp.Pending(); pending > 0 {
+				log.Printf("Spool has %d hits (%d bytes) waiting to be replayed", pending, size)
+			}
+		}
+	}
+
+	return s
+}
+
+// AddStage appends a stage to the pipeline. Stages run in the order they were added.
+func (s *IngestService) AddStage(stage IngestStage) {
+	s.stages = append(s.stages, stage)
+}
+
+// Prepare runs a hit through every ingestion stage and reports whether it
+// should be stored. Stages after one that rejects the hit are skipped.
+func (s *IngestService) Prepare(hit *models.APILogs) bool {
+	for _, stage := range s.stages {
+		if !stage(hit) {
+			return false
+		}
+	}
+	return true
+}
+
+// Start launches the flush workers
+func (s *IngestService) Start() {
+	for i := 0; i < s.workers; i++ {
+		s.wg.Add(1)
+		go s.worker()
+	}
+
+	if s.spool != nil {
+		go s.replayer()
+	} else {
+		close(s.replayDone)
+	}
+	go s.retrier()
+	go s.purger()
+	log.Printf("Ingestion pipeline started (workers=%d, batch=%d, queue=%d)", s.workers, s.batchSize, cap(s.queue))
+}
+
+// Stop rejects new hits, drains the queue and waits for the final flush
+func (s *IngestService) Stop() {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return
+	}
+	s.stopped = true
+	close(s.queue)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+
+	close(s.stopReplay)
+	<-s.replayDone
+	<-s.retryDone
+	<-s.purgeDone
+
+	// Last chance for held-back hits, while the spool is still open
+	if !s.retryHits() {
+		s.retryMu.Lock()
+		lost := s.retry
+		s.retry = nil
+		s.retryMu.Unlock()
+		s.drop(lost, "the write DB is still unavailable at shutdown")
+	}
+	if s.spool != nil {
+		s.spool.Close()
+	}
+	log.Println("Ingestion pipeline stopped")
+}
+
+// Enqueue adds a hit to the queue without blocking
+func (s *IngestService) Enqueue(hit models.APILogs) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.stopped {
+		return ErrIngestStopped
+	}
+
+	select {
+	case s.queue <- hit:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// EnqueueWait adds a hit to the queue, waiting for capacity until ctx is done
+func (s *IngestService) EnqueueWait(ctx context.Context, hit models.APILogs) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.stopped {
+		return ErrIngestStopped
+	}
+
+	select {
+	case s.queue <- hit:
+		return nil
+	case <-ctx.Done():
+		return ErrQueueFull
+	}
+}
+
+// InsertBatch writes a batch of hits synchronously, bypassing the queue.
+// spooled is true when the hits were accepted into the disk spool instead.
+func (s *IngestService) InsertBatch(hits []models.APILogs) (spooled bool, err error) {
+	if len(hits) == 0 {
+		return false, nil
+	}
+	return s.store(hits)
+}
+
+// ClaimIdempotencyKey reserves an idempotency key for eventID. If the key was
+// already used, the original event ID is returned and duplicate is true. The
+// claim lives in cache until the hit is stored, which commits the key in the
+// same transaction, so a hit that is never stored does not keep its key.
+func (s *IngestService) ClaimIdempotencyKey(clientID, key, eventID string) (string, bool, error) {
+	ttl := configs.AppConfig.IdempotencyTTL
+	cacheKey := idempotencyCacheKey(clientID, key)
+
+	// Fast path: the key is claimed by a hit in flight or remembered in cache
+	claimed, cacheErr := s.cache.SetNX(cacheKey, eventID, ttl)
+	if cacheErr == nil && !claimed {
+		var originalID string
+		if found, err := s.cache.Get(cacheKey, &originalID); found && err == nil && originalID != "" {
+			return originalID, true, nil
+		}
+	}
+
+	// Committed keys are the source of truth
+	var existing models.IdempotencyKey
+	err := s.dbManager.WriteDB.Where("client_id = ? AND idempotency_key = ?", clientID, key).Limit(1).Find(&existing).Error
+	if err != nil {
+		// While the write DB is down and hits are spooled, the cache claim has to do
+		if claimed && cacheErr == nil && s.spool != nil {
+			return eventID, false, nil
+		}
+		s.cache.Delete(cacheKey)
+		return "", false, err
+	}
+
+	// Keys older than the TTL may be reused
+	if existing.ID != 0 && time.Since(existing.CreatedAt) <= ttl {
+		s.cache.Set(cacheKey, existing.EventID, ttl)
+		return existing.EventID, true, nil
+	}
+	return eventID, false, nil
+}
+
+// ReleaseIdempotencyKey frees a claimed key when the hit could not be accepted
+// or was dropped
+func (s *IngestService) ReleaseIdempotencyKey(clientID, key string) {
+	s.cache.Delete(idempotencyCacheKey(clientID, key))
+}
+
+// CommitIdempotencyKeys records the keys of hits that are counted but not
+// stored, such as sampled-out ones. On failure the cache claim still holds.
+func (s *IngestService) CommitIdempotencyKeys(hits ...models.APILogs) {
+	if err := s.dbManager.CommitIdempotencyKeys(hits); err != nil {
+		log.Printf("Failed to commit idempotency keys: %v", err)
+	}
+}
+
+func idempotencyCacheKey(clientID, key string) string {
+	return fmt.Sprintf("idempotency:%s:%s", clientID, key)
+}
+
+// QueueDepth returns the number of hits waiting to be flushed
+func (s *IngestService) QueueDepth() int {
+	return len(s.queue)
+}
+
+// QueueCapacity returns the maximum number of buffered hits
+func (s *IngestService) QueueCapacity() int {
+	return cap(s.queue)
+}
+
+// SpoolEnabled reports whether hits are spooled to disk during DB outages
+func (s *IngestService) SpoolEnabled() bool {
+	return s.spool != nil
+}
+
+// RetryBacklog returns the number of hits held in memory for another store attempt
+func (s *IngestService) RetryBacklog() int {
+	s.retryMu.Lock()
+	defer s.retryMu.Unlock()
+	return len(s.retry)
+}
+
+// DroppedHits returns the number of accepted hits lost since startup
+func (s *IngestService) DroppedHits() int64 {
+	return s.droppedHits.Load()
+}
+
+// Degraded reports whether hits are waiting for a retry or were dropped recently
+func (s *IngestService) Degraded() bool {
+	if s.RetryBacklog() > 0 {
+		return true
+	}
+	last := s.lastDrop.Load()
+	return last > 0 && time.Since(time.Unix(last, 0)) < dropWindow
+}
+
+// SpoolBacklog returns the number and size in bytes of spooled hits awaiting replay
+func (s *IngestService) SpoolBacklog() (int, int64) {
+	if s.spool == nil {
+		return 0, 0
+	}
+	return s.spool.Pending()
+}
+
+func (s *IngestService) worker() {
+	defer s.wg.Done()
+
+	batch := make([]models.APILogs, 0, s.batchSize)
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case hit, ok := <-s.queue:
+			if !ok {
+				s.flush(batch)
+				return
+			}
+			batch = append(batch, hit)
+			if len(batch) >= s.batchSize {
+				s.flush(batch)
+				batch = batch[:0]
+			}
+
+		case <-ticker.C:
+			if len(batch) > 0 {
+				s.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+func (s *IngestService) flush(batch []models.APILogs) {
+	if len(batch) == 0 {
+		return
+	}
+
+	if _, err := s.store(batch); err != nil {
+		log.Printf("Failed to flush %d hits, keeping them for retry: %v", len(batch), err)
+		s.keepForRetry(batch)
+	}
+}
+
+// keepForRetry holds hits that failed to store for the retrier. The batch is
+// copied since workers reuse it.
+func (s *IngestService) keepForRetry(hits []models.APILogs) {
+	s.retryMu.Lock()
+	kept := min(max(s.retryMax-len(s.retry), 0), len(hits))
+	s.retry = append(s.retry, hits[:kept]...)
+	s.retryMu.Unlock()
+
+	if kept < len(hits) {
+		s.drop(hits[kept:], "the retry buffer is full")
+	}
+}
+
+// drop gives up on accepted hits, counting them for /health
+func (s *IngestService) drop(hits []models.APILogs, reason string) {
+	if len(hits) == 0 {
+		return
+	}
+	s.droppedHits.Add(int64(len(hits)))
+	s.lastDrop.Store(time.Now().Unix())
+
+	// Let clients retry the dropped hits
+	for _, hit := range hits {
+		if hit.IdempotencyKey != "" {
+			s.ReleaseIdempotencyKey(hit.ClientID, hit.IdempotencyKey)
+		}
+	}
+	log.Printf("Dropped %d hits because %s (%d dropped since startup)", len(hits), reason, s.droppedHits.Load())
+}
+
+// retrier periodically stores the hits held back by failed flushes
+func (s *IngestService) retrier() {
+	defer close(s.retryDone)
+
+	interval := s.replayInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopReplay:
+			return
+		case <-ticker.C:
+			if pending := s.RetryBacklog(); pending > 0 && s.retryHits() {
+				log.Printf("Stored %d hits held back by failed flushes", pending)
+			}
+		}
+	}
+}
+
+// purger deletes idempotency keys older than IDEMPOTENCY_TTL every hour; one
+// instance sharing Redis purges per hour
+func (s *IngestService) purger() {
+	defer close(s.purgeDone)
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopReplay:
+			return
+		case <-ticker.C:
+			if locked, err := s.cache.SetNX("idempotency:purge", true, time.Hour-time.Minute); err != nil || !locked {
+				continue
+			}
+			s.purgeIdempotencyKeys()
+		}
+	}
+}
+
+// purgeIdempotencyKeys deletes expired keys in small batches so the table is
+// never locked for long
+func (s *IngestService) purgeIdempotencyKeys() {
+	cutoff := time.Now().Add(-configs.AppConfig.IdempotencyTTL)
+	var purged int64
+	for {
+		select {
+		case <-s.stopReplay:
+			return
+		default:
+		}
+
+		n, err := s.dbManager.PurgeIdempotencyKeys(cutoff, 10000)
+		purged += n
+		if err != nil {
+			log.Printf("Failed to purge idempotency keys: %v", err)
+			return
+		}
+		if n < 10000 {
+			if purged > 0 {
+				log.Printf("Purged %d expired idempotency keys", purged)
+			}
+			return
+		}
+	}
+}
+
+// retryHits stores held-back hits batch by batch, oldest first, and reports
+// whether none are left. Only the retrier and Stop remove hits, so the front
+// of the buffer does not move while a batch is being stored.
+func (s *IngestService) retryHits() bool {
+	for {
+		s.retryMu.Lock()
+		n := min(len(s.retry), s.batchSize)
+		batch := append([]models.APILogs(nil), s.retry[:n]...)
+		s.retryMu.Unlock()
+		if n == 0 {
+			return true
+		}
+
+		if _, err := s.store(batch); err != nil {
+			return false
+		}
+
+		s.retryMu.Lock()
+		s.retry = s.retry[n:]
+		if len(s.retry) == 0 {
+			s.retry = nil
+		}
+		s.retryMu.Unlock()
+	}
+}
+
+// store writes hits to MySQL, falling back to the spool when the write fails.
+// While a backlog is pending, new hits go to the spool too so replay stays in order.
+func (s *IngestService) store(hits []models.APILogs) (bool, error) {
+	if s.spool != nil {
+		if pending, _ := s.spool.Pending(); pending > 0 {
+			if err := s.spoolHits(hits); err == nil {
+				return true, nil
+			}
+		}
+	}
+
+	err := s.dbManager.BatchInsertHits(hits)
+	if err == nil || s.spool == nil {
+		return false, err
+	}
+
+	if spoolErr := s.spoolHits(hits); spoolErr != nil {
+		log.Printf("Failed to spool %d hits: %v", len(hits), spoolErr)
+		return false, err
+	}
+	log.Printf("Write DB unavailable, spooled %d hits: %v", len(hits), err)
+	return true, nil
+}
+
+func (s *IngestService) spoolHits(hits []models.APILogs) error {
+	records := make([][]byte, 0, len(hits))
+	for _, hit := range hits {
+		record, err := json.Marshal(hit)
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+	}
+	return s.spool.Append(records)
+}
+
+// replayer periodically drains the spool into MySQL
+func (s *IngestService) replayer() {
+	defer close(s.replayDone)
+
+	interval := s.replayInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopReplay:
+			return
+		case <-ticker.C:
+			if pending, _ := s.spool.Pending(); pending > 0 {
+				s.replaySpool()
+			}
+		}
+	}
+}
+
+// replaySpool inserts spooled hits in order until the spool is empty or the DB fails again.
+// The checkpoint only advances after a batch is stored, and hits already in
+// api_logs are skipped, so a crash mid-replay never duplicates hits.
+func (s *IngestService) replaySpool() {
+	replayed := 0
+	defer func() {
+		if replayed > 0 {
+			pending, _ := s.spool.Pending()
+			log.Printf("Replayed %d spooled hits, %d remaining", replayed, pending)
+		}
+	}()
+
+	for {
+		select {
+		case <-s.stopReplay:
+			return
+		default:
+		}
+
+		batch, err := s.spool.Read(s.batchSize)
+		if err != nil {
+			log.Printf("Failed to read spool: %v", err)
+			return
+		}
+		if len(batch.Records) == 0 {
+			return
+		}
+
+		hits := make([]models.APILogs, 0, len(batch.Records))
+		for _, record := range batch.Records {
+			var hit models.APILogs
+			if err := json.Unmarshal(record, &hit); err != nil {
+				log.Printf("Skipping undecodable spooled hit: %v", err)
+				continue
+			}
+			hits = append(hits, hit)
+		}
+
+		if err := s.replayHits(hits); err != nil {
+			log.Printf("Spool replay paused, write DB still unavailable: %v", err)
+			return
+		}
+		if err := s.spool.Commit(batch); err != nil {
+			log.Printf("Failed to commit spool checkpoint: %v", err)
+			return
+		}
+		replayed += len(hits)
+	}
+}
+
+// replayHits stores spooled hits that are not yet in api_logs
+func (s *IngestService) replayHits(hits []models.APILogs) error {
+	if err := s.pingWriteDB(); err != nil {
+		return err
+	}
+
+	hits, err := s.withoutStoredHits(hits)
+	if err != nil || len(hits) == 0 {
+		return err
+	}
+
+	if err := s.dbManager.BatchInsertHits(hits); err != nil {
+		if pingErr := s.pingWriteDB(); pingErr != nil {
+			return pingErr
+		}
+
+		// The DB is up, so some hit is rejected; store the rest one by one
+		for i := range hits {
+			if err := s.dbManager.BatchInsertHits(hits[i : i+1]); err != nil {
+				if pingErr := s.pingWriteDB(); pingErr != nil {
+					return pingErr
+				}
+				s.drop(hits[i:i+1], fmt.Sprintf("MySQL rejected spooled hit %s of client %s: %v", hits[i].EventID, hits[i].ClientID, err))
+			}
+		}
+	}
+
+	s.reaggregate(hits)
+	return nil
+}
+
+// withoutStoredHits filters out hits whose event IDs are already in api_logs
+func (s *IngestService) withoutStoredHits(hits []models.APILogs) ([]models.APILogs, error) {
+	if len(hits) == 0 {
+		return hits, nil
+	}
+
+	clientIDs := make(map[string]struct{})
+	eventIDs := make([]string, 0, len(hits))
+	from, to := hits[0].Timestamp, hits[0].Timestamp
+	for _, hit := range hits {
+		clientIDs[hit.ClientID] = struct{}{}
+		eventIDs = append(eventIDs, hit.EventID)
+		if hit.Timestamp.Before(from) {
+			from = hit.Timestamp
+		}
+		if hit.Timestamp.After(to) {
+			to = hit.Timestamp
+		}
+	}
+	clients := make([]string, 0, len(clientIDs))
+	for clientID := range clientIDs {
+		clients = append(clients, clientID)
+	}
+
+	var stored []struct {
+		ClientID string
+		EventID  string
+	}
+	err := s.dbManager.WriteDB.Model(&models.APILogs{}).
+		Select("client_id, event_id").
+		Where("client_id IN ? AND event_id IN ? AND timestamp >= ? AND timestamp <= ?", clients, eventIDs, from, to).
+		Scan(&stored).Error
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{}, len(stored))
+	for _, row := range stored {
+		seen[row.ClientID+"\x00"+row.EventID] = struct{}{}
+	}
+	return unseenHits(hits, seen), nil
+}
+
+// unseenHits keeps the first hit of every client and event ID not in seen.
+// A crash before the spool checkpoint is saved replays hits that were stored,
+// and a retried request may have been spooled twice.
+func unseenHits(hits []models.APILogs, seen map[string]struct{}) []models.APILogs {
+	fresh := hits[:0]
+	for _, hit := range hits {
+		key := hit.ClientID + "\x00" + hit.EventID
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		fresh = append(fresh, hit)
+	}
+	return fresh
+}
+
+// reaggregate rebuilds daily usage for past days that received replayed hits
+// and publishes a usage update for every client they belong to
+func (s *IngestService) reaggregate(hits []models.APILogs) {
+	today := time.Now().Format("2006-01-02")
+
+	clients := make(map[string]struct{})
+	days := make(map[string]map[string]struct{})
+	for _, hit := range hits {
+		clients[hit.ClientID] = struct{}{}
+		// Hits keep the offset they were sent with; MySQL stores server time
+		date := hit.Timestamp.In(time.Local).Format("2006-01-02")
+		if date >= today {
+			continue
+		}
+		if days[date] == nil {
+			days[date] = make(map[string]struct{})
+		}
+		days[date][hit.ClientID] = struct{}{}
+	}
+
+	for date, clientSet := range days {
+		clientIDs := make([]string, 0, len(clientSet))
+		for clientID := range clientSet {
+			clientIDs = append(clientIDs, clientID)
+		}
+
+		day, _ := time.ParseInLocation("2006-01-02", date, time.Local)
+		if err := s.dbManager.RebuildDailyUsage(day, clientIDs...); err != nil {
+			log.Printf("Failed to re-aggregate daily usage for %s after replay: %v", date, err)
+		}
+	}
+
+	for clientID := range clients {
+		s.cache.PublishUpdate(clientID)
+	}
+}
+
+func (s *IngestService) pingWriteDB() error {
+	sqlDB, err := s.dbManager.WriteDB.DB()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
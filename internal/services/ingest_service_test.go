@@ -0,0 +1,142 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"user-activity-tracker/internal/models"
+	"user-activity-tracker/internal/spool"
+)
+
+func TestUnseenHits(t *testing.T) {
+	hit := func(clientID, eventID string) models.APILogs {
+		return models.APILogs{ClientID: clientID, EventID: eventID}
+	}
+
+	tests := []struct {
+		name   string
+		hits   []models.APILogs
+		stored []string // client\x00event
+		want   []string
+	}{
+		{
+			name: "nothing stored",
+			hits: []models.APILogs{hit("a", "1"), hit("a", "2")},
+			want: []string{"a/1", "a/2"},
+		},
+		{
+			name:   "already stored",
+			hits:   []models.APILogs{hit("a", "1"), hit("a", "2"), hit("a", "3")},
+			stored: []string{"a\x002"},
+			want:   []string{"a/1", "a/3"},
+		},
+		{
+			name: "repeated within the batch",
+			hits: []models.APILogs{hit("a", "1"), hit("a", "1"), hit("a", "2"), hit("a", "1")},
+			want: []string{"a/1", "a/2"},
+		},
+		{
+			name:   "event IDs are per client",
+			hits:   []models.APILogs{hit("a", "1"), hit("b", "1")},
+			stored: []string{"a\x001"},
+			want:   []string{"b/1"},
+		},
+		{
+			name:   "everything stored",
+			hits:   []models.APILogs{hit("a", "1"), hit("a", "1")},
+			stored: []string{"a\x001"},
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := make(map[string]struct{})
+			for _, key := range tt.stored {
+				seen[key] = struct{}{}
+			}
+
+			var got []string
+			for _, hit := range unseenHits(tt.hits, seen) {
+				got = append(got, hit.ClientID+"/"+hit.EventID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("unseenHits = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("unseenHits = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestSpoolReplayWithDuplicateEventIDs(t *testing.T) {
+	dir := t.TempDir()
+	sp, err := spool.Open(dir, 1<<20, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	var records [][]byte
+	for _, eventID := range []string{"e1", "e2", "e1", "e3"} {
+		record, err := json.Marshal(models.APILogs{ClientID: "client", EventID: eventID, Endpoint: "/x", Timestamp: now})
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if err := sp.Append(records); err != nil {
+		t.Fatal(err)
+	}
+
+	// First replay stores e1 and e2, then crashes before committing the checkpoint
+	batch, err := sp.Read(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := map[string]struct{}{}
+	for _, hit := range unseenHits(decodeSpooled(t, batch.Records), map[string]struct{}{}) {
+		stored[hit.ClientID+"\x00"+hit.EventID] = struct{}{}
+	}
+	sp.Close()
+
+	sp, err = spool.Open(dir, 1<<20, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sp.Close()
+
+	batch, err = sp.Read(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Records) != 4 {
+		t.Fatalf("read %d records after restart, want all 4", len(batch.Records))
+	}
+
+	fresh := unseenHits(decodeSpooled(t, batch.Records), stored)
+	if len(fresh) != 1 || fresh[0].EventID != "e3" {
+		t.Fatalf("replay after restart would store %v, want only e3", fresh)
+	}
+	if !fresh[0].Timestamp.Equal(now) {
+		t.Errorf("spooled timestamp = %v, want %v", fresh[0].Timestamp, now)
+	}
+}
+
+func decodeSpooled(t *testing.T, records [][]byte) []models.APILogs {
+	t.Helper()
+	hits := make([]models.APILogs, 0, len(records))
+	for _, record := range records {
+		var hit models.APILogs
+		if err := json.Unmarshal(record, &hit); err != nil {
+			t.Fatal(err)
+		}
+		hits = append(hits, hit)
+	}
+	return hits
+}
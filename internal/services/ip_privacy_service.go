@@ -0,0 +1,290 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"user-activity-tracker/configs"
+	"user-activity-tracker/internal/cache"
+	"user-activity-tracker/internal/database"
+	"user-activity-tracker/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// How a client's IP addresses are stored
+const (
+	IPPrivacyFull      = "full"      // as reported
+	IPPrivacyTruncated = "truncated" // IPv4 /24, IPv6 /48
+	IPPrivacyHashed    = "hashed"    // keyed hash
+	IPPrivacyNone      = "none"      // not stored
+)
+
+const (
+	ipPrivacyCacheTTL   = time.Minute
+	ipAnonymizeBatch    = 1000
+	ipv4TruncatedPrefix = 24
+	ipv6TruncatedPrefix = 48
+)
+
+var ErrInvalidIPPrivacy = errors.New("ip_privacy must be full, truncated, hashed or none")
+
+// IPPrivacyService applies the client's IP privacy mode to hits before they
+// are stored or broadcast, and to addresses read back from storage. In the
+// background it anonymizes addresses older than IP_ANONYMIZE_AFTER.
+type IPPrivacyService struct {
+	db    *gorm.DB
+	cache *cache.CacheManager
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewIPPrivacyService() *IPPrivacyService {
+	return &IPPrivacyService{
+		db:    database.GetDBManager().WriteDB,
+		cache: cache.GetCacheManager(),
+		stop:  make(chan struct{}),
+	}
+}
+
+// Apply is the ingestion stage replacing the IP address with its stored form.
+// It runs after enrichment so the GeoIP lookup still sees the full address.
+func (s *IPPrivacyService) Apply(hit *models.APILogs) bool {
+	mode := s.Mode(hit.ClientID)
+	hit.IPAddress = AnonymizeIP(mode, hit.ClientID, hit.IPAddress)
+	hit.IPAnonymized = mode != IPPrivacyFull
+	return true
+}
+
+// Mask returns the form of a stored address the client's current mode allows
+// to be shown. Addresses stored under a stricter mode are returned as is.
+func (s *IPPrivacyService) Mask(clientID, ipAddress string) string {
+	return AnonymizeIP(s.Mode(clientID), clientID, ipAddress)
+}
+
+// Mode returns the client's IP privacy mode. Addresses are truncated when the
+// mode cannot be loaded.
+func (s *IPPrivacyService) Mode(clientID string) string {
+	cacheKey := ipPrivacyCacheKey(clientID)
+
+	var mode string
+	if found, err := s.cache.Get(cacheKey, &mode); found && err == nil {
+		return mode
+	}
+
+	var client models.Client
+	if err := s.db.Select("ip_privacy").Where("client_id = ?", clientID).First(&client).Error; err != nil {
+		return IPPrivacyTruncated
+	}
+	mode = client.IPPrivacy
+	if !ValidIPPrivacy(mode) {
+		mode = IPPrivacyFull
+	}
+
+	s.cache.Set(cacheKey, mode, ipPrivacyCacheTTL)
+	return mode
+}
+
+// SetMode changes how the client's IP addresses are stored from now on.
+// Addresses already stored are masked to the new mode when read back.
+func (s *IPPrivacyService) SetMode(clientID, mode string) error {
+	if !ValidIPPrivacy(mode) {
+		return ErrInvalidIPPrivacy
+	}
+
+	err := s.db.Model(&models.Client{}).Where("client_id = ?", clientID).Update("ip_privacy", mode).Error
+	if err != nil {
+		return err
+	}
+
+	s.cache.Delete(ipPrivacyCacheKey(clientID))
+	return nil
+}
+
+// Start anonymizes old addresses every IP_ANONYMIZE_INTERVAL
+func (s *IPPrivacyService) Start() {
+	cfg := configs.AppConfig
+	if cfg.IPAnonymizeAfter <= 0 || cfg.IPAnonymizeInterval <= 0 {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(cfg.IPAnonymizeInterval)
+		defer ticker.Stop()
+
+		for {
+			s.anonymizeAll()
+
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop waits for the running batch and stops anonymizing
+func (s *IPPrivacyService) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// anonymizeAll processes batches until no old full addresses are left
+func (s *IPPrivacyService) anonymizeAll() {
+	for {
+		logs, err := s.AnonymizeLogs()
+		if err != nil {
+			log.Printf("Failed to anonymize hit IP addresses: %v", err)
+			return
+		}
+		sessions, err := s.AnonymizeSessions()
+		if err != nil {
+			log.Printf("Failed to anonymize session IP addresses: %v", err)
+			return
+		}
+		if logs < ipAnonymizeBatch && sessions < ipAnonymizeBatch {
+			return
+		}
+
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+	}
+}
+
+// AnonymizeLogs anonymizes the next batch of hits older than IP_ANONYMIZE_AFTER
+// that still hold a full address and returns how many it changed
+func (s *IPPrivacyService) AnonymizeLogs() (int, error) {
+	var rows []struct {
+		ID        uint64
+		ClientID  string
+		IPAddress string
+	}
+	err := s.db.Table("api_logs").
+		Select("id, client_id, ip_address").
+		Where("ip_anonymized = ? AND timestamp < ?", false, time.Now().Add(-configs.AppConfig.IPAnonymizeAfter)).
+		Limit(ipAnonymizeBatch).
+		Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return 0, err
+	}
+
+	// One update per resulting address
+	byAddress := make(map[string][]uint64)
+	for _, row := range rows {
+		ip := AnonymizeIP(s.retentionMode(row.ClientID), row.ClientID, row.IPAddress)
+		byAddress[ip] = append(byAddress[ip], row.ID)
+	}
+	for ip, ids := range byAddress {
+		err := s.db.Table("api_logs").Where("id IN ?", ids).
+			Updates(map[string]interface{}{"ip_address": ip, "ip_anonymized": true}).Error
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(rows), nil
+}
+
+// AnonymizeSessions anonymizes the next batch of sessions that ended before
+// IP_ANONYMIZE_AFTER and still hold a full address, including the visitor key
+// of IP visitors, and returns how many it changed
+func (s *IPPrivacyService) AnonymizeSessions() (int, error) {
+	var sessions []models.Session
+	err := s.db.Select("id, client_id, visitor_key, ip_address").
+		Where("ip_anonymized = ? AND end_time < ?", false, time.Now().Add(-configs.AppConfig.IPAnonymizeAfter)).
+		Limit(ipAnonymizeBatch).
+		Find(&sessions).Error
+	if err != nil || len(sessions) == 0 {
+		return 0, err
+	}
+
+	for _, session := range sessions {
+		ip := AnonymizeIP(s.retentionMode(session.ClientID), session.ClientID, session.IPAddress)
+		updates := map[string]interface{}{"ip_address": ip, "ip_anonymized": true}
+		if session.VisitorKey == "ip:"+session.IPAddress {
+			updates["visitor_key"] = "ip:" + ip
+		}
+		if err := s.db.Model(&models.Session{}).Where("id = ?", session.ID).Updates(updates).Error; err != nil {
+			return 0, err
+		}
+	}
+	return len(sessions), nil
+}
+
+// retentionMode is the mode old addresses are anonymized with: the client's
+// own mode, or IP_ANONYMIZE_MODE for clients storing full addresses
+func (s *IPPrivacyService) retentionMode(clientID string) string {
+	if mode := s.Mode(clientID); mode != IPPrivacyFull {
+		return mode
+	}
+	if mode := configs.AppConfig.IPAnonymizeMode; ValidIPPrivacy(mode) && mode != IPPrivacyFull {
+		return mode
+	}
+	return IPPrivacyTruncated
+}
+
+// ValidIPPrivacy reports whether mode is a known IP privacy mode
+func ValidIPPrivacy(mode string) bool {
+	switch mode {
+	case IPPrivacyFull, IPPrivacyTruncated, IPPrivacyHashed, IPPrivacyNone:
+		return true
+	}
+	return false
+}
+
+// AnonymizeIP returns the form of an address stored under a mode. Hashes are
+// not addresses and pass through, and truncation is idempotent, so a mode can
+// be applied again to values already stored.
+func AnonymizeIP(mode, clientID, ipAddress string) string {
+	if mode == IPPrivacyNone {
+		return ""
+	}
+	ip := net.ParseIP(ipAddress)
+	if ip == nil {
+		return ipAddress
+	}
+
+	switch mode {
+	case IPPrivacyTruncated:
+		return TruncateIP(ip).String()
+	case IPPrivacyHashed:
+		return HashIP(clientID, ip.String())
+	}
+	return ipAddress
+}
+
+// TruncateIP zeroes the host part of an address: the last octet of IPv4
+// addresses and all but the first 48 bits of IPv6 addresses
+func TruncateIP(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(ipv4TruncatedPrefix, 32))
+	}
+	return ip.Mask(net.CIDRMask(ipv6TruncatedPrefix, 128))
+}
+
+// HashIP pseudonymizes an address. The client ID is part of the input so the
+// same address seen by two clients cannot be linked.
+func HashIP(clientID, ipAddress string) string {
+	mac := hmac.New(sha256.New, []byte(configs.AppConfig.IPHashKey))
+	mac.Write([]byte(clientID))
+	mac.Write([]byte{0})
+	mac.Write([]byte(ipAddress))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+func ipPrivacyCacheKey(clientID string) string {
+	return fmt.Sprintf("ip-privacy:%s", clientID)
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"log"
+	"sort"
+	"sync"
+
+	"user-activity-tracker/configs"
+	"user-activity-tracker/internal/database"
+	"user-activity-tracker/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LabelOverflowValue replaces label values beyond a key's cardinality limit
+const LabelOverflowValue = "__other__"
+
+// LabelService enforces per-client label cardinality limits. Keys beyond the
+// key limit are dropped; new values beyond the value limit are folded into
+// LabelOverflowValue so totals stay correct. The limits are checked in MySQL
+// when a value is first seen, so they hold across instances; what is already
+// decided is cached here.
+type LabelService struct {
+	db        *gorm.DB
+	maxKeys   int
+	maxValues int
+
+	mu    sync.Mutex
+	known map[string]*clientLabels
+}
+
+// clientLabels is what an instance knows about one client's labels. Rejected
+// keys and full keys never change, since registered values are never removed.
+type clientLabels struct {
+	values   map[string]map[string]struct{} // key -> registered values
+	full     map[string]bool                // keys at the value limit
+	rejected map[string]bool                // keys refused by the key limit
+}
+
+// labelOutcome is the result of registering a label value
+type labelOutcome int
+
+const (
+	labelRegistered labelOutcome = iota
+	labelOverflow
+	labelKeyRejected
+)
+
+func NewLabelService() *LabelService {
+	return &LabelService{
+		db:        database.GetDBManager().WriteDB,
+		maxKeys:   configs.AppConfig.LabelMaxKeysPerClient,
+		maxValues: configs.AppConfig.LabelMaxValuesPerKey,
+		known:     make(map[string]*clientLabels),
+	}
+}
+
+// Apply is the ingestion stage enforcing the cardinality limits on a hit's labels
+func (s *LabelService) Apply(hit *models.APILogs) bool {
+	if len(hit.Labels) == 0 {
+		return true
+	}
+
+	keys := make([]string, 0, len(hit.Labels))
+	for key := range hit.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	labels := s.clientLabels(hit.ClientID)
+	for _, key := range keys {
+		value := hit.Labels[key]
+
+		s.mu.Lock()
+		_, known := labels.values[key][value]
+		rejected, full := labels.rejected[key], labels.full[key]
+		s.mu.Unlock()
+
+		switch {
+		case known:
+			continue
+		case rejected:
+			delete(hit.Labels, key)
+			continue
+		case full:
+			hit.Labels[key] = LabelOverflowValue
+			continue
+		}
+
+		outcome, values, err := s.register(hit.ClientID, key, value)
+		if err != nil {
+			log.Printf("Failed to register label %s for client %s: %v", key, hit.ClientID, err)
+			continue
+		}
+
+		s.mu.Lock()
+		switch outcome {
+		case labelRegistered:
+			labels.add(key, value)
+		case labelOverflow:
+			for _, v := range values {
+				labels.add(key, v)
+			}
+			labels.full[key] = true
+		case labelKeyRejected:
+			labels.rejected[key] = true
+		}
+		s.mu.Unlock()
+
+		switch outcome {
+		case labelOverflow:
+			hit.Labels[key] = LabelOverflowValue
+		case labelKeyRejected:
+			delete(hit.Labels, key)
+		}
+	}
+	return true
+}
+
+// clientLabels returns the known label values of a client, loading them on first use
+func (s *LabelService) clientLabels(clientID string) *clientLabels {
+	s.mu.Lock()
+	labels, ok := s.known[clientID]
+	s.mu.Unlock()
+	if ok {
+		return labels
+	}
+
+	labels = &clientLabels{
+		values:   make(map[string]map[string]struct{}),
+		full:     make(map[string]bool),
+		rejected: make(map[string]bool),
+	}
+
+	var rows []models.ClientLabelValue
+	if err := s.db.Where("client_id = ?", clientID).Find(&rows).Error; err != nil {
+		log.Printf("Failed to load labels for client %s: %v", clientID, err)
+		return labels
+	}
+	for _, row := range rows {
+		labels.add(row.Key, row.Value)
+	}
+	for key, values := range labels.values {
+		labels.full[key] = len(values) >= s.maxValues
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.known[clientID]; ok {
+		return existing
+	}
+	s.known[clientID] = labels
+	return labels
+}
+
+func (l *clientLabels) add(key, value string) {
+	if l.values[key] == nil {
+		l.values[key] = make(map[string]struct{})
+	}
+	l.values[key][value] = struct{}{}
+}
+
+// register records a new label value unless it breaks a limit. The client row
+// is locked so concurrent registrations on any instance are checked in turn.
+// On labelOverflow the key's registered values are returned.
+func (s *LabelService) register(clientID, key, value string) (labelOutcome, []string, error) {
+	outcome := labelRegistered
+	var values []string
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var locked []string
+		if err := tx.Raw("SELECT client_id FROM clients WHERE client_id = ? FOR UPDATE", clientID).
+			Scan(&locked).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.ClientLabelValue{}).
+			Where("client_id = ? AND label_key = ?", clientID, key).
+			Pluck("label_value", &values).Error; err != nil {
+			return err
+		}
+		for _, v := range values {
+			if v == value {
+				return nil
+			}
+		}
+
+		if len(values) == 0 {
+			var keys int64
+			if err := tx.Model(&models.ClientLabelValue{}).
+				Where("client_id = ?", clientID).
+				Distinct("label_key").
+				Count(&keys).Error; err != nil {
+				return err
+			}
+			if keys >= int64(s.maxKeys) {
+				outcome = labelKeyRejected
+				return nil
+			}
+		}
+		if len(values) >= s.maxValues {
+			outcome = labelOverflow
+			return nil
+		}
+
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.ClientLabelValue{ClientID: clientID, Key: key, Value: value}).Error
+	})
+	return outcome, values, err
+}
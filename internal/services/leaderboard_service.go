@@ -0,0 +1,643 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"user-activity-tracker/configs"
+	"user-activity-tracker/internal/cache"
+	"user-activity-tracker/internal/database"
+	"user-activity-tracker/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Leaderboard metrics
+const (
+	MetricRequests = "requests" // weighted hits
+	MetricIPs      = "ips"      // distinct stored IP addresses
+	MetricErrors   = "errors"   // weighted hits with a 4xx or 5xx status
+)
+
+// MaxLeaderboardSize is the number of entries kept per leaderboard
+const MaxLeaderboardSize = 100
+
+const (
+	minLeaderboardWindow = time.Minute
+	maxLeaderboardWindow = 90 * 24 * time.Hour
+)
+
+// LeaderboardMetrics lists the metrics in the order they are documented
+var LeaderboardMetrics = []string{MetricRequests, MetricIPs, MetricErrors}
+
+// IPLeaderboardMetrics are the metrics IP addresses are ranked by
+var IPLeaderboardMetrics = []string{MetricRequests, MetricErrors}
+
+// metricColumns maps a metric onto the leaderboard row column it is read from
+var metricColumns = map[string]string{
+	MetricRequests: "request_count",
+	MetricIPs:      "ips",
+	MetricErrors:   "errors",
+}
+
+// EndpointTemplateColumn is the route template of a hit, or its raw endpoint
+// when the hit was recorded before normalization
+const EndpointTemplateColumn = "COALESCE(NULLIF(endpoint_template, ''), endpoint)"
+
+// rankColumns are the metrics of a leaderboard row
+const rankColumns = "CAST(ROUND(SUM(sample_weight)) AS SIGNED) AS request_count, " +
+	"COUNT(DISTINCT NULLIF(ip_address, '')) AS ips, " +
+	"CAST(ROUND(SUM(CASE WHEN status_code >= 400 THEN sample_weight ELSE 0 END)) AS SIGNED) AS errors"
+
+// presetWindows are the windows whose client leaderboards are precomputed, with
+// how often each is refreshed; longer windows change more slowly
+var presetWindows = []struct {
+	name    string
+	period  string
+	window  time.Duration
+	refresh time.Duration
+}{
+	{"1h", "last_hour", time.Hour, time.Minute},
+	{"24h", "last_24_hours", 24 * time.Hour, 5 * time.Minute},
+	{"7d", "last_7_days", 7 * 24 * time.Hour, 30 * time.Minute},
+	{"30d", "last_30_days", 30 * 24 * time.Hour, time.Hour},
+}
+
+// Window is the period a leaderboard covers, ending now
+type Window struct {
+	Name     string
+	Period   string
+	Duration time.Duration
+	preset   bool
+}
+
+// ClientRank is a client's entry on a leaderboard
+type ClientRank struct {
+	ClientID     string `json:"client_id"`
+	Name         string `json:"name"`
+	Value        int64  `json:"value"`
+	RequestCount int64  `json:"request_count"`
+	IPs          int64  `json:"-" gorm:"column:ips"`
+	Errors       int64  `json:"-"`
+}
+
+// ClientLeaderboard ranks clients by one metric over a window
+type ClientLeaderboard struct {
+	Window      string       `json:"window"`
+	Metric      string       `json:"metric"`
+	GeneratedAt time.Time    `json:"generated_at"`
+	Clients     []ClientRank `json:"clients"`
+}
+
+// EndpointRank is an endpoint's entry on a client's leaderboard
+type EndpointRank struct {
+	ClientID     string `json:"-"`
+	Endpoint     string `json:"endpoint"`
+	Value        int64  `json:"value"`
+	RequestCount int64  `json:"request_count"`
+
+	// Not set on real-time request leaderboards
+	IPs    *int64 `json:"ips,omitempty" gorm:"column:ips"`
+	Errors *int64 `json:"errors,omitempty"`
+}
+
+// EndpointLeaderboard ranks a client's endpoints by one metric over a window
+type EndpointLeaderboard struct {
+	Window      string         `json:"window"`
+	Metric      string         `json:"metric"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	Endpoints   []EndpointRank `json:"endpoints"`
+}
+
+// IPRank is an IP address's entry on a client's leaderboard
+type IPRank struct {
+	ClientID     string `json:"-"`
+	IPAddress    string `json:"ip_address"`
+	Value        int64  `json:"value"`
+	RequestCount int64  `json:"request_count"`
+	Errors       int64  `json:"errors"`
+}
+
+// IPLeaderboard ranks a client's stored IP addresses by one metric over a window
+type IPLeaderboard struct {
+	Window      string    `json:"window"`
+	Metric      string    `json:"metric"`
+	GeneratedAt time.Time `json:"generated_at"`
+	IPs         []IPRank  `json:"ips"`
+}
+
+// LeaderboardService ranks clients, and each client's endpoints and IP
+// addresses, by requests, distinct IPs and errors. Request leaderboards of
+// windows up to a day are read from real-time counters; the preset windows are
+// precomputed in the background for the other metrics, so reading them does
+// not group api_logs; one instance refreshes each window at a time.
+type LeaderboardService struct {
+	cache *cache.CacheManager
+	topK  *topKCounter
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewLeaderboardService() *LeaderboardService {
+	return &LeaderboardService{
+		cache: cache.GetCacheManager(),
+		topK:  newTopKCounter(),
+		stop:  make(chan struct{}),
+	}
+}
+
+// ParseWindow reads a window: 1h, 24h, 7d, 30d, or a custom duration such as
+// 90m, 6h or 14d between a minute and 90 days
+func ParseWindow(value string) (Window, error) {
+	for _, preset := range presetWindows {
+		if value == preset.name {
+			return Window{Name: preset.name, Period: preset.period, Duration: preset.window, preset: true}, nil
+		}
+	}
+
+	var d time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return Window{}, fmt.Errorf("window %q is invalid", value)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(value); err != nil {
+			return Window{}, fmt.Errorf("window %q is invalid", value)
+		}
+	}
+	if d < minLeaderboardWindow || d > maxLeaderboardWindow {
+		return Window{}, fmt.Errorf("window must be between 1m and 90d")
+	}
+
+	// Equivalent custom windows share the precomputed leaderboard
+	for _, preset := range presetWindows {
+		if d == preset.window {
+			return Window{Name: preset.name, Period: preset.period, Duration: d, preset: true}, nil
+		}
+	}
+	return Window{Name: value, Period: "last_" + value, Duration: d}, nil
+}
+
+// Record counts stored hits into the real-time leaderboards by their sample
+// weight. Hits sampled out are not stored and must not be recorded.
+func (s *LeaderboardService) Record(hits ...models.APILogs) {
+	s.topK.record(hits)
+}
+
+// Realtime reports whether leaderboards of the window and metric are read from
+// the real-time counters
+func (s *LeaderboardService) Realtime(window Window, metric string) bool {
+	return metric == MetricRequests && s.topK.covers(window)
+}
+
+// TopClients returns the client leaderboard of a window and metric. Real-time
+// request leaderboards are merged from the minute counters; other preset
+// windows are served from the precomputed boards, computing them on a miss;
+// custom windows are computed and cached for CACHE_TTL.
+func (s *LeaderboardService) TopClients(window Window, metric string) (ClientLeaderboard, error) {
+	if s.Realtime(window, metric) {
+		board, err := s.realtimeClients(window)
+		if err == nil {
+			return board, nil
+		}
+		log.Printf("Failed to read real-time leaderboard, falling back to MySQL: %v", err)
+	}
+
+	cacheKey := leaderboardCacheKey(window, metric)
+
+	var board ClientLeaderboard
+	if found, err := s.cache.Get(cacheKey, &board); found && err == nil {
+		return board, nil
+	}
+
+	boards, err := s.compute(window)
+	if err != nil {
+		return ClientLeaderboard{}, err
+	}
+	s.store(window, boards)
+	return boards[metric], nil
+}
+
+// TopEndpoints returns the leaderboard of a client's endpoints (route templates
+// when known). Real-time request leaderboards are merged from the minute
+// counters; other leaderboards are served like those of TopClients.
+func (s *LeaderboardService) TopEndpoints(clientID string, window Window, metric string) (EndpointLeaderboard, error) {
+	if s.Realtime(window, metric) {
+		board, err := s.realtimeEndpoints(clientID, window)
+		if err == nil {
+			return board, nil
+		}
+		log.Printf("Failed to read real-time endpoint leaderboard, falling back to MySQL: %v", err)
+	}
+
+	var board EndpointLeaderboard
+	if found, err := s.cache.Get(endpointLeaderboardCacheKey(clientID, window, metric), &board); found && err == nil {
+		return board, nil
+	}
+
+	boards, err := RankEndpoints(s.hits(window).Where("client_id = ?", clientID), window)
+	if err != nil {
+		return EndpointLeaderboard{}, err
+	}
+	if _, ok := boards[clientID]; !ok {
+		boards[clientID] = make(map[string]EndpointLeaderboard, len(LeaderboardMetrics))
+		for _, m := range LeaderboardMetrics {
+			boards[clientID][m] = EndpointLeaderboard{Window: window.Name, Metric: m, GeneratedAt: time.Now(), Endpoints: []EndpointRank{}}
+		}
+	}
+	s.storeEndpoints(window, boards)
+	return boards[clientID][metric], nil
+}
+
+// TopIPs returns the leaderboard of the IP addresses stored for a client's
+// hits, served like those of TopClients
+func (s *LeaderboardService) TopIPs(clientID string, window Window, metric string) (IPLeaderboard, error) {
+	var board IPLeaderboard
+	if found, err := s.cache.Get(ipLeaderboardCacheKey(clientID, window, metric), &board); found && err == nil {
+		return board, nil
+	}
+
+	boards, err := RankIPs(s.hits(window).Where("client_id = ?", clientID), window)
+	if err != nil {
+		return IPLeaderboard{}, err
+	}
+	if _, ok := boards[clientID]; !ok {
+		boards[clientID] = make(map[string]IPLeaderboard, len(IPLeaderboardMetrics))
+		for _, m := range IPLeaderboardMetrics {
+			boards[clientID][m] = IPLeaderboard{Window: window.Name, Metric: m, GeneratedAt: time.Now(), IPs: []IPRank{}}
+		}
+	}
+	s.storeIPs(window, boards)
+	return boards[clientID][metric], nil
+}
+
+// realtimeEndpoints builds a client's request leaderboard of endpoints from
+// the real-time counters
+func (s *LeaderboardService) realtimeEndpoints(clientID string, window Window) (EndpointLeaderboard, error) {
+	board := EndpointLeaderboard{Window: window.Name, Metric: MetricRequests, GeneratedAt: time.Now(), Endpoints: []EndpointRank{}}
+
+	entries, err := s.topK.topEndpoints(clientID, window, MaxLeaderboardSize)
+	if err != nil {
+		return board, err
+	}
+	for _, entry := range entries {
+		count := int64(math.Round(entry.count))
+		board.Endpoints = append(board.Endpoints, EndpointRank{
+			ClientID:     clientID,
+			Endpoint:     entry.member,
+			Value:        count,
+			RequestCount: count,
+		})
+	}
+	return board, nil
+}
+
+// realtimeClients builds a request leaderboard from the real-time counters,
+// naming clients from the clients table
+func (s *LeaderboardService) realtimeClients(window Window) (ClientLeaderboard, error) {
+	board := ClientLeaderboard{Window: window.Name, Metric: MetricRequests, GeneratedAt: time.Now(), Clients: []ClientRank{}}
+
+	entries, err := s.topK.topClients(window, MaxLeaderboardSize)
+	if err != nil || len(entries) == 0 {
+		return board, err
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.member)
+	}
+	var clients []struct {
+		ClientID string
+		Name     string
+	}
+	err = database.GetDBManager().GetReadDB().Table("clients").
+		Select("client_id, name").
+		Where("client_id IN ?", ids).
+		Scan(&clients).Error
+	if err != nil {
+		return board, err
+	}
+	names := make(map[string]string, len(clients))
+	for _, client := range clients {
+		names[client.ClientID] = client.Name
+	}
+
+	for _, entry := range entries {
+		name, ok := names[entry.member]
+		if !ok {
+			continue
+		}
+		count := int64(math.Round(entry.count))
+		board.Clients = append(board.Clients, ClientRank{
+			ClientID:     entry.member,
+			Name:         name,
+			Value:        count,
+			RequestCount: count,
+		})
+	}
+	return board, nil
+}
+
+// RankClients groups the hits selected by query per client and ranks clients
+// by every metric. The query must expose the hits as api_logs.
+func RankClients(query *gorm.DB, window Window) (map[string]ClientLeaderboard, error) {
+	var ranks []ClientRank
+	err := query.
+		Select("clients.client_id, clients.name, " +
+			"CAST(ROUND(SUM(api_logs.sample_weight)) AS SIGNED) AS request_count, " +
+			"COUNT(DISTINCT NULLIF(api_logs.ip_address, '')) AS ips, " +
+			"CAST(ROUND(SUM(CASE WHEN api_logs.status_code >= 400 THEN api_logs.sample_weight ELSE 0 END)) AS SIGNED) AS errors").
+		Joins("JOIN clients ON clients.client_id = api_logs.client_id").
+		Group("clients.client_id, clients.name").
+		Scan(&ranks).Error
+	if err != nil {
+		return nil, err
+	}
+
+	generatedAt := time.Now()
+	boards := make(map[string]ClientLeaderboard, len(LeaderboardMetrics))
+	for _, metric := range LeaderboardMetrics {
+		for i := range ranks {
+			ranks[i].Value = MetricValue(metric, ranks[i].RequestCount, ranks[i].IPs, ranks[i].Errors)
+		}
+		boards[metric] = ClientLeaderboard{
+			Window:      window.Name,
+			Metric:      metric,
+			GeneratedAt: generatedAt,
+			Clients: topRanked(ranks, func(r ClientRank) int64 { return r.Value },
+				func(r ClientRank) string { return r.ClientID }),
+		}
+	}
+	return boards, nil
+}
+
+// RankEndpoints groups the hits selected by query per client and endpoint and
+// ranks each client's endpoints by every metric
+func RankEndpoints(query *gorm.DB, window Window) (map[string]map[string]EndpointLeaderboard, error) {
+	var rows []EndpointRank
+	grouped := query.
+		Select("client_id, " + EndpointTemplateColumn + " AS endpoint, " + rankColumns).
+		Group("client_id, " + EndpointTemplateColumn)
+	if err := topPerClient(grouped, "endpoint", LeaderboardMetrics).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	generatedAt := time.Now()
+	boards := make(map[string]map[string]EndpointLeaderboard)
+	for clientID, ranks := range groupByClient(rows, func(r EndpointRank) string { return r.ClientID }) {
+		boards[clientID] = make(map[string]EndpointLeaderboard, len(LeaderboardMetrics))
+		for _, metric := range LeaderboardMetrics {
+			for i := range ranks {
+				ranks[i].Value = MetricValue(metric, ranks[i].RequestCount, *ranks[i].IPs, *ranks[i].Errors)
+			}
+			boards[clientID][metric] = EndpointLeaderboard{
+				Window:      window.Name,
+				Metric:      metric,
+				GeneratedAt: generatedAt,
+				Endpoints: topRanked(ranks, func(r EndpointRank) int64 { return r.Value },
+					func(r EndpointRank) string { return r.Endpoint }),
+			}
+		}
+	}
+	return boards, nil
+}
+
+// RankIPs groups the hits selected by query per client and stored IP address
+// and ranks each client's addresses by requests and errors
+func RankIPs(query *gorm.DB, window Window) (map[string]map[string]IPLeaderboard, error) {
+	var rows []IPRank
+	grouped := query.
+		Select("client_id, ip_address, " + rankColumns).
+		Where("ip_address <> ''").
+		Group("client_id, ip_address")
+	if err := topPerClient(grouped, "ip_address", IPLeaderboardMetrics).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	generatedAt := time.Now()
+	boards := make(map[string]map[string]IPLeaderboard)
+	for clientID, ranks := range groupByClient(rows, func(r IPRank) string { return r.ClientID }) {
+		boards[clientID] = make(map[string]IPLeaderboard, len(IPLeaderboardMetrics))
+		for _, metric := range IPLeaderboardMetrics {
+			for i := range ranks {
+				ranks[i].Value = MetricValue(metric, ranks[i].RequestCount, 0, ranks[i].Errors)
+			}
+			boards[clientID][metric] = IPLeaderboard{
+				Window:      window.Name,
+				Metric:      metric,
+				GeneratedAt: generatedAt,
+				IPs: topRanked(ranks, func(r IPRank) int64 { return r.Value },
+					func(r IPRank) string { return r.IPAddress }),
+			}
+		}
+	}
+	return boards, nil
+}
+
+// topPerClient selects the rows of grouped, per-client leaderboard rows, that
+// are among their client's top MaxLeaderboardSize by any of the metrics, so
+// clients with many endpoints or addresses do not have all of them read.
+// key breaks ties.
+func topPerClient(grouped *gorm.DB, key string, metrics []string) *gorm.DB {
+	ranks := make([]string, 0, len(metrics))
+	top := make([]string, 0, len(metrics))
+	for _, metric := range metrics {
+		ranks = append(ranks, fmt.Sprintf("ROW_NUMBER() OVER (PARTITION BY client_id ORDER BY %s DESC, %s) AS %s_rank",
+			metricColumns[metric], key, metric))
+		top = append(top, fmt.Sprintf("%s_rank <= %d", metric, MaxLeaderboardSize))
+	}
+
+	db := grouped.Session(&gorm.Session{NewDB: true})
+	ranked := db.Table("(?) AS grouped", grouped).Select("grouped.*, " + strings.Join(ranks, ", "))
+	return db.Table("(?) AS ranked", ranked).Where(strings.Join(top, " OR "))
+}
+
+// topRanked returns the first MaxLeaderboardSize rows with a value, highest
+// first and then by key
+func topRanked[T any](rows []T, value func(T) int64, key func(T) string) []T {
+	ranked := make([]T, 0, len(rows))
+	for _, row := range rows {
+		if value(row) > 0 {
+			ranked = append(ranked, row)
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if vi, vj := value(ranked[i]), value(ranked[j]); vi != vj {
+			return vi > vj
+		}
+		return key(ranked[i]) < key(ranked[j])
+	})
+	if len(ranked) > MaxLeaderboardSize {
+		ranked = ranked[:MaxLeaderboardSize]
+	}
+	return ranked
+}
+
+func groupByClient[T any](rows []T, clientID func(T) string) map[string][]T {
+	groups := make(map[string][]T)
+	for _, row := range rows {
+		groups[clientID(row)] = append(groups[clientID(row)], row)
+	}
+	return groups
+}
+
+// Start runs the real-time counters and refreshes the preset leaderboards as
+// they come due
+func (s *LeaderboardService) Start() {
+	s.topK.start(&s.wg, s.stop)
+
+	if !configs.AppConfig.PrecomputeLeaderboards {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		refreshed := make(map[string]time.Time)
+		for {
+			for _, preset := range presetWindows {
+				if time.Since(refreshed[preset.name]) < preset.refresh {
+					continue
+				}
+				refreshed[preset.name] = time.Now()
+
+				// Only one instance refreshes a window per period
+				locked, err := s.cache.SetNX("usage:top:refresh:"+preset.name, true, preset.refresh-time.Second)
+				if err != nil || !locked {
+					continue
+				}
+				window := Window{Name: preset.name, Period: preset.period, Duration: preset.window, preset: true}
+				if err := s.refresh(window); err != nil {
+					log.Printf("Failed to refresh %s leaderboards: %v", preset.name, err)
+				}
+			}
+
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop waits for the running refresh, flushes buffered counts and stops
+func (s *LeaderboardService) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// refresh precomputes the client leaderboards of a window and the endpoint
+// and IP leaderboards of every client with hits in it
+func (s *LeaderboardService) refresh(window Window) error {
+	boards, err := s.compute(window)
+	if err != nil {
+		return err
+	}
+	s.store(window, boards)
+
+	endpoints, err := RankEndpoints(s.hits(window), window)
+	if err != nil {
+		return err
+	}
+	s.storeEndpoints(window, endpoints)
+
+	ips, err := RankIPs(s.hits(window), window)
+	if err != nil {
+		return err
+	}
+	s.storeIPs(window, ips)
+	return nil
+}
+
+// compute ranks clients over the window from the read replica
+func (s *LeaderboardService) compute(window Window) (map[string]ClientLeaderboard, error) {
+	return RankClients(s.hits(window), window)
+}
+
+// hits selects the hits of the window from the read replica
+func (s *LeaderboardService) hits(window Window) *gorm.DB {
+	return database.GetDBManager().GetReadDB().Table("api_logs").
+		Where("api_logs.timestamp >= ?", time.Now().Add(-window.Duration))
+}
+
+// store caches the client leaderboards of a window
+func (s *LeaderboardService) store(window Window, boards map[string]ClientLeaderboard) {
+	for metric, board := range boards {
+		s.cache.Set(leaderboardCacheKey(window, metric), board, leaderboardTTL(window))
+	}
+}
+
+// storeEndpoints caches the endpoint leaderboards of a window per client
+func (s *LeaderboardService) storeEndpoints(window Window, boards map[string]map[string]EndpointLeaderboard) {
+	for clientID, clientBoards := range boards {
+		for metric, board := range clientBoards {
+			s.cache.Set(endpointLeaderboardCacheKey(clientID, window, metric), board, leaderboardTTL(window))
+		}
+	}
+}
+
+// storeIPs caches the IP leaderboards of a window per client
+func (s *LeaderboardService) storeIPs(window Window, boards map[string]map[string]IPLeaderboard) {
+	for clientID, clientBoards := range boards {
+		for metric, board := range clientBoards {
+			s.cache.Set(ipLeaderboardCacheKey(clientID, window, metric), board, leaderboardTTL(window))
+		}
+	}
+}
+
+// leaderboardTTL is how long leaderboards of a window are cached. Precomputed
+// boards outlive their refresh period so readers never wait for a refresh.
+func leaderboardTTL(window Window) time.Duration {
+	for _, preset := range presetWindows {
+		if window.preset && preset.name == window.Name {
+			return 2 * preset.refresh
+		}
+	}
+	return configs.AppConfig.CacheTTL
+}
+
+// MetricValue returns the value of a leaderboard entry for the metric
+func MetricValue(metric string, requests, ips, errors int64) int64 {
+	switch metric {
+	case MetricIPs:
+		return ips
+	case MetricErrors:
+		return errors
+	}
+	return requests
+}
+
+// leaderboardCacheKey names preset windows and keys custom ones by length, so
+// 360m and 6h share an entry
+func leaderboardCacheKey(window Window, metric string) string {
+	return fmt.Sprintf("usage:top:%s:%s", windowCacheKey(window), metric)
+}
+
+func endpointLeaderboardCacheKey(clientID string, window Window, metric string) string {
+	return fmt.Sprintf("usage:top-endpoints:%s:%s:%s", clientID, windowCacheKey(window), metric)
+}
+
+func ipLeaderboardCacheKey(clientID string, window Window, metric string) string {
+	return fmt.Sprintf("usage:top-ips:%s:%s:%s", clientID, windowCacheKey(window), metric)
+}
+
+func windowCacheKey(window Window) string {
+	if window.preset {
+		return window.Name
+	}
+	return fmt.Sprintf("%ds", int64(window.Duration.Seconds()))
+}
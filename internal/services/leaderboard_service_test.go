@@ -0,0 +1,131 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		value    string
+		name     string
+		period   string
+		duration time.Duration
+		preset   bool
+	}{
+		{"1h", "1h", "last_hour", time.Hour, true},
+		{"24h", "24h", "last_24_hours", 24 * time.Hour, true},
+		{"7d", "7d", "last_7_days", 7 * 24 * time.Hour, true},
+		{"30d", "30d", "last_30_days", 30 * 24 * time.Hour, true},
+		// Custom windows equal to a preset share it
+		{"60m", "1h", "last_hour", time.Hour, true},
+		{"1d", "24h", "last_24_hours", 24 * time.Hour, true},
+		{"168h", "7d", "last_7_days", 7 * 24 * time.Hour, true},
+		{"90m", "90m", "last_90m", 90 * time.Minute, false},
+		{"6h", "6h", "last_6h", 6 * time.Hour, false},
+		{"14d", "14d", "last_14d", 14 * 24 * time.Hour, false},
+		{"1m", "1m", "last_1m", time.Minute, false},
+		{"90d", "90d", "last_90d", 90 * 24 * time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			window, err := ParseWindow(tt.value)
+			if err != nil {
+				t.Fatalf("ParseWindow: %v", err)
+			}
+			want := Window{Name: tt.name, Period: tt.period, Duration: tt.duration, preset: tt.preset}
+			if window != want {
+				t.Errorf("ParseWindow(%q) = %+v, want %+v", tt.value, window, want)
+			}
+		})
+	}
+
+	for _, value := range []string{"", "h", "d", "1.5d", "-1h", "1w", "59s", "0d", "91d", "2161h"} {
+		if window, err := ParseWindow(value); err == nil {
+			t.Errorf("ParseWindow(%q) = %+v, want an error", value, window)
+		}
+	}
+}
+
+func TestWindowCacheKey(t *testing.T) {
+	a, _ := ParseWindow("360m")
+	b, _ := ParseWindow("6h")
+	preset, _ := ParseWindow("1d")
+	if windowCacheKey(a) != windowCacheKey(b) {
+		t.Errorf("360m and 6h have different keys: %q, %q", windowCacheKey(a), windowCacheKey(b))
+	}
+	if windowCacheKey(preset) != "24h" {
+		t.Errorf("1d key = %q, want the 24h preset", windowCacheKey(preset))
+	}
+	if leaderboardTTL(preset) != 10*time.Minute {
+		t.Errorf("24h TTL = %v, want twice its refresh period", leaderboardTTL(preset))
+	}
+}
+
+func TestTopRanked(t *testing.T) {
+	var rows []IPRank
+	for i := 0; i < MaxLeaderboardSize+20; i++ {
+		rows = append(rows, IPRank{IPAddress: fmt.Sprintf("198.51.100.%d", i), Value: int64(i % 7)})
+	}
+
+	ranked := topRanked(rows, func(r IPRank) int64 { return r.Value }, func(r IPRank) string { return r.IPAddress })
+	if len(ranked) != MaxLeaderboardSize {
+		t.Fatalf("%d rows, want %d", len(ranked), MaxLeaderboardSize)
+	}
+	for i, row := range ranked {
+		if row.Value == 0 {
+			t.Fatalf("row %d has no value", i)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := ranked[i-1]
+		if prev.Value < row.Value || prev.Value == row.Value && prev.IPAddress > row.IPAddress {
+			t.Fatalf("rows %d and %d are out of order: %+v, %+v", i-1, i, prev, row)
+		}
+	}
+
+	if got := topRanked([]IPRank{{Value: 0}}, func(r IPRank) int64 { return r.Value }, func(r IPRank) string { return r.IPAddress }); got == nil || len(got) != 0 {
+		t.Errorf("rows without a value = %#v, want an empty leaderboard", got)
+	}
+}
+
+func TestTopPerClient(t *testing.T) {
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "test@tcp(127.0.0.1:1)/test", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grouped := db.Table("api_logs").
+		Where("api_logs.timestamp >= ?", time.Unix(0, 0)).
+		Select("client_id, ip_address, " + rankColumns).
+		Group("client_id, ip_address")
+	var rows []IPRank
+	stmt := topPerClient(grouped, "ip_address", IPLeaderboardMetrics).Scan(&rows).Statement
+	sql := stmt.SQL.String()
+
+	for _, want := range []string{
+		"FROM (SELECT grouped.*, ",
+		"ROW_NUMBER() OVER (PARTITION BY client_id ORDER BY request_count DESC, ip_address) AS requests_rank",
+		"ROW_NUMBER() OVER (PARTITION BY client_id ORDER BY errors DESC, ip_address) AS errors_rank",
+		"GROUP BY client_id, ip_address) AS grouped) AS ranked",
+		fmt.Sprintf("WHERE requests_rank <= %d OR errors_rank <= %d", MaxLeaderboardSize, MaxLeaderboardSize),
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("SQL = %s\nwant it to contain %q", sql, want)
+		}
+	}
+	if strings.Contains(sql, "ips_rank") {
+		t.Error("IP addresses are ranked by distinct IPs")
+	}
+	if len(stmt.Vars) != 1 {
+		t.Errorf("vars = %v, want the window start only", stmt.Vars)
+	}
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"user-activity-tracker/internal/cache"
+	"user-activity-tracker/internal/database"
+	"user-activity-tracker/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	maxRouteTemplatesPerClient = 200
+	routeTemplateCacheTTL      = 5 * time.Minute
+)
+
+var (
+	ErrInvalidRouteTemplate   = errors.New("invalid route template")
+	ErrTooManyRouteTemplates  = fmt.Errorf("a client may define at most %d route templates", maxRouteTemplatesPerClient)
+	ErrRouteTemplateNotFound  = errors.New("route template not found")
+	ErrRouteTemplateDuplicate = errors.New("route template already exists")
+)
+
+// Built-in rules replacing high-cardinality path segments
+var (
+	numericSegment = regexp.MustCompile(`^\d+$`)
+	uuidSegment    = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hashSegment    = regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)
+)
+
+// EndpointNormalizer maps raw endpoints onto route templates so that
+// /users/123 and /users/456 are both counted as /users/:id
+type EndpointNormalizer struct {
+	db    *gorm.DB
+	cache *cache.CacheManager
+}
+
+func NewEndpointNormalizer() *EndpointNormalizer {
+	return &EndpointNormalizer{
+		db:    database.GetDBManager().WriteDB,
+		cache: cache.GetCacheManager(),
+	}
+}
+
+// Apply is the ingestion stage storing the normalized template on the hit
+func (n *EndpointNormalizer) Apply(hit *models.APILogs) bool {
+	hit.EndpointTemplate = n.Normalize(hit.ClientID, hit.Endpoint)
+	return true
+}
+
+// Normalize returns the route template for an endpoint. Client templates take
+// precedence; otherwise the built-in rules replace IDs, UUIDs and hashes.
+func (n *EndpointNormalizer) Normalize(clientID, endpoint string) string {
+	path := stripQuery(endpoint)
+	segments := splitPath(path)
+
+	for _, template := range n.clientTemplates(clientID) {
+		if matchTemplate(splitPath(template), segments) {
+			return template
+		}
+	}
+
+	for i, segment := range segments {
+		switch {
+		case numericSegment.MatchString(segment):
+			segments[i] = ":id"
+		case uuidSegment.MatchString(segment):
+			segments[i] = ":uuid"
+		case hashSegment.MatchString(segment):
+			segments[i] = ":hash"
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// Templates lists a client's configured route templates
+func (n *EndpointNormalizer) Templates(clientID string) ([]models.RouteTemplate, error) {
+	var templates []models.RouteTemplate
+	err := n.db.Where("client_id = ?", clientID).Order("id").Find(&templates).Error
+	return templates, err
+}
+
+// AddTemplate stores a new route template for the client
+func (n *EndpointNormalizer) AddTemplate(clientID, template string) (*models.RouteTemplate, error) {
+	template, err := ValidateRouteTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := n.db.Model(&models.RouteTemplate{}).Where("client_id = ?", clientID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count >= maxRouteTemplatesPerClient {
+		return nil, ErrTooManyRouteTemplates
+	}
+
+	var existing int64
+	n.db.Model(&models.RouteTemplate{}).Where("client_id = ? AND template = ?", clientID, template).Count(&existing)
+	if existing > 0 {
+		return nil, ErrRouteTemplateDuplicate
+	}
+
+	record := models.RouteTemplate{ClientID: clientID, Template: template}
+	if err := n.db.Create(&record).Error; err != nil {
+		return nil, err
+	}
+
+	n.cache.Delete(routeTemplateCacheKey(clientID))
+	return &record, nil
+}
+
+// DeleteTemplate removes one of the client's route templates
+func (n *EndpointNormalizer) DeleteTemplate(clientID string, id uint) error {
+	result := n.db.Where("client_id = ? AND id = ?", clientID, id).Delete(&models.RouteTemplate{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRouteTemplateNotFound
+	}
+
+	n.cache.Delete(routeTemplateCacheKey(clientID))
+	return nil
+}
+
+// clientTemplates returns the client's templates ordered from most to least specific
+func (n *EndpointNormalizer) clientTemplates(clientID string) []string {
+	cacheKey := routeTemplateCacheKey(clientID)
+
+	var templates []string
+	if found, err := n.cache.Get(cacheKey, &templates); found && err == nil {
+		return templates
+	}
+
+	records, err := n.Templates(clientID)
+	if err != nil {
+		return nil
+	}
+
+	templates = make([]string, 0, len(records))
+	for _, record := range records {
+		templates = append(templates, record.Template)
+	}
+	sort.SliceStable(templates, func(i, j int) bool {
+		return templateSpecificity(templates[i]) > templateSpecificity(templates[j])
+	})
+
+	n.cache.Set(cacheKey, templates, routeTemplateCacheTTL)
+	return templates
+}
+
+// ValidateRouteTemplate checks and canonicalizes a template such as
+// /users/:id/orders/{order_id} or /static/*
+func ValidateRouteTemplate(template string) (string, error) {
+	template = strings.TrimSpace(template)
+	if !strings.HasPrefix(template, "/") || len(template) > 500 {
+		return "", fmt.Errorf("%w: must start with / and be at most 500 characters", ErrInvalidRouteTemplate)
+	}
+	if strings.ContainsAny(template, "?#") {
+		return "", fmt.Errorf("%w: must not contain a query string or fragment", ErrInvalidRouteTemplate)
+	}
+
+	segments := splitPath(template)
+	for i, segment := range segments {
+		if segment == "*" && i != len(segments)-1 {
+			return "", fmt.Errorf("%w: * is only allowed as the last segment", ErrInvalidRouteTemplate)
+		}
+		if segment == ":" || segment == "{}" {
+			return "", fmt.Errorf("%w: parameters need a name", ErrInvalidRouteTemplate)
+		}
+	}
+	return "/" + strings.Join(segments, "/"), nil
+}
+
+// matchTemplate reports whether path segments fit a template. Parameters
+// (:name or {name}) match one segment; a trailing * matches the rest.
+func matchTemplate(template, path []string) bool {
+	for i, segment := range template {
+		if segment == "*" {
+			return true
+		}
+		if i >= len(path) {
+			return false
+		}
+		if isTemplateParam(segment) {
+			continue
+		}
+		if segment != path[i] {
+			return false
+		}
+	}
+	return len(template) == len(path)
+}
+
+// templateSpecificity ranks templates so literal segments win over parameters and wildcards
+func templateSpecificity(template string) int {
+	score := 0
+	for _, segment := range splitPath(template) {
+		switch {
+		case segment == "*":
+		case isTemplateParam(segment):
+			score += 1
+		default:
+			score += 3
+		}
+	}
+	return score
+}
+
+func isTemplateParam(segment string) bool {
+	return strings.HasPrefix(segment, ":") ||
+		(strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}"))
+}
+
+func stripQuery(endpoint string) string {
+	if i := strings.IndexAny(endpoint, "?#"); i >= 0 {
+		return endpoint[:i]
+	}
+	return endpoint
+}
+
+func splitPath(path string) []string {
+	parts := strings.Split(path, "/")
+	segments := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part != "" {
+			segments = append(segments, part)
+		}
+	}
+	return segments
+}
+
+func routeTemplateCacheKey(clientID string) string {
+	return fmt.Sprintf("route_templates:%s", clientID)
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateRouteTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{name: "plain", template: "/users", want: "/users"},
+		{name: "colon param", template: "/users/:id/orders", want: "/users/:id/orders"},
+		{name: "brace param", template: "/users/{user_id}", want: "/users/{user_id}"},
+		{name: "trailing wildcard", template: "/static/*", want: "/static/*"},
+		{name: "trims and collapses slashes", template: "  /users//:id/ ", want: "/users/:id"},
+		{name: "root", template: "/", want: "/"},
+		{name: "missing leading slash", template: "users/:id", wantErr: true},
+		{name: "empty", template: "", wantErr: true},
+		{name: "query string", template: "/users?page=1", wantErr: true},
+		{name: "fragment", template: "/users#top", wantErr: true},
+		{name: "wildcard not last", template: "/static/*/file", wantErr: true},
+		{name: "unnamed colon param", template: "/users/:", wantErr: true},
+		{name: "unnamed brace param", template: "/users/{}", wantErr: true},
+		{name: "too long", template: "/" + strings.Repeat("a", 500), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateRouteTemplate(tt.template)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidRouteTemplate) {
+					t.Fatalf("ValidateRouteTemplate(%q) error = %v, want ErrInvalidRouteTemplate", tt.template, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateRouteTemplate(%q) unexpected error: %v", tt.template, err)
+			}
+			if got != tt.want {
+				t.Errorf("ValidateRouteTemplate(%q) = %q, want %q", tt.template, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		path     string
+		want     bool
+	}{
+		{"/users", "/users", true},
+		{"/users", "/users/1", false},
+		{"/users/:id", "/users/42", true},
+		{"/users/:id", "/users", false},
+		{"/users/:id", "/users/42/orders", false},
+		{"/users/{id}/orders", "/users/42/orders", true},
+		{"/users/{id}/orders", "/users/42/invoices", false},
+		{"/static/*", "/static/css/site.css", true},
+		{"/static/*", "/static", true},
+		{"/static/*", "/assets/site.css", false},
+		{"/", "/", true},
+		{"/", "/users", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template+" "+tt.path, func(t *testing.T) {
+			if got := matchTemplate(splitPath(tt.template), splitPath(stripQuery(tt.path))); got != tt.want {
+				t.Errorf("matchTemplate(%q, %q) = %v, want %v", tt.template, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateSpecificity(t *testing.T) {
+	tests := []struct {
+		more, less string
+	}{
+		{"/users/me", "/users/:id"},
+		{"/users/:id", "/users/*"},
+		{"/users/:id/orders", "/users/:id"},
+		{"/users/{id}", "/*"},
+	}
+
+	for _, tt := range tests {
+		if templateSpecificity(tt.more) <= templateSpecificity(tt.less) {
+			t.Errorf("templateSpecificity(%q) = %d, want more than %q (%d)",
+				tt.more, templateSpecificity(tt.more), tt.less, templateSpecificity(tt.less))
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"user-activity-tracker/configs"
+	"user-activity-tracker/internal/database"
+	"user-activity-tracker/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Query parameter modes
+const (
+	QueryModeDeny  = "deny"  // remove the listed parameters and the global deny list
+	QueryModeAllow = "allow" // keep only the listed parameters
+)
+
+const (
+	maxRedactionParams   = 100
+	maxRedactionPatterns = 20
+	maxPatternLength     = 200
+	redactionRulesTTL    = time.Minute
+
+	redactedValue = "[REDACTED]"
+	redactedEmail = "[EMAIL]"
+	redactedJWT   = "[JWT]"
+)
+
+var ErrInvalidRedactionRules = errors.New("invalid redaction rules")
+
+// Built-in detectors; emails may arrive with a percent-encoded @
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+(?:@|%40)[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+	jwtPattern   = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+)
+
+// Redactor removes secrets and personal data from endpoints
+type Redactor struct {
+	queryMode    string
+	queryParams  map[string]bool
+	patterns     []*regexp.Regexp
+	detectEmails bool
+	detectJWTs   bool
+}
+
+// RedactionService scrubs endpoints before hits are stored, broadcast or
+// cached, using the client's rules or the defaults
+type RedactionService struct {
+	db *gorm.DB
+
+	mu        sync.RWMutex
+	redactors map[string]cachedRedactor
+}
+
+type cachedRedactor struct {
+	redactor *Redactor
+	loadedAt time.Time
+}
+
+func NewRedactionService() *RedactionService {
+	return &RedactionService{
+		db:        database.GetDBManager().WriteDB,
+		redactors: make(map[string]cachedRedactor),
+	}
+}
+
+// Apply is the ingestion stage redacting the endpoint. It runs first so no
+// other stage sees the unredacted endpoint.
+func (s *RedactionService) Apply(hit *models.APILogs) bool {
+	hit.Endpoint = s.redactor(hit.ClientID).Redact(hit.Endpoint)
+	if len(hit.Endpoint) > 500 {
+		hit.Endpoint = hit.Endpoint[:500]
+	}
+	return true
+}
+
+// Rules returns the client's stored rules, or the defaults
+func (s *RedactionService) Rules(clientID string) (models.RedactionRules, error) {
+	rules := models.RedactionRules{
+		ClientID:     clientID,
+		QueryMode:    QueryModeDeny,
+		DetectEmails: true,
+		DetectJWTs:   true,
+	}
+	err := s.db.Where("client_id = ?", clientID).First(&rules).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return rules, nil
+	}
+	return rules, err
+}
+
+// SetRules validates and stores the client's rules, replacing earlier ones
+func (s *RedactionService) SetRules(rules models.RedactionRules) (*models.RedactionRules, error) {
+	if _, err := NewRedactor(rules); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&rules).Error
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	delete(s.redactors, rules.ClientID)
+	s.mu.Unlock()
+	return &rules, nil
+}
+
+// DeleteRules resets the client to the default rules
+func (s *RedactionService) DeleteRules(clientID string) error {
+	if err := s.db.Where("client_id = ?", clientID).Delete(&models.RedactionRules{}).Error; err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.redactors, clientID)
+	s.mu.Unlock()
+	return nil
+}
+
+// redactor returns the client's compiled rules. Rules are reloaded after a
+// minute so changes made through another instance take effect.
+func (s *RedactionService) redactor(clientID string) *Redactor {
+	s.mu.RLock()
+	cached, ok := s.redactors[clientID]
+	s.mu.RUnlock()
+	if ok && time.Since(cached.loadedAt) < redactionRulesTTL {
+		return cached.redactor
+	}
+
+	rules, err := s.Rules(clientID)
+	if err != nil {
+		// Keep redacting with the previous rules, or the defaults
+		if ok {
+			return cached.redactor
+		}
+		rules = models.RedactionRules{QueryMode: QueryModeDeny, DetectEmails: true, DetectJWTs: true}
+	}
+	redactor, err := NewRedactor(rules)
+	if err != nil {
+		redactor, _ = NewRedactor(models.RedactionRules{QueryMode: QueryModeDeny, DetectEmails: true, DetectJWTs: true})
+	}
+
+	s.mu.Lock()
+	s.redactors[clientID] = cachedRedactor{redactor: redactor, loadedAt: time.Now()}
+	s.mu.Unlock()
+	return redactor
+}
+
+// NewRedactor compiles a set of rules
+func NewRedactor(rules models.RedactionRules) (*Redactor, error) {
+	r := &Redactor{
+		queryMode:    rules.QueryMode,
+		queryParams:  make(map[string]bool),
+		detectEmails: rules.DetectEmails,
+		detectJWTs:   rules.DetectJWTs,
+	}
+	if r.queryMode == "" {
+		r.queryMode = QueryModeDeny
+	}
+	if r.queryMode != QueryModeDeny && r.queryMode != QueryModeAllow {
+		return nil, fmt.Errorf("%w: query_mode must be deny or allow", ErrInvalidRedactionRules)
+	}
+
+	params, err := decodeList(rules.QueryParams)
+	if err != nil {
+		return nil, fmt.Errorf("%w: query_params: %v", ErrInvalidRedactionRules, err)
+	}
+	if len(params) > maxRedactionParams {
+		return nil, fmt.Errorf("%w: at most %d query_params are allowed", ErrInvalidRedactionRules, maxRedactionParams)
+	}
+	for _, param := range params {
+		r.queryParams[strings.ToLower(param)] = true
+	}
+	if r.queryMode == QueryModeDeny {
+		for _, param := range configs.AppConfig.RedactQueryParams {
+			r.queryParams[strings.ToLower(param)] = true
+		}
+	}
+
+	patterns, err := decodeList(rules.Patterns)
+	if err != nil {
+		return nil, fmt.Errorf("%w: patterns: %v", ErrInvalidRedactionRules, err)
+	}
+	if len(patterns) > maxRedactionPatterns {
+		return nil, fmt.Errorf("%w: at most %d patterns are allowed", ErrInvalidRedactionRules, maxRedactionPatterns)
+	}
+	for _, pattern := range patterns {
+		if pattern == "" || len(pattern) > maxPatternLength {
+			return nil, fmt.Errorf("%w: patterns must be 1 to %d characters", ErrInvalidRedactionRules, maxPatternLength)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: pattern %q: %v", ErrInvalidRedactionRules, pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Redact filters the query parameters of an endpoint, then masks pattern
+// matches, emails and JWTs anywhere in it. Fragments are dropped.
+func (r *Redactor) Redact(endpoint string) string {
+	endpoint, _, _ = strings.Cut(endpoint, "#")
+
+	path, query, hasQuery := strings.Cut(endpoint, "?")
+	if hasQuery {
+		var kept []string
+		for _, pair := range strings.Split(query, "&") {
+			if pair == "" {
+				continue
+			}
+			name, _, _ := strings.Cut(pair, "=")
+			if decoded, err := url.QueryUnescape(name); err == nil {
+				name = decoded
+			}
+
+			listed := r.queryParams[strings.ToLower(name)]
+			if listed == (r.queryMode == QueryModeDeny) {
+				continue
+			}
+			kept = append(kept, pair)
+		}
+
+		endpoint = path
+		if len(kept) > 0 {
+			endpoint += "?" + strings.Join(kept, "&")
+		}
+	}
+
+	for _, re := range r.patterns {
+		endpoint = re.ReplaceAllLiteralString(endpoint, redactedValue)
+	}
+	if r.detectJWTs {
+		endpoint = jwtPattern.ReplaceAllLiteralString(endpoint, redactedJWT)
+	}
+	if r.detectEmails {
+		endpoint = emailPattern.ReplaceAllLiteralString(endpoint, redactedEmail)
+	}
+	return endpoint
+}
+
+// decodeList reads a JSON array of strings stored in a text column
+func decodeList(value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var items []string
+	if err := json.Unmarshal([]byte(value), &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
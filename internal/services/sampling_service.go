@@ -0,0 +1,155 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"user-activity-tracker/internal/cache"
+	"user-activity-tracker/internal/database"
+	"user-activity-tracker/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	minSampleRate          = 0.0001
+	samplingPolicyCacheTTL = time.Minute
+)
+
+var (
+	ErrInvalidSampleRate      = fmt.Errorf("rate must be between %g and 1", minSampleRate)
+	ErrSamplingPolicyNotFound = errors.New("sampling policy not found")
+)
+
+// SamplingService keeps only a fraction of a client's hits in api_logs. Stored
+// rows carry the weight of the hits they stand for, so aggregates stay accurate.
+type SamplingService struct {
+	db    *gorm.DB
+	cache *cache.CacheManager
+}
+
+func NewSamplingService() *SamplingService {
+	return &SamplingService{
+		db:    database.GetDBManager().WriteDB,
+		cache: cache.GetCacheManager(),
+	}
+}
+
+// Apply is the ingestion stage deciding whether a hit is stored. It must run
+// after endpoint normalization so per-template policies can match.
+func (s *SamplingService) Apply(hit *models.APILogs) bool {
+	rate := s.Rate(hit.ClientID, hit.EndpointTemplate)
+	if rate >= 1 {
+		hit.SampleWeight = 1
+		return true
+	}
+
+	// Hashing the event ID keeps the decision stable when a hit is retried
+	if sampleFraction(hit.EventID) >= rate {
+		return false
+	}
+	hit.SampleWeight = 1 / rate
+	return true
+}
+
+// Rate returns the sample rate for a client's endpoint template: the template's
+// own policy, else the client default, else 1
+func (s *SamplingService) Rate(clientID, template string) float64 {
+	rates := s.clientRates(clientID)
+	if rate, ok := rates[template]; ok {
+		return rate
+	}
+	if rate, ok := rates[""]; ok {
+		return rate
+	}
+	return 1
+}
+
+// Policies lists the client's sampling policies
+func (s *SamplingService) Policies(clientID string) ([]models.SamplingPolicy, error) {
+	var policies []models.SamplingPolicy
+	err := s.db.Where("client_id = ?", clientID).Order("endpoint_template").Find(&policies).Error
+	return policies, err
+}
+
+// SetPolicy creates or updates the rate for an endpoint template, or the client
+// default when template is empty
+func (s *SamplingService) SetPolicy(clientID, template string, rate float64) (*models.SamplingPolicy, error) {
+	if rate < minSampleRate || rate > 1 {
+		return nil, ErrInvalidSampleRate
+	}
+	if template != "" {
+		var err error
+		if template, err = ValidateRouteTemplate(template); err != nil {
+			return nil, err
+		}
+	}
+
+	policy := models.SamplingPolicy{ClientID: clientID, EndpointTemplate: template, Rate: rate}
+	err := s.db.Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "updated_at"}),
+	}).Create(&policy).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Reload to return the stored ID when the policy already existed
+	if err := s.db.Where("client_id = ? AND endpoint_template = ?", clientID, template).First(&policy).Error; err != nil {
+		return nil, err
+	}
+
+	s.cache.Delete(samplingCacheKey(clientID))
+	return &policy, nil
+}
+
+// DeletePolicy removes one of the client's sampling policies
+func (s *SamplingService) DeletePolicy(clientID string, id uint) error {
+	result := s.db.Where("client_id = ? AND id = ?", clientID, id).Delete(&models.SamplingPolicy{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSamplingPolicyNotFound
+	}
+
+	s.cache.Delete(samplingCacheKey(clientID))
+	return nil
+}
+
+// clientRates returns the client's rates keyed by endpoint template
+func (s *SamplingService) clientRates(clientID string) map[string]float64 {
+	cacheKey := samplingCacheKey(clientID)
+
+	var rates map[string]float64
+	if found, err := s.cache.Get(cacheKey, &rates); found && err == nil {
+		return rates
+	}
+
+	policies, err := s.Policies(clientID)
+	if err != nil {
+		// Store everything rather than drop hits when policies cannot be loaded
+		return nil
+	}
+
+	rates = make(map[string]float64, len(policies))
+	for _, policy := range policies {
+		rates[policy.EndpointTemplate] = policy.Rate
+	}
+
+	s.cache.Set(cacheKey, rates, samplingPolicyCacheTTL)
+	return rates
+}
+
+// sampleFraction maps an event ID onto [0, 1)
+func sampleFraction(eventID string) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(eventID))
+	return float64(h.Sum64()>>11) / (1 << 53)
+}
+
+func samplingCacheKey(clientID string) string {
+	return fmt.Sprintf("sampling:%s", clientID)
+}
@@ -0,0 +1,398 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"user-activity-tracker/configs"
+	"user-activity-tracker/internal/database"
+	"user-activity-tracker/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// sessionSettleDelay keeps the sessionizer behind hits whose insert may not be
+// committed yet, so no lower log ID becomes visible after a higher one was processed
+const sessionSettleDelay = 30 * time.Second
+
+// SessionService groups stored hits into sessions per visitor: the end user when
+// the hit has a user_id, otherwise the IP address. A hit more than the session
+// gap away from every session of its visitor starts a new session; a hit
+// within the gap of several sessions joins them into one. Hit counts are sums
+// of sample weights.
+//
+// Hits are read from api_logs in ID order behind a persisted position, so
+// backfilled and imported hits are sessionized too. Concurrent instances
+// serialize on the position row.
+type SessionService struct {
+	db *gorm.DB
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewSessionService() *SessionService {
+	return &SessionService{
+		db:   database.GetDBManager().WriteDB,
+		stop: make(chan struct{}),
+	}
+}
+
+// sessionHit is the part of a hit the sessionizer reads
+type sessionHit struct {
+	ID               uint64
+	ClientID         string
+	UserID           string
+	IPAddress        string
+	IPAnonymized     bool
+	Timestamp        time.Time
+	Endpoint         string
+	EndpointTemplate string
+	SampleWeight     float64
+	CreatedAt        time.Time
+}
+
+// Start sessionizes new hits every SESSION_INTERVAL
+func (s *SessionService) Start() {
+	cfg := configs.AppConfig
+	if !cfg.EnableSessions || cfg.SessionInterval <= 0 {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(cfg.SessionInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				s.catchUp()
+			}
+		}
+	}()
+}
+
+// Stop waits for the running batch and stops sessionizing
+func (s *SessionService) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// catchUp processes batches until no settled hits are left
+func (s *SessionService) catchUp() {
+	for {
+		n, err := s.ProcessBatch()
+		if err != nil {
+			log.Printf("Failed to sessionize hits: %v", err)
+			return
+		}
+		if n == 0 || n < configs.AppConfig.SessionBatchSize {
+			return
+		}
+
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+	}
+}
+
+// ProcessBatch assigns the next batch of hits to sessions and returns how many
+// hits it processed
+func (s *SessionService) ProcessBatch() (int, error) {
+	gap := configs.AppConfig.SessionGap
+	batchSize := configs.AppConfig.SessionBatchSize
+	if batchSize <= 0 {
+		batchSize = 5000
+	}
+
+	processed := 0
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var progress models.SessionProgress
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", 1).First(&progress).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Start with the hits recorded from now on
+			progress.ID = 1
+			if err := tx.Table("api_logs").Select("COALESCE(MAX(id), 0)").Scan(&progress.LastLogID).Error; err != nil {
+				return err
+			}
+			return tx.Create(&progress).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		var hits []sessionHit
+		err = tx.Table("api_logs").
+			Select("id, client_id, COALESCE(user_id, '') AS user_id, ip_address, ip_anonymized, timestamp, endpoint, "+
+				"COALESCE(endpoint_template, '') AS endpoint_template, sample_weight, created_at").
+			Where("id > ?", progress.LastLogID).
+			Order("id").
+			Limit(batchSize).
+			Scan(&hits).Error
+		if err != nil {
+			return err
+		}
+
+		settled := time.Now().Add(-sessionSettleDelay)
+		for i, hit := range hits {
+			if hit.CreatedAt.After(settled) {
+				hits = hits[:i]
+				break
+			}
+		}
+		if len(hits) == 0 {
+			return nil
+		}
+
+		sessions, merged, err := s.assign(tx, hits, gap)
+		if err != nil {
+			return err
+		}
+
+		if len(merged) > 0 {
+			ids := make([]uint64, len(merged))
+			for i, session := range merged {
+				ids[i] = session.ID
+			}
+			if err := tx.Delete(&models.Session{}, ids).Error; err != nil {
+				return err
+			}
+		}
+
+		var created []*models.Session
+		for _, session := range sessions {
+			if session.ID == 0 {
+				created = append(created, session)
+				continue
+			}
+			if err := tx.Save(session).Error; err != nil {
+				return err
+			}
+		}
+		if len(created) > 0 {
+			if err := tx.CreateInBatches(created, 500).Error; err != nil {
+				return err
+			}
+		}
+
+		progress.LastLogID = hits[len(hits)-1].ID
+		if err := tx.Save(&progress).Error; err != nil {
+			return err
+		}
+		processed = len(hits)
+		return nil
+	})
+	return processed, err
+}
+
+// assign merges hits into their visitors' sessions, loading the sessions they
+// may extend. It returns the sessions that were created or changed, and the
+// stored sessions that were merged into another one and must be deleted.
+func (s *SessionService) assign(tx *gorm.DB, hits []sessionHit, gap time.Duration) ([]*models.Session, []*models.Session, error) {
+	clientIDs := make(map[string]bool)
+	keys := make(map[string]bool)
+	var first, last time.Time
+	for _, hit := range hits {
+		key := visitorKey(hit.UserID, hit.IPAddress)
+		if key == "" {
+			continue
+		}
+		clientIDs[hit.ClientID] = true
+		keys[key] = true
+		if first.IsZero() || hit.Timestamp.Before(first) {
+			first = hit.Timestamp
+		}
+		if last.IsZero() || hit.Timestamp.After(last) {
+			last = hit.Timestamp
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, nil, nil
+	}
+
+	var existing []*models.Session
+	err := tx.Where("client_id IN ? AND visitor_key IN ? AND end_time >= ? AND start_time <= ?",
+		mapKeys(clientIDs), mapKeys(keys), first.Add(-gap), last.Add(gap)).
+		Find(&existing).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changed, merged := sessionize(existing, hits, gap)
+	return changed, merged, nil
+}
+
+// sessionize adds hits to the sessions of their visitors, creating sessions
+// as needed. A hit within the gap of several sessions merges them into the
+// earliest stored one. It returns the created or changed sessions and the
+// stored sessions merged away.
+func sessionize(existing []*models.Session, hits []sessionHit, gap time.Duration) ([]*models.Session, []*models.Session) {
+	type visitor struct{ clientID, key string }
+
+	byVisitor := make(map[visitor][]sessionHit)
+	var order []visitor
+	for _, hit := range hits {
+		v := visitor{hit.ClientID, visitorKey(hit.UserID, hit.IPAddress)}
+		if v.key == "" {
+			// Anonymous hit of a client that does not store IP addresses
+			continue
+		}
+		if _, ok := byVisitor[v]; !ok {
+			order = append(order, v)
+		}
+		byVisitor[v] = append(byVisitor[v], hit)
+	}
+
+	open := make(map[visitor][]*models.Session)
+	for _, session := range existing {
+		v := visitor{session.ClientID, session.VisitorKey}
+		open[v] = append(open[v], session)
+	}
+
+	var changed, merged []*models.Session
+	isChanged := make(map[*models.Session]bool)
+	gone := make(map[*models.Session]bool)
+	for _, v := range order {
+		visitorHits := byVisitor[v]
+		sort.SliceStable(visitorHits, func(i, j int) bool {
+			return visitorHits[i].Timestamp.Before(visitorHits[j].Timestamp)
+		})
+
+		for _, hit := range visitorHits {
+			matches := findSessions(open[v], hit.Timestamp, gap)
+
+			var session *models.Session
+			if len(matches) == 0 {
+				session = &models.Session{
+					ClientID:     hit.ClientID,
+					VisitorKey:   v.key,
+					UserID:       hit.UserID,
+					IPAddress:    hit.IPAddress,
+					IPAnonymized: hit.IPAnonymized,
+					StartTime:    hit.Timestamp,
+					EndTime:      hit.Timestamp,
+				}
+				open[v] = append(open[v], session)
+			} else {
+				session = matches[0]
+				for _, other := range matches[1:] {
+					mergeSession(session, other)
+					gone[other] = true
+					if other.ID != 0 {
+						merged = append(merged, other)
+					}
+				}
+				open[v] = withoutSessions(open[v], gone)
+			}
+			addSessionHit(session, hit)
+
+			if !isChanged[session] {
+				isChanged[session] = true
+				changed = append(changed, session)
+			}
+		}
+	}
+
+	result := changed[:0]
+	for _, session := range changed {
+		if !gone[session] {
+			result = append(result, session)
+		}
+	}
+	return result, merged
+}
+
+// findSessions returns the sessions a hit at ts belongs to, stored ones first
+// and then by start time
+func findSessions(sessions []*models.Session, ts time.Time, gap time.Duration) []*models.Session {
+	var matches []*models.Session
+	for _, session := range sessions {
+		if !ts.Before(session.StartTime.Add(-gap)) && !ts.After(session.EndTime.Add(gap)) {
+			matches = append(matches, session)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if stored := matches[i].ID != 0; stored != (matches[j].ID != 0) {
+			return stored
+		}
+		return matches[i].StartTime.Before(matches[j].StartTime)
+	})
+	return matches
+}
+
+// mergeSession folds other into session
+func mergeSession(session, other *models.Session) {
+	if other.StartTime.Before(session.StartTime) {
+		session.StartTime = other.StartTime
+		session.EntryEndpoint = other.EntryEndpoint
+	}
+	if !other.EndTime.Before(session.EndTime) {
+		session.EndTime = other.EndTime
+		session.ExitEndpoint = other.ExitEndpoint
+	}
+	session.HitCount += other.HitCount
+}
+
+func withoutSessions(sessions []*models.Session, gone map[*models.Session]bool) []*models.Session {
+	kept := sessions[:0]
+	for _, session := range sessions {
+		if !gone[session] {
+			kept = append(kept, session)
+		}
+	}
+	return kept
+}
+
+// addSessionHit extends a session by a hit, counting its sample weight; the
+// earliest hit is the entry and the latest the exit
+func addSessionHit(session *models.Session, hit sessionHit) {
+	endpoint := hit.EndpointTemplate
+	if endpoint == "" {
+		endpoint = hit.Endpoint
+	}
+
+	if session.HitCount == 0 || hit.Timestamp.Before(session.StartTime) {
+		session.StartTime = hit.Timestamp
+		session.EntryEndpoint = endpoint
+	}
+	if session.HitCount == 0 || !hit.Timestamp.Before(session.EndTime) {
+		session.EndTime = hit.Timestamp
+		session.ExitEndpoint = endpoint
+	}
+	weight := hit.SampleWeight
+	if weight <= 0 {
+		weight = 1
+	}
+	session.HitCount += weight
+}
+
+// visitorKey identifies whom a hit is attributed to within a client; hits
+// with neither a user ID nor an IP address have no visitor
+func visitorKey(userID, ipAddress string) string {
+	if userID != "" {
+		return "u:" + userID
+	}
+	if ipAddress == "" {
+		return ""
+	}
+	return "ip:" + ipAddress
+}
+
+func mapKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
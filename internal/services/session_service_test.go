@@ -0,0 +1,104 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"user-activity-tracker/internal/models"
+)
+
+func TestSessionize(t *testing.T) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return base.Add(time.Duration(minutes) * time.Minute) }
+	hit := func(minutes int, endpoint string, weight float64) sessionHit {
+		return sessionHit{ClientID: "c", UserID: "u1", Timestamp: at(minutes), Endpoint: endpoint, SampleWeight: weight}
+	}
+	stored := func(id uint64, from, to int, entry, exit string, hits float64) *models.Session {
+		return &models.Session{ID: id, ClientID: "c", VisitorKey: "u:u1", UserID: "u1",
+			StartTime: at(from), EndTime: at(to), EntryEndpoint: entry, ExitEndpoint: exit, HitCount: hits}
+	}
+
+	type want struct {
+		id          uint64
+		from, to    int
+		entry, exit string
+		hits        float64
+	}
+
+	tests := []struct {
+		name     string
+		existing []*models.Session
+		hits     []sessionHit
+		changed  []want
+		merged   []uint64
+	}{
+		{
+			name: "new sessions split by the gap",
+			hits: []sessionHit{hit(0, "/a", 1), hit(10, "/b", 1), hit(60, "/c", 1)},
+			changed: []want{
+				{from: 0, to: 10, entry: "/a", exit: "/b", hits: 2},
+				{from: 60, to: 60, entry: "/c", exit: "/c", hits: 1},
+			},
+		},
+		{
+			name:     "hit extends a stored session",
+			existing: []*models.Session{stored(7, 0, 10, "/a", "/b", 2)},
+			hits:     []sessionHit{hit(25, "/c", 1)},
+			changed:  []want{{id: 7, from: 0, to: 25, entry: "/a", exit: "/c", hits: 3}},
+		},
+		{
+			name: "late hit bridges two stored sessions",
+			existing: []*models.Session{
+				stored(8, 50, 60, "/c", "/d", 2),
+				stored(7, 0, 10, "/a", "/b", 2),
+			},
+			hits:    []sessionHit{hit(30, "/x", 1)},
+			changed: []want{{id: 7, from: 0, to: 60, entry: "/a", exit: "/d", hits: 5}},
+			merged:  []uint64{8},
+		},
+		{
+			name:     "hits in one batch bridge a stored and a new session",
+			existing: []*models.Session{stored(7, 0, 10, "/a", "/b", 1)},
+			hits:     []sessionHit{hit(70, "/z", 1), hit(40, "/m", 1)},
+			changed:  []want{{id: 7, from: 0, to: 70, entry: "/a", exit: "/z", hits: 3}},
+		},
+		{
+			name: "hit count sums sample weights",
+			hits: []sessionHit{hit(0, "/a", 4), hit(5, "/b", 2.5), hit(6, "/c", 0)},
+			changed: []want{
+				{from: 0, to: 6, entry: "/a", exit: "/c", hits: 7.5},
+			},
+		},
+		{
+			name: "anonymous hits are skipped",
+			hits: []sessionHit{{ClientID: "c", Timestamp: at(0), Endpoint: "/a"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed, merged := sessionize(tt.existing, tt.hits, 30*time.Minute)
+
+			if len(changed) != len(tt.changed) {
+				t.Fatalf("changed %d sessions, want %d: %+v", len(changed), len(tt.changed), changed)
+			}
+			for i, w := range tt.changed {
+				got := changed[i]
+				if got.ID != w.id || !got.StartTime.Equal(at(w.from)) || !got.EndTime.Equal(at(w.to)) ||
+					got.EntryEndpoint != w.entry || got.ExitEndpoint != w.exit || got.HitCount != w.hits {
+					t.Errorf("session %d = {id %d, %s-%s, %s -> %s, %g hits}, want %+v", i, got.ID,
+						got.StartTime.Format("15:04"), got.EndTime.Format("15:04"), got.EntryEndpoint, got.ExitEndpoint, got.HitCount, w)
+				}
+			}
+
+			if len(merged) != len(tt.merged) {
+				t.Fatalf("merged %d sessions, want %v", len(merged), tt.merged)
+			}
+			for i, id := range tt.merged {
+				if merged[i].ID != id {
+					t.Errorf("merged session %d, want %d", merged[i].ID, id)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,495 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"user-activity-tracker/configs"
+	"user-activity-tracker/internal/cache"
+	"user-activity-tracker/internal/database"
+	"user-activity-tracker/internal/models"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	topKRetention     = 24 * time.Hour  // longest real-time window
+	topKFlushInterval = time.Second     // how long counts are buffered before reaching Redis
+	topKSettle        = 2 * time.Minute // hits older than this are in MySQL
+	topKUnionTTL      = 5 * time.Second // how long a merged window is reused
+	topKMinDrift      = 10              // differences up to this many requests are never drift
+)
+
+// topKEntry is a sorted set member and its score
+type topKEntry struct {
+	member string
+	count  float64
+}
+
+// topKMinute holds one minute of requests per client and per client endpoint
+type topKMinute struct {
+	clients   map[string]float64
+	endpoints map[string]map[string]float64
+}
+
+func newTopKMinute() *topKMinute {
+	return &topKMinute{
+		clients:   make(map[string]float64),
+		endpoints: make(map[string]map[string]float64),
+	}
+}
+
+func (m *topKMinute) add(clientID, endpoint string, n float64) {
+	m.clients[clientID] += n
+	if m.endpoints[clientID] == nil {
+		m.endpoints[clientID] = make(map[string]float64)
+	}
+	m.endpoints[clientID][endpoint] += n
+}
+
+// topKCounter counts requests per minute for the real-time leaderboards. With
+// Redis the counts are buffered for a second, then added to per-minute sorted
+// sets shared by every instance; windows are merged with ZUNIONSTORE. Without
+// Redis each instance counts its own hits in memory and reconciliation fills
+// in the rest from MySQL.
+type topKCounter struct {
+	cache *cache.CacheManager
+
+	mu      sync.Mutex
+	pending map[int64]*topKMinute // counts not yet flushed to Redis
+	local   map[int64]*topKMinute // counts kept in memory without Redis
+
+	// flushMu keeps a flush from adding counts to minutes being rebuilt
+	flushMu sync.Mutex
+}
+
+func newTopKCounter() *topKCounter {
+	return &topKCounter{
+		cache:   cache.GetCacheManager(),
+		pending: make(map[int64]*topKMinute),
+		local:   make(map[int64]*topKMinute),
+	}
+}
+
+// covers reports whether the counters can serve a window: whole minutes up to
+// a day
+func (c *topKCounter) covers(window Window) bool {
+	return configs.AppConfig.RealtimeLeaderboards &&
+		window.Duration%time.Minute == 0 && window.Duration <= topKRetention
+}
+
+// record counts stored hits into the minute of their timestamp by their sample
+// weight, as MySQL counts them, so reconciliation finds no drift under
+// sampling. Hits sampled out must not be recorded.
+func (c *topKCounter) record(hits []models.APILogs) {
+	if !configs.AppConfig.RealtimeLeaderboards {
+		return
+	}
+	oldest := time.Now().Add(-topKRetention)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	minutes := c.local
+	if c.cache.IsAvailable() {
+		minutes = c.pending
+	}
+	for _, hit := range hits {
+		if hit.Timestamp.Before(oldest) {
+			continue
+		}
+		minute := hit.Timestamp.Unix() / 60
+		if minutes[minute] == nil {
+			minutes[minute] = newTopKMinute()
+		}
+		endpoint := hit.EndpointTemplate
+		if endpoint == "" {
+			endpoint = hit.Endpoint
+		}
+		weight := hit.SampleWeight
+		if weight <= 0 {
+			weight = 1
+		}
+		minutes[minute].add(hit.ClientID, endpoint, weight)
+	}
+}
+
+// topClients returns the clients with the most requests over the window
+func (c *topKCounter) topClients(window Window, limit int) ([]topKEntry, error) {
+	from, to := windowMinutes(window.Duration)
+	return c.top(from, to, topKClientsKey, func(m *topKMinute) map[string]float64 {
+		return m.clients
+	}, limit)
+}
+
+// topEndpoints returns the client's endpoints with the most requests over the window
+func (c *topKCounter) topEndpoints(clientID string, window Window, limit int) ([]topKEntry, error) {
+	from, to := windowMinutes(window.Duration)
+	return c.top(from, to, func(minute int64) string {
+		return topKEndpointsKey(clientID, minute)
+	}, func(m *topKMinute) map[string]float64 {
+		return m.endpoints[clientID]
+	}, limit)
+}
+
+// top merges the counts of the minutes [from, to) and returns the highest,
+// all of them when limit is 0. key names a minute's sorted set in Redis and
+// counts picks its counts in memory.
+func (c *topKCounter) top(from, to int64, key func(int64) string, counts func(*topKMinute) map[string]float64, limit int) ([]topKEntry, error) {
+	if !c.cache.IsAvailable() {
+		merged := make(map[string]float64)
+		c.mu.Lock()
+		for minute := from; minute < to; minute++ {
+			if m := c.local[minute]; m != nil {
+				for member, n := range counts(m) {
+					merged[member] += n
+				}
+			}
+		}
+		c.mu.Unlock()
+
+		entries := make([]topKEntry, 0, len(merged))
+		for member, n := range merged {
+			entries = append(entries, topKEntry{member: member, count: n})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].count != entries[j].count {
+				return entries[i].count > entries[j].count
+			}
+			return entries[i].member < entries[j].member
+		})
+		if limit > 0 && len(entries) > limit {
+			entries = entries[:limit]
+		}
+		return entries, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	rdb := c.cache.Client()
+
+	// Requests for the same window within a few seconds share one merge
+	dest := fmt.Sprintf("usage:topk:union:%s:%d", key(from), to)
+	exists, err := rdb.Exists(ctx, dest).Result()
+	if err != nil {
+		return nil, err
+	}
+	if exists == 0 {
+		keys := make([]string, 0, to-from)
+		for minute := from; minute < to; minute++ {
+			keys = append(keys, key(minute))
+		}
+		pipe := rdb.TxPipeline()
+		pipe.ZUnionStore(ctx, dest, &redis.ZStore{Keys: keys})
+		pipe.Expire(ctx, dest, topKUnionTTL)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	scores, err := rdb.ZRevRangeWithScores(ctx, dest, 0, int64(limit)-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]topKEntry, 0, len(scores))
+	for _, z := range scores {
+		if member, ok := z.Member.(string); ok {
+			entries = append(entries, topKEntry{member: member, count: z.Score})
+		}
+	}
+	return entries, nil
+}
+
+// start flushes buffered counts every second and reconciles the counters with
+// MySQL every LEADERBOARD_RECONCILE_INTERVAL, after filling them in on startup
+func (c *topKCounter) start(wg *sync.WaitGroup, stop <-chan struct{}) {
+	if !configs.AppConfig.RealtimeLeaderboards {
+		return
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(topKFlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				c.flush()
+				return
+			case <-ticker.C:
+				c.flush()
+				c.prune()
+			}
+		}
+	}()
+
+	interval := configs.AppConfig.LeaderboardReconcileEvery
+	if interval <= 0 {
+		return
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.reconcileSettled(topKRetention, interval, stop)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				c.reconcileSettled(time.Hour, interval, stop)
+			}
+		}
+	}()
+}
+
+// flush adds the buffered counts to the Redis sorted sets. Counts lost to a
+// Redis error are restored by reconciliation.
+func (c *topKCounter) flush() {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = make(map[int64]*topKMinute)
+	c.mu.Unlock()
+
+	if len(pending) == 0 || !c.cache.IsAvailable() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pipe := c.cache.Client().Pipeline()
+	for minute, m := range pending {
+		writeTopKMinute(ctx, pipe, minute, m)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to flush real-time leaderboard counts: %v", err)
+	}
+}
+
+// prune drops in-memory minutes that no window reaches anymore
+func (c *topKCounter) prune() {
+	oldest := time.Now().Add(-topKRetention).Unix()/60 - 1
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for minute := range c.local {
+		if minute < oldest {
+			delete(c.local, minute)
+		}
+	}
+}
+
+// reconcileSettled checks the settled minutes of the last span hour by hour,
+// newest first. One instance sharing Redis checks per interval.
+func (c *topKCounter) reconcileSettled(span, interval time.Duration, stop <-chan struct{}) {
+	locked, err := c.cache.SetNX("usage:topk:reconcile", true, interval-time.Second)
+	if err != nil || !locked {
+		return
+	}
+
+	to := time.Now().Add(-topKSettle).Truncate(time.Minute)
+	for end := to; end.After(to.Add(-span)); end = end.Add(-time.Hour) {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if err := c.reconcile(end.Add(-time.Hour), end); err != nil {
+			log.Printf("Failed to reconcile real-time leaderboards: %v", err)
+			return
+		}
+	}
+}
+
+// reconcile compares the requests counted per client between from and to with
+// the hits stored in MySQL, and rebuilds those minutes from MySQL when a client
+// is off by more than LEADERBOARD_DRIFT_PERCENT. Sampled clients are compared
+// by their weighted hits.
+func (c *topKCounter) reconcile(from, to time.Time) error {
+	counted, err := c.top(from.Unix()/60, to.Unix()/60, topKClientsKey, func(m *topKMinute) map[string]float64 {
+		return m.clients
+	}, 0)
+	if err != nil {
+		return err
+	}
+
+	var stored []struct {
+		ClientID     string
+		RequestCount int64
+	}
+	err = database.GetDBManager().GetReadDB().Table("api_logs").
+		Select("client_id, CAST(ROUND(SUM(sample_weight)) AS SIGNED) AS request_count").
+		Where("timestamp >= ? AND timestamp < ?", from, to).
+		Group("client_id").
+		Scan(&stored).Error
+	if err != nil {
+		return err
+	}
+
+	diffs := make(map[string]float64, len(stored))
+	expected := make(map[string]float64, len(stored))
+	for _, entry := range counted {
+		diffs[entry.member] += entry.count
+	}
+	for _, row := range stored {
+		diffs[row.ClientID] -= float64(row.RequestCount)
+		expected[row.ClientID] = float64(row.RequestCount)
+	}
+
+	drifted := 0
+	tolerance := float64(configs.AppConfig.LeaderboardDriftPercent) / 100
+	for clientID, diff := range diffs {
+		if math.Abs(diff) > math.Max(topKMinDrift, tolerance*expected[clientID]) {
+			drifted++
+		}
+	}
+	if drifted == 0 {
+		return nil
+	}
+
+	log.Printf("Real-time leaderboards are off for %d clients between %s and %s, rebuilding from MySQL",
+		drifted, from.Format(time.RFC3339), to.Format(time.RFC3339))
+	return c.rebuild(from, to)
+}
+
+// rebuild replaces the counts of the minutes between from and to with the
+// weighted hits stored in MySQL
+func (c *topKCounter) rebuild(from, to time.Time) error {
+	var rows []struct {
+		Minute       string
+		ClientID     string
+		Endpoint     string
+		RequestCount float64
+	}
+	err := database.GetDBManager().GetReadDB().Table("api_logs").
+		Select("DATE_FORMAT(timestamp, '%Y-%m-%d %H:%i') AS minute, client_id, "+
+			EndpointTemplateColumn+" AS endpoint, SUM(sample_weight) AS request_count").
+		Where("timestamp >= ? AND timestamp < ?", from, to).
+		Group("1, 2, 3").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	minutes := make(map[int64]*topKMinute)
+	for _, row := range rows {
+		t, err := time.ParseInLocation("2006-01-02 15:04", row.Minute, time.Local)
+		if err != nil {
+			continue
+		}
+		minute := t.Unix() / 60
+		if minutes[minute] == nil {
+			minutes[minute] = newTopKMinute()
+		}
+		minutes[minute].add(row.ClientID, row.Endpoint, row.RequestCount)
+	}
+
+	first, last := from.Unix()/60, to.Unix()/60
+	if !c.cache.IsAvailable() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for minute := first; minute < last; minute++ {
+			if m, ok := minutes[minute]; ok {
+				c.local[minute] = m
+			} else {
+				delete(c.local, minute)
+			}
+		}
+		return nil
+	}
+
+	// Buffered counts of the rebuilt minutes are for hits MySQL already holds
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+	c.discardPending(first, last)
+
+	rdb := c.cache.Client()
+	for minute := first; minute < last; minute++ {
+		if err := c.replaceMinute(rdb, minute, minutes[minute]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// discardPending drops the buffered counts of the minutes [first, last)
+func (c *topKCounter) discardPending(first, last int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for minute := range c.pending {
+		if minute >= first && minute < last {
+			delete(c.pending, minute)
+		}
+	}
+}
+
+// replaceMinute swaps a minute's sorted sets for m, or deletes them when m is nil
+func (c *topKCounter) replaceMinute(rdb *redis.Client, minute int64, m *topKMinute) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	key := topKClientsKey(minute)
+	clients, err := rdb.ZRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+
+	pipe := rdb.TxPipeline()
+	pipe.Del(ctx, key)
+	for _, clientID := range clients {
+		pipe.Del(ctx, topKEndpointsKey(clientID, minute))
+	}
+	if m != nil {
+		writeTopKMinute(ctx, pipe, minute, m)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// writeTopKMinute adds a minute's counts to its sorted sets, which expire once
+// no window reaches them
+func writeTopKMinute(ctx context.Context, pipe redis.Pipeliner, minute int64, m *topKMinute) {
+	expireAt := time.Unix((minute+1)*60, 0).Add(topKRetention)
+
+	key := topKClientsKey(minute)
+	for clientID, n := range m.clients {
+		pipe.ZIncrBy(ctx, key, n, clientID)
+	}
+	pipe.ExpireAt(ctx, key, expireAt)
+
+	for clientID, endpoints := range m.endpoints {
+		key := topKEndpointsKey(clientID, minute)
+		for endpoint, n := range endpoints {
+			pipe.ZIncrBy(ctx, key, n, endpoint)
+		}
+		pipe.ExpireAt(ctx, key, expireAt)
+	}
+}
+
+// windowMinutes returns the minutes [from, to) of a window ending with the
+// current minute
+func windowMinutes(window time.Duration) (int64, int64) {
+	to := time.Now().Unix()/60 + 1
+	return to - int64(window/time.Minute), to
+}
+
+func topKClientsKey(minute int64) string {
+	return fmt.Sprintf("usage:topk:clients:%d", minute)
+}
+
+func topKEndpointsKey(clientID string, minute int64) string {
+	return fmt.Sprintf("usage:topk:endpoints:%s:%d", clientID, minute)
+}
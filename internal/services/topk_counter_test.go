@@ -0,0 +1,60 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"user-activity-tracker/configs"
+	"user-activity-tracker/internal/models"
+)
+
+func TestTopKRecordWeights(t *testing.T) {
+	saved := configs.AppConfig.RealtimeLeaderboards
+	configs.AppConfig.RealtimeLeaderboards = true
+	t.Cleanup(func() { configs.AppConfig.RealtimeLeaderboards = saved })
+
+	c := &topKCounter{cache: testCache(), pending: make(map[int64]*topKMinute), local: make(map[int64]*topKMinute)}
+	now := time.Now()
+	c.record([]models.APILogs{
+		{ClientID: "a", Endpoint: "/users/1", EndpointTemplate: "/users/:id", Timestamp: now, SampleWeight: 10},
+		{ClientID: "a", Endpoint: "/users/2", EndpointTemplate: "/users/:id", Timestamp: now, SampleWeight: 10},
+		{ClientID: "a", Endpoint: "/health", Timestamp: now, SampleWeight: 1},
+		// Hits stored without a sampling stage count once
+		{ClientID: "b", Endpoint: "/", Timestamp: now},
+		// Beyond the longest window
+		{ClientID: "b", Endpoint: "/", Timestamp: now.Add(-topKRetention - time.Minute), SampleWeight: 1},
+	})
+
+	clients, err := c.topClients(Window{Duration: time.Hour}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clients) != 2 || clients[0] != (topKEntry{"a", 21}) || clients[1] != (topKEntry{"b", 1}) {
+		t.Errorf("clients = %+v, want a with 21 and b with 1", clients)
+	}
+
+	endpoints, err := c.topEndpoints("a", Window{Duration: time.Hour}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoints) != 1 || endpoints[0] != (topKEntry{"/users/:id", 20}) {
+		t.Errorf("endpoints = %+v, want /users/:id with 20", endpoints)
+	}
+}
+
+func TestTopKDiscardPending(t *testing.T) {
+	c := &topKCounter{pending: make(map[int64]*topKMinute)}
+	for minute := int64(100); minute < 110; minute++ {
+		c.pending[minute] = newTopKMinute()
+		c.pending[minute].add("a", "/", 1)
+	}
+
+	c.discardPending(102, 105)
+
+	for minute := int64(100); minute < 110; minute++ {
+		_, kept := c.pending[minute]
+		if want := minute < 102 || minute >= 105; kept != want {
+			t.Errorf("minute %d kept = %v, want %v", minute, kept, want)
+		}
+	}
+}
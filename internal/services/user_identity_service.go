@@ -0,0 +1,110 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"user-activity-tracker/configs"
+	"user-activity-tracker/internal/cache"
+	"user-activity-tracker/internal/database"
+	"user-activity-tracker/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// How a client's end-user IDs are stored
+const (
+	UserIDHashed = "hashed"
+	UserIDRaw    = "raw"
+)
+
+const userIDStorageCacheTTL = time.Minute
+
+var ErrInvalidUserIDStorage = errors.New("user_id_storage must be hashed or raw")
+
+// UserIdentityService stores the end-user IDs clients report with their hits,
+// either as sent or as a keyed hash, depending on the client's setting
+type UserIdentityService struct {
+	db    *gorm.DB
+	cache *cache.CacheManager
+}
+
+func NewUserIdentityService() *UserIdentityService {
+	return &UserIdentityService{
+		db:    database.GetDBManager().WriteDB,
+		cache: cache.GetCacheManager(),
+	}
+}
+
+// Apply is the ingestion stage replacing the reported user ID with its stored form
+func (s *UserIdentityService) Apply(hit *models.APILogs) bool {
+	if hit.UserID != "" {
+		hit.UserID = s.StoredUserID(hit.ClientID, hit.UserID)
+	}
+	return true
+}
+
+// StoredUserID returns the form a user ID is stored in for the client
+func (s *UserIdentityService) StoredUserID(clientID, userID string) string {
+	if s.Storage(clientID) == UserIDRaw {
+		return userID
+	}
+	return HashUserID(clientID, userID)
+}
+
+// Storage returns the client's user ID storage setting. IDs are hashed when
+// the setting cannot be loaded.
+func (s *UserIdentityService) Storage(clientID string) string {
+	cacheKey := userIDStorageCacheKey(clientID)
+
+	var storage string
+	if found, err := s.cache.Get(cacheKey, &storage); found && err == nil {
+		return storage
+	}
+
+	var client models.Client
+	if err := s.db.Select("user_id_storage").Where("client_id = ?", clientID).First(&client).Error; err != nil {
+		return UserIDHashed
+	}
+	storage = client.UserIDStorage
+	if storage != UserIDRaw {
+		storage = UserIDHashed
+	}
+
+	s.cache.Set(cacheKey, storage, userIDStorageCacheTTL)
+	return storage
+}
+
+// SetStorage changes how the client's user IDs are stored from now on. IDs
+// already stored keep their previous form.
+func (s *UserIdentityService) SetStorage(clientID, storage string) error {
+	if storage != UserIDHashed && storage != UserIDRaw {
+		return ErrInvalidUserIDStorage
+	}
+
+	err := s.db.Model(&models.Client{}).Where("client_id = ?", clientID).Update("user_id_storage", storage).Error
+	if err != nil {
+		return err
+	}
+
+	s.cache.Delete(userIDStorageCacheKey(clientID))
+	return nil
+}
+
+// HashUserID pseudonymizes a user ID. The client ID is part of the input so the
+// same user ID reported by two clients cannot be linked.
+func HashUserID(clientID, userID string) string {
+	mac := hmac.New(sha256.New, []byte(configs.AppConfig.UserIDHashKey))
+	mac.Write([]byte(clientID))
+	mac.Write([]byte{0})
+	mac.Write([]byte(userID))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+func userIDStorageCacheKey(clientID string) string {
+	return fmt.Sprintf("user-id-storage:%s", clientID)
+}
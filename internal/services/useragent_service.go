@@ -0,0 +1,59 @@
+package services
+
+import (
+	"sync"
+
+	"user-activity-tracker/internal/models"
+	"user-activity-tracker/internal/useragent"
+)
+
+// maxParsedUserAgents bounds the cache of parsed agents. A handful of agents
+// makes up most traffic, so the cache is simply emptied when it fills up.
+const maxParsedUserAgents = 10000
+
+// UserAgentService enriches hits with the browser, OS, device and bot families
+// parsed from their user agent
+type UserAgentService struct {
+	mu     sync.RWMutex
+	parsed map[string]useragent.Agent
+}
+
+func NewUserAgentService() *UserAgentService {
+	return &UserAgentService{parsed: make(map[string]useragent.Agent)}
+}
+
+// Apply is the ingestion stage filling in the user agent fields of a hit
+func (s *UserAgentService) Apply(hit *models.APILogs) bool {
+	if hit.UserAgent == "" {
+		return true
+	}
+	agent := s.Parse(hit.UserAgent)
+
+	hit.Browser = agent.Browser
+	hit.BrowserVersion = agent.BrowserVersion
+	hit.OS = agent.OS
+	hit.OSVersion = agent.OSVersion
+	hit.DeviceType = agent.Device
+	hit.IsBot = agent.Bot
+	return true
+}
+
+// Parse classifies a user agent, reusing earlier results for the same string
+func (s *UserAgentService) Parse(ua string) useragent.Agent {
+	s.mu.RLock()
+	agent, ok := s.parsed[ua]
+	s.mu.RUnlock()
+	if ok {
+		return agent
+	}
+
+	agent = useragent.Parse(ua)
+
+	s.mu.Lock()
+	if len(s.parsed) >= maxParsedUserAgents {
+		s.parsed = make(map[string]useragent.Agent)
+	}
+	s.parsed[ua] = agent
+	s.mu.Unlock()
+	return agent
+}
@@ -0,0 +1,436 @@
+// Package spool is an append-only, disk-backed queue of records split across
+// segment files. Records are checksummed and fsynced on append; the replay
+// position is kept in a checkpoint file that is replaced atomically, so a
+// crash never loses acknowledged records or skips unreplayed ones.
+package spool
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	headerSize     = 8 // 4 byte length + 4 byte CRC32
+	segmentSuffix  = ".seg"
+	checkpointFile = "checkpoint"
+)
+
+// ErrFull is returned when an append would exceed the spool's size limit
+var ErrFull = errors.New("spool is full")
+
+var errCorrupt = errors.New("corrupt record")
+
+// Position identifies a byte offset within a segment
+type Position struct {
+	Segment uint64 `json:"segment"`
+	Offset  int64  `json:"offset"`
+}
+
+// Batch is a run of records read from the spool. Commit it once the records
+// have been processed so they are not read again.
+type Batch struct {
+	Records [][]byte
+	next    Position
+	bytes   int64
+}
+
+type Spool struct {
+	dir        string
+	segmentMax int64
+	maxBytes   int64
+
+	mu      sync.Mutex
+	file    *os.File // active segment, opened for appending
+	write   Position // end of the active segment
+	read    Position // committed replay position
+	pending int      // records not yet committed
+	size    int64    // bytes not yet committed
+}
+
+// Open opens or creates a spool in dir, recovering the backlog left by a
+// previous run. A torn record at the end of the active segment is truncated.
+func Open(dir string, segmentMax, maxBytes int64) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	s := &Spool{dir: dir, segmentMax: segmentMax, maxBytes: maxBytes}
+
+	segments, err := s.segments()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadCheckpoint(segments); err != nil {
+		return nil, err
+	}
+
+	// Segments before the checkpoint were fully replayed
+	for len(segments) > 0 && segments[0] < s.read.Segment {
+		os.Remove(s.segmentPath(segments[0]))
+		segments = segments[1:]
+	}
+	if len(segments) == 0 {
+		segments = []uint64{s.read.Segment}
+		s.read.Offset = 0
+	}
+
+	for i, segment := range segments {
+		start := int64(0)
+		if segment == s.read.Segment {
+			start = s.read.Offset
+		}
+
+		count, end, err := s.scan(segment, start)
+		if err != nil && !errors.Is(err, errCorrupt) {
+			return nil, err
+		}
+		if errors.Is(err, errCorrupt) {
+			log.Printf("Spool segment %d is corrupt after offset %d, discarding the remainder", segment, end)
+			if err := os.Truncate(s.segmentPath(segment), end); err != nil {
+				return nil, err
+			}
+		}
+
+		s.pending += count
+		s.size += end - start
+
+		if i == len(segments)-1 {
+			s.write = Position{Segment: segment, Offset: end}
+		}
+	}
+
+	if s.file, err = s.openSegment(s.write.Segment); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Append durably writes records in order. Either all records are written or none.
+func (s *Spool) Append(records [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var total int64
+	for _, record := range records {
+		total += int64(headerSize + len(record))
+	}
+	if s.maxBytes > 0 && s.size+total > s.maxBytes {
+		return ErrFull
+	}
+
+	start := s.write
+	var buf []byte
+	for _, record := range records {
+		used := s.write.Offset + int64(len(buf))
+		if used > 0 && used+int64(headerSize+len(record)) > s.segmentMax {
+			if err := s.writeAndSync(buf); err != nil {
+				return s.rollback(start, err)
+			}
+			buf = buf[:0]
+			if err := s.rotate(); err != nil {
+				return s.rollback(start, err)
+			}
+		}
+		buf = appendRecord(buf, record)
+	}
+	if err := s.writeAndSync(buf); err != nil {
+		return s.rollback(start, err)
+	}
+
+	s.pending += len(records)
+	s.size += total
+	return nil
+}
+
+// Read returns up to max records following the committed position
+func (s *Spool) Read(max int) (*Batch, error) {
+	s.mu.Lock()
+	pos, write := s.read, s.write
+	s.mu.Unlock()
+
+	batch := &Batch{next: pos}
+	for len(batch.Records) < max && pos != write {
+		limit := write.Offset
+		if pos.Segment < write.Segment {
+			info, err := os.Stat(s.segmentPath(pos.Segment))
+			if err != nil {
+				return nil, err
+			}
+			limit = info.Size()
+		}
+
+		records, end, err := s.readSegment(pos, limit, max-len(batch.Records))
+		if err != nil && !errors.Is(err, errCorrupt) {
+			return nil, err
+		}
+		batch.Records = append(batch.Records, records...)
+		batch.bytes += end - pos.Offset
+		pos.Offset = end
+
+		// Move on to the next segment once this one is exhausted
+		exhausted := end >= limit || errors.Is(err, errCorrupt)
+		if exhausted && pos.Segment < write.Segment {
+			batch.bytes += limit - end
+			pos = Position{Segment: pos.Segment + 1}
+		} else if errors.Is(err, errCorrupt) {
+			// The active segment was validated on open, so this only happens on disk errors
+			batch.next = pos
+			return batch, err
+		}
+		batch.next = pos
+	}
+	return batch, nil
+}
+
+// Commit marks a batch as processed and removes segments that are no longer needed
+func (s *Spool) Commit(batch *Batch) error {
+	if err := s.saveCheckpoint(batch.next); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	previous := s.read
+	s.read = batch.next
+	s.pending -= len(batch.Records)
+	s.size -= batch.bytes
+	s.mu.Unlock()
+
+	for segment := previous.Segment; segment < batch.next.Segment; segment++ {
+		os.Remove(s.segmentPath(segment))
+	}
+	return nil
+}
+
+// Pending returns the number and total size of records not yet committed
+func (s *Spool) Pending() (int, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending, s.size
+}
+
+// Close closes the active segment
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+func (s *Spool) writeAndSync(buf []byte) error {
+	if len(buf) == 0 {
+		return nil
+	}
+	if _, err := s.file.Write(buf); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	s.write.Offset += int64(len(buf))
+	return nil
+}
+
+// rollback discards a partially written append. Callers must hold s.mu.
+func (s *Spool) rollback(start Position, err error) error {
+	for s.write.Segment > start.Segment {
+		s.file.Close()
+		os.Remove(s.segmentPath(s.write.Segment))
+		s.write = Position{Segment: s.write.Segment - 1}
+		if file, openErr := s.openSegment(s.write.Segment); openErr == nil {
+			s.file = file
+		}
+	}
+
+	if truncErr := os.Truncate(s.segmentPath(start.Segment), start.Offset); truncErr != nil {
+		log.Printf("Failed to roll back spool segment %d: %v", start.Segment, truncErr)
+	}
+	s.write = start
+	return err
+}
+
+// rotate starts a new active segment. Callers must hold s.mu.
+func (s *Spool) rotate() error {
+	next := s.write.Segment + 1
+	file, err := s.openSegment(next)
+	if err != nil {
+		return err
+	}
+	if err := syncDir(s.dir); err != nil {
+		file.Close()
+		return err
+	}
+
+	s.file.Close()
+	s.file = file
+	s.write = Position{Segment: next}
+	return nil
+}
+
+// scan validates the records of a segment from start, returning how many
+// there are and where the valid data ends
+func (s *Spool) scan(segment uint64, start int64) (int, int64, error) {
+	info, err := os.Stat(s.segmentPath(segment))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, start, nil
+	}
+	if err != nil {
+		return 0, start, err
+	}
+
+	count := 0
+	pos := Position{Segment: segment, Offset: start}
+	for pos.Offset < info.Size() {
+		records, end, err := s.readSegment(pos, info.Size(), 1024)
+		count += len(records)
+		if err != nil {
+			return count, end, err
+		}
+		pos.Offset = end
+	}
+	return count, pos.Offset, nil
+}
+
+// readSegment reads up to max records between pos and limit
+func (s *Spool) readSegment(pos Position, limit int64, max int) ([][]byte, int64, error) {
+	file, err := os.Open(s.segmentPath(pos.Segment))
+	if err != nil {
+		return nil, pos.Offset, err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(pos.Offset, io.SeekStart); err != nil {
+		return nil, pos.Offset, err
+	}
+	reader := bufio.NewReader(io.LimitReader(file, limit-pos.Offset))
+
+	var records [][]byte
+	offset := pos.Offset
+	var header [headerSize]byte
+	for len(records) < max && offset < limit {
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			return records, offset, errCorrupt
+		}
+
+		length := binary.BigEndian.Uint32(header[:4])
+		if int64(length) > limit-offset-headerSize {
+			return records, offset, errCorrupt
+		}
+		record := make([]byte, length)
+		if _, err := io.ReadFull(reader, record); err != nil {
+			return records, offset, errCorrupt
+		}
+		if crc32.ChecksumIEEE(record) != binary.BigEndian.Uint32(header[4:]) {
+			return records, offset, errCorrupt
+		}
+
+		records = append(records, record)
+		offset += int64(headerSize) + int64(length)
+	}
+	return records, offset, nil
+}
+
+func (s *Spool) loadCheckpoint(segments []uint64) error {
+	data, err := os.ReadFile(filepath.Join(s.dir, checkpointFile))
+	if errors.Is(err, os.ErrNotExist) {
+		s.read = Position{Segment: 1}
+		if len(segments) > 0 {
+			s.read.Segment = segments[0]
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &s.read); err != nil {
+		return fmt.Errorf("read spool checkpoint: %w", err)
+	}
+	return nil
+}
+
+// saveCheckpoint atomically replaces the checkpoint file
+func (s *Spool) saveCheckpoint(pos Position) error {
+	data, err := json.Marshal(pos)
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(s.dir, checkpointFile+".tmp")
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, filepath.Join(s.dir, checkpointFile)); err != nil {
+		return err
+	}
+	return syncDir(s.dir)
+}
+
+func (s *Spool) segments() ([]uint64, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		n, err := strconv.ParseUint(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, n)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+	return segments, nil
+}
+
+func (s *Spool) openSegment(segment uint64) (*os.File, error) {
+	return os.OpenFile(s.segmentPath(segment), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+}
+
+func (s *Spool) segmentPath(segment uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", segment, segmentSuffix))
+}
+
+func appendRecord(buf, record []byte) []byte {
+	var header [headerSize]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(record)))
+	binary.BigEndian.PutUint32(header[4:], crc32.ChecksumIEEE(record))
+	buf = append(buf, header[:]...)
+	return append(buf, record...)
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,277 @@
+package spool
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func records(from, to int) [][]byte {
+	var out [][]byte
+	for i := from; i <= to; i++ {
+		out = append(out, []byte(fmt.Sprintf(`{"event_id":"evt-%d"}`, i)))
+	}
+	return out
+}
+
+func openSpool(t *testing.T, dir string, segmentMax, maxBytes int64) *Spool {
+	t.Helper()
+	s, err := Open(dir, segmentMax, maxBytes)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func readAll(t *testing.T, s *Spool) (*Batch, []string) {
+	t.Helper()
+	batch, err := s.Read(1000)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	var got []string
+	for _, record := range batch.Records {
+		got = append(got, string(record))
+	}
+	return batch, got
+}
+
+func wantRecords(t *testing.T, got []string, want [][]byte) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("read %d records %v, want %d", len(got), got, len(want))
+	}
+	for i := range want {
+		if got[i] != string(want[i]) {
+			t.Errorf("record %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func wantPending(t *testing.T, s *Spool, want int) {
+	t.Helper()
+	if pending, _ := s.Pending(); pending != want {
+		t.Errorf("Pending = %d, want %d", pending, want)
+	}
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestAppendReadAcrossSegments(t *testing.T) {
+	dir := t.TempDir()
+	s := openSpool(t, dir, 64, 0)
+
+	if err := s.Append(records(1, 10)); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if n := len(segmentFiles(t, dir)); n < 2 {
+		t.Fatalf("expected the records to span segments, got %d", n)
+	}
+	wantPending(t, s, 10)
+
+	batch, got := readAll(t, s)
+	wantRecords(t, got, records(1, 10))
+
+	if err := s.Commit(batch); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	wantPending(t, s, 0)
+	if pending, size := s.Pending(); pending != 0 || size != 0 {
+		t.Errorf("Pending = %d records, %d bytes after commit, want none", pending, size)
+	}
+	if n := len(segmentFiles(t, dir)); n != 1 {
+		t.Errorf("%d segments left after commit, want only the active one", n)
+	}
+}
+
+func TestTornLastRecord(t *testing.T) {
+	dir := t.TempDir()
+	s := openSpool(t, dir, 1<<20, 0)
+	if err := s.Append(records(1, 3)); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// Simulate a crash halfway through writing the last record
+	segment := segmentFiles(t, dir)[0]
+	info, err := os.Stat(segment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(segment, info.Size()-5); err != nil {
+		t.Fatal(err)
+	}
+
+	s = openSpool(t, dir, 1<<20, 0)
+	wantPending(t, s, 2)
+	_, got := readAll(t, s)
+	wantRecords(t, got, records(1, 2))
+
+	// New records follow the last intact one
+	if err := s.Append(records(4, 4)); err != nil {
+		t.Fatal(err)
+	}
+	_, got = readAll(t, s)
+	wantRecords(t, got, append(records(1, 2), records(4, 4)...))
+}
+
+func TestTornHeader(t *testing.T) {
+	dir := t.TempDir()
+	s := openSpool(t, dir, 1<<20, 0)
+	if err := s.Append(records(1, 2)); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// A crash can leave part of a header behind
+	file, err := os.OpenFile(segmentFiles(t, dir)[0], os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte{0, 0, 1})
+	file.Close()
+
+	s = openSpool(t, dir, 1<<20, 0)
+	wantPending(t, s, 2)
+	_, got := readAll(t, s)
+	wantRecords(t, got, records(1, 2))
+}
+
+func TestCRCMismatch(t *testing.T) {
+	dir := t.TempDir()
+	s := openSpool(t, dir, 1<<20, 0)
+	if err := s.Append(records(1, 3)); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// Flip a byte in the payload of the second record
+	segment := segmentFiles(t, dir)[0]
+	data, err := os.ReadFile(segment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := headerSize + len(records(1, 1)[0]) + headerSize
+	data[second] ^= 0xff
+	if err := os.WriteFile(segment, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s = openSpool(t, dir, 1<<20, 0)
+	wantPending(t, s, 1)
+	_, got := readAll(t, s)
+	wantRecords(t, got, records(1, 1))
+
+	info, err := os.Stat(segment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(headerSize + len(records(1, 1)[0])); info.Size() != want {
+		t.Errorf("segment is %d bytes after recovery, want it truncated to %d", info.Size(), want)
+	}
+}
+
+func TestCheckpointResume(t *testing.T) {
+	dir := t.TempDir()
+	s := openSpool(t, dir, 64, 0)
+	if err := s.Append(records(1, 8)); err != nil {
+		t.Fatal(err)
+	}
+
+	batch, err := s.Read(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Records) != 3 {
+		t.Fatalf("read %d records, want 3", len(batch.Records))
+	}
+	if err := s.Commit(batch); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s = openSpool(t, dir, 64, 0)
+	wantPending(t, s, 5)
+	batch, got := readAll(t, s)
+	wantRecords(t, got, records(4, 8))
+
+	if err := s.Commit(batch); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s = openSpool(t, dir, 64, 0)
+	wantPending(t, s, 0)
+	_, got = readAll(t, s)
+	wantRecords(t, got, nil)
+}
+
+func TestUncommittedBatchIsReadAgain(t *testing.T) {
+	dir := t.TempDir()
+	s := openSpool(t, dir, 1<<20, 0)
+	if err := s.Append(records(1, 4)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash between storing a batch and committing it replays the batch, so
+	// the same event IDs are read twice; the ingest service skips hits whose
+	// event_id is already stored.
+	if _, err := s.Read(2); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s = openSpool(t, dir, 1<<20, 0)
+	wantPending(t, s, 4)
+	_, got := readAll(t, s)
+	wantRecords(t, got, records(1, 4))
+}
+
+func TestDuplicateRecordsAreKept(t *testing.T) {
+	dir := t.TempDir()
+	s := openSpool(t, dir, 1<<20, 0)
+
+	// The spool is a plain log; the same event ID appended twice is read twice
+	duplicate := records(1, 1)
+	if err := s.Append(duplicate); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append(duplicate); err != nil {
+		t.Fatal(err)
+	}
+	_, got := readAll(t, s)
+	wantRecords(t, got, append(records(1, 1), records(1, 1)...))
+}
+
+func TestAppendBeyondLimit(t *testing.T) {
+	dir := t.TempDir()
+	record := records(1, 1)
+	limit := int64(2 * (headerSize + len(record[0])))
+	s := openSpool(t, dir, 1<<20, limit)
+
+	if err := s.Append(records(1, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append(records(3, 3)); !errors.Is(err, ErrFull) {
+		t.Fatalf("Append over the limit = %v, want ErrFull", err)
+	}
+	wantPending(t, s, 2)
+
+	batch, _ := readAll(t, s)
+	if err := s.Commit(batch); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append(records(3, 3)); err != nil {
+		t.Fatalf("Append after commit: %v", err)
+	}
+}
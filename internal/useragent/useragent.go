@@ -0,0 +1,198 @@
+// Package useragent classifies User-Agent strings into browser, operating
+// system, device type and bot families. It recognises the common browsers,
+// crawlers and HTTP client libraries; any other "product/version" agent, such
+// as an SDK, is reported under its product name.
+package useragent
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Device types
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+	DeviceLibrary = "library" // HTTP client libraries and SDKs
+	DeviceOther   = "other"
+)
+
+// Agent is the classification of a User-Agent string
+type Agent struct {
+	Browser        string // browser, crawler, library or SDK name
+	BrowserVersion string // major version for browsers, full version otherwise
+	OS             string
+	OSVersion      string
+	Device         string
+	Bot            bool
+}
+
+type family struct {
+	name    string
+	pattern *regexp.Regexp // first submatch is the version
+	major   bool           // keep the major version only
+}
+
+var (
+	// Named crawlers and monitors, checked before anything else
+	bots = []family{
+		{"Googlebot", regexp.MustCompile(`Googlebot(?:-\w+)?/([\d.]+)`), false},
+		{"Bingbot", regexp.MustCompile(`bingbot/([\d.]+)`), false},
+		{"YandexBot", regexp.MustCompile(`YandexBot/([\d.]+)`), false},
+		{"Baiduspider", regexp.MustCompile(`Baiduspider(?:-\w+)?/([\d.]+)`), false},
+		{"DuckDuckBot", regexp.MustCompile(`DuckDuckBot(?:-\w+)?/([\d.]+)`), false},
+		{"Applebot", regexp.MustCompile(`Applebot/([\d.]+)`), false},
+		{"facebookexternalhit", regexp.MustCompile(`facebookexternalhit/([\d.]+)`), false},
+		{"HeadlessChrome", regexp.MustCompile(`HeadlessChrome/(\d+)`), true},
+		{"UptimeRobot", regexp.MustCompile(`UptimeRobot/([\d.]+)`), false},
+		{"Pingdom", regexp.MustCompile(`Pingdom\.com_bot_version_([\d.]+)`), false},
+	}
+	// Other crawlers, by a product token such as "FooBot/1.2" or a bare word
+	// such as "crawler"; a phone model like "Cubot" is neither
+	genericBot = regexp.MustCompile(`(?i)\b([a-z0-9_.-]*(?:bot|crawler|spider|scraper))/v?([\d.]+)|\b(bot|crawler|spider|scraper)\b`)
+
+	// HTTP client libraries, matched anywhere in the string
+	libraries = []family{
+		{"curl", regexp.MustCompile(`^curl/([\d.]+)`), false},
+		{"Wget", regexp.MustCompile(`^Wget/([\d.]+)`), false},
+		{"python-requests", regexp.MustCompile(`python-requests/([\d.]+)`), false},
+		{"python-httpx", regexp.MustCompile(`python-httpx/([\d.]+)`), false},
+		{"aiohttp", regexp.MustCompile(`aiohttp/([\d.]+)`), false},
+		{"Python-urllib", regexp.MustCompile(`Python-urllib/([\d.]+)`), false},
+		{"Go-http-client", regexp.MustCompile(`Go-http-client/([\d.]+)`), false},
+		{"okhttp", regexp.MustCompile(`okhttp/([\d.]+)`), false},
+		{"axios", regexp.MustCompile(`axios/([\d.]+)`), false},
+		{"node-fetch", regexp.MustCompile(`node-fetch(?:/([\d.]+))?`), false},
+		{"undici", regexp.MustCompile(`undici(?:/([\d.]+))?`), false},
+		{"Apache-HttpClient", regexp.MustCompile(`Apache-HttpClient/([\d.]+)`), false},
+		{"Java", regexp.MustCompile(`^Java/([\d._]+)`), false},
+		{"PostmanRuntime", regexp.MustCompile(`PostmanRuntime/([\d.]+)`), false},
+		{"insomnia", regexp.MustCompile(`insomnia/([\d.]+)`), false},
+		{"Dart", regexp.MustCompile(`^Dart/([\d.]+)`), false},
+		{"GuzzleHttp", regexp.MustCompile(`GuzzleHttp/([\d.]+)`), false},
+		{"Faraday", regexp.MustCompile(`Faraday v([\d.]+)`), false},
+		{"RestSharp", regexp.MustCompile(`RestSharp/([\d.]+)`), false},
+		{"libwww-perl", regexp.MustCompile(`libwww-perl/([\d.]+)`), false},
+	}
+
+	// Browsers, in order: several of them also claim to be Chrome or Safari
+	browsers = []family{
+		{"Edge", regexp.MustCompile(`(?:Edg|Edge|EdgA|EdgiOS)/(\d+)`), true},
+		{"Opera", regexp.MustCompile(`(?:OPR|OPT|Opera)/(\d+)`), true},
+		{"Samsung Internet", regexp.MustCompile(`SamsungBrowser/(\d+)`), true},
+		{"Yandex Browser", regexp.MustCompile(`YaBrowser/(\d+)`), true},
+		{"Vivaldi", regexp.MustCompile(`Vivaldi/(\d+)`), true},
+		{"Firefox", regexp.MustCompile(`(?:Firefox|FxiOS)/(\d+)`), true},
+		{"Chrome", regexp.MustCompile(`(?:Chrome|CriOS)/(\d+)`), true},
+		{"Safari", regexp.MustCompile(`Version/(\d+)[\d.]* (?:Mobile/\S+ )?Safari/`), true},
+		{"Internet Explorer", regexp.MustCompile(`(?:MSIE |Trident/.*rv:)(\d+)`), true},
+	}
+
+	operatingSystems = []family{
+		{"Windows", regexp.MustCompile(`Windows NT ([\d.]+)`), false},
+		{"iOS", regexp.MustCompile(`(?:iPhone|CPU) OS ([\d_]+)`), false},
+		{"Android", regexp.MustCompile(`Android ([\d.]+)`), false},
+		{"ChromeOS", regexp.MustCompile(`CrOS \S+ ([\d.]+)`), false},
+		{"macOS", regexp.MustCompile(`Mac OS X ([\d_.]+)`), false},
+		{"Linux", regexp.MustCompile(`Linux()`), false},
+	}
+
+	windowsVersions = map[string]string{
+		"10.0": "10", // also Windows 11, which kept the same token
+		"6.3":  "8.1",
+		"6.2":  "8",
+		"6.1":  "7",
+		"6.0":  "Vista",
+		"5.1":  "XP",
+	}
+
+	// Any other agent of the form "name/version", e.g. "acme-sdk-js/2.3.1"
+	product = regexp.MustCompile(`^([A-Za-z][\w.-]*)/(v?[\w.-]+)`)
+)
+
+// Parse classifies a User-Agent string. An empty string gives an empty Agent.
+func Parse(ua string) Agent {
+	ua = strings.TrimSpace(ua)
+	if ua == "" {
+		return Agent{}
+	}
+
+	if name, version, ok := match(bots, ua); ok {
+		return Agent{Browser: name, BrowserVersion: version, Device: DeviceBot, Bot: true}
+	}
+	if m := genericBot.FindStringSubmatch(ua); m != nil {
+		name := m[1]
+		if name == "" {
+			name = m[3]
+		}
+		return Agent{Browser: truncate(name, 64), BrowserVersion: truncate(m[2], 20), Device: DeviceBot, Bot: true}
+	}
+
+	if name, version, ok := match(libraries, ua); ok {
+		return Agent{Browser: name, BrowserVersion: version, Device: DeviceLibrary}
+	}
+
+	if !strings.HasPrefix(ua, "Mozilla/") && !strings.HasPrefix(ua, "Opera/") {
+		if m := product.FindStringSubmatch(ua); m != nil {
+			return Agent{Browser: truncate(m[1], 64), BrowserVersion: truncate(m[2], 20), Device: DeviceLibrary}
+		}
+		return Agent{Browser: "Other", Device: DeviceOther}
+	}
+
+	agent := Agent{Browser: "Other", Device: DeviceOther}
+	if name, version, ok := match(browsers, ua); ok {
+		agent.Browser, agent.BrowserVersion = name, version
+	}
+	if name, version, ok := match(operatingSystems, ua); ok {
+		agent.OS, agent.OSVersion = name, version
+		switch name {
+		case "Windows":
+			if v, ok := windowsVersions[version]; ok {
+				agent.OSVersion = v
+			}
+		case "iOS", "macOS":
+			agent.OSVersion = strings.ReplaceAll(version, "_", ".")
+		}
+	}
+	agent.Device = device(ua, agent.OS)
+	return agent
+}
+
+func device(ua, os string) string {
+	switch {
+	case strings.Contains(ua, "iPad") || strings.Contains(ua, "Tablet"):
+		return DeviceTablet
+	case os == "Android" && !strings.Contains(ua, "Mobile"):
+		return DeviceTablet
+	case strings.Contains(ua, "Mobile") || strings.Contains(ua, "iPhone") || os == "iOS":
+		return DeviceMobile
+	case os != "":
+		return DeviceDesktop
+	}
+	return DeviceOther
+}
+
+// match returns the first family whose pattern matches ua
+func match(families []family, ua string) (string, string, bool) {
+	for _, f := range families {
+		m := f.pattern.FindStringSubmatch(ua)
+		if m == nil {
+			continue
+		}
+		version := m[1]
+		if f.major {
+			version, _, _ = strings.Cut(version, ".")
+		}
+		return f.name, truncate(version, 20), true
+	}
+	return "", "", false
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
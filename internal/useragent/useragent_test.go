@@ -0,0 +1,150 @@
+package useragent
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		ua   string
+		want Agent
+	}{
+		{
+			"Chrome on Windows 10",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.109 Safari/537.36",
+			Agent{Browser: "Chrome", BrowserVersion: "120", OS: "Windows", OSVersion: "10", Device: DeviceDesktop},
+		},
+		{
+			"Edge on Windows 7",
+			"Mozilla/5.0 (Windows NT 6.1; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/109.0.0.0 Safari/537.36 Edg/109.0.1518.78",
+			Agent{Browser: "Edge", BrowserVersion: "109", OS: "Windows", OSVersion: "7", Device: DeviceDesktop},
+		},
+		{
+			"Firefox on Windows 8.1",
+			"Mozilla/5.0 (Windows NT 6.3; Win64; x64; rv:115.0) Gecko/20100101 Firefox/115.0",
+			Agent{Browser: "Firefox", BrowserVersion: "115", OS: "Windows", OSVersion: "8.1", Device: DeviceDesktop},
+		},
+		{
+			"Internet Explorer on Windows XP",
+			"Mozilla/4.0 (compatible; MSIE 8.0; Windows NT 5.1; Trident/4.0)",
+			Agent{Browser: "Internet Explorer", BrowserVersion: "8", OS: "Windows", OSVersion: "XP", Device: DeviceDesktop},
+		},
+		{
+			"Internet Explorer 11 on Windows 8",
+			"Mozilla/5.0 (Windows NT 6.2; Trident/7.0; rv:11.0) like Gecko",
+			Agent{Browser: "Internet Explorer", BrowserVersion: "11", OS: "Windows", OSVersion: "8", Device: DeviceDesktop},
+		},
+		{
+			"unmapped Windows NT version",
+			"Mozilla/5.0 (Windows NT 5.2; Win64; x64; rv:52.0) Gecko/20100101 Firefox/52.0",
+			Agent{Browser: "Firefox", BrowserVersion: "52", OS: "Windows", OSVersion: "5.2", Device: DeviceDesktop},
+		},
+		{
+			"Opera on Linux",
+			"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36 OPR/105.0.0.0",
+			Agent{Browser: "Opera", BrowserVersion: "105", OS: "Linux", Device: DeviceDesktop},
+		},
+		{
+			"Safari on macOS",
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15",
+			Agent{Browser: "Safari", BrowserVersion: "17", OS: "macOS", OSVersion: "10.15.7", Device: DeviceDesktop},
+		},
+		{
+			"Chrome on ChromeOS",
+			"Mozilla/5.0 (X11; CrOS x86_64 15633.69.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36",
+			Agent{Browser: "Chrome", BrowserVersion: "119", OS: "ChromeOS", OSVersion: "15633.69.0", Device: DeviceDesktop},
+		},
+		{
+			"Safari on iPhone",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1.2 Mobile/15E148 Safari/604.1",
+			Agent{Browser: "Safari", BrowserVersion: "17", OS: "iOS", OSVersion: "17.1.2", Device: DeviceMobile},
+		},
+		{
+			"Chrome on iPhone",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/119.0.6045.169 Mobile/15E148 Safari/604.1",
+			Agent{Browser: "Chrome", BrowserVersion: "119", OS: "iOS", OSVersion: "16.6", Device: DeviceMobile},
+		},
+		{
+			"Safari on iPad",
+			"Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1",
+			Agent{Browser: "Safari", BrowserVersion: "16", OS: "iOS", OSVersion: "16.6", Device: DeviceTablet},
+		},
+		{
+			"Samsung Internet on an Android phone",
+			"Mozilla/5.0 (Linux; Android 13; SM-S911B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Mobile Safari/537.36",
+			Agent{Browser: "Samsung Internet", BrowserVersion: "23", OS: "Android", OSVersion: "13", Device: DeviceMobile},
+		},
+		{
+			"Chrome on an Android tablet",
+			"Mozilla/5.0 (Linux; Android 12; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			Agent{Browser: "Chrome", BrowserVersion: "120", OS: "Android", OSVersion: "12", Device: DeviceTablet},
+		},
+		{
+			"Cubot phone",
+			"Mozilla/5.0 (Linux; Android 9; CUBOT_X19) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/110.0.0.0 Mobile Safari/537.36",
+			Agent{Browser: "Chrome", BrowserVersion: "110", OS: "Android", OSVersion: "9", Device: DeviceMobile},
+		},
+		{
+			"Cubot phone with a build",
+			"Mozilla/5.0 (Linux; Android 10; Cubot Note 20 Build/QP1A.190711.020) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Mobile Safari/537.36",
+			Agent{Browser: "Chrome", BrowserVersion: "118", OS: "Android", OSVersion: "10", Device: DeviceMobile},
+		},
+
+		// HTTP libraries and SDKs
+		{"curl", "curl/8.4.0", Agent{Browser: "curl", BrowserVersion: "8.4.0", Device: DeviceLibrary}},
+		{"python-requests", "python-requests/2.31.0", Agent{Browser: "python-requests", BrowserVersion: "2.31.0", Device: DeviceLibrary}},
+		{"Go", "Go-http-client/2.0", Agent{Browser: "Go-http-client", BrowserVersion: "2.0", Device: DeviceLibrary}},
+		{"okhttp", "okhttp/4.12.0", Agent{Browser: "okhttp", BrowserVersion: "4.12.0", Device: DeviceLibrary}},
+		{"node-fetch without a version", "node-fetch", Agent{Browser: "node-fetch", Device: DeviceLibrary}},
+		{"Java", "Java/17.0.9", Agent{Browser: "Java", BrowserVersion: "17.0.9", Device: DeviceLibrary}},
+		{"Faraday", "Faraday v2.7.11", Agent{Browser: "Faraday", BrowserVersion: "2.7.11", Device: DeviceLibrary}},
+		{"SDK", "acme-sdk-js/2.3.1 (node 20.10.0)", Agent{Browser: "acme-sdk-js", BrowserVersion: "2.3.1", Device: DeviceLibrary}},
+		{"SDK with a v version", "AcmeSDK/v1.0.0-beta.2", Agent{Browser: "AcmeSDK", BrowserVersion: "v1.0.0-beta.2", Device: DeviceLibrary}},
+		{"unknown", "something odd", Agent{Browser: "Other", Device: DeviceOther}},
+		{"empty", "  ", Agent{}},
+
+		// Bots
+		{
+			"Googlebot",
+			"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			Agent{Browser: "Googlebot", BrowserVersion: "2.1", Device: DeviceBot, Bot: true},
+		},
+		{
+			"Googlebot smartphone",
+			"Mozilla/5.0 (Linux; Android 6.0.1; Nexus 5X Build/MMB29P) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.71 Mobile Safari/537.36 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			Agent{Browser: "Googlebot", BrowserVersion: "2.1", Device: DeviceBot, Bot: true},
+		},
+		{
+			"Bingbot",
+			"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)",
+			Agent{Browser: "Bingbot", BrowserVersion: "2.0", Device: DeviceBot, Bot: true},
+		},
+		{
+			"HeadlessChrome",
+			"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/120.0.6099.28 Safari/537.36",
+			Agent{Browser: "HeadlessChrome", BrowserVersion: "120", Device: DeviceBot, Bot: true},
+		},
+		{
+			"other bot product",
+			"Mozilla/5.0 (compatible; AhrefsBot/7.0; +http://ahrefs.com/robot/)",
+			Agent{Browser: "AhrefsBot", BrowserVersion: "7.0", Device: DeviceBot, Bot: true},
+		},
+		{
+			"other bot with a v version",
+			"Mozilla/5.0 (compatible; MJ12bot/v1.4.8; http://mj12bot.com/)",
+			Agent{Browser: "MJ12bot", BrowserVersion: "1.4.8", Device: DeviceBot, Bot: true},
+		},
+		{
+			"bare crawler word",
+			"Mozilla/5.0 (compatible; generic crawler)",
+			Agent{Browser: "crawler", Device: DeviceBot, Bot: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.ua); got != tt.want {
+				t.Errorf("Parse(%q)\n got %+v\nwant %+v", tt.ua, got, tt.want)
+			}
+		})
+	}
+}
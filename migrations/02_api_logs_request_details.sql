@@ -0,0 +1,11 @@
+-- Optional request details on API hits
+USE activity_tracker;
+
+ALTER TABLE api_logs
+    ADD COLUMN method VARCHAR(10) NULL AFTER timestamp,
+    ADD COLUMN status_code SMALLINT UNSIGNED NULL AFTER method,
+    ADD COLUMN latency_ms INT UNSIGNED NULL AFTER status_code,
+    ADD COLUMN request_bytes BIGINT UNSIGNED NULL AFTER latency_ms,
+    ADD COLUMN response_bytes BIGINT UNSIGNED NULL AFTER request_bytes,
+    ADD COLUMN user_agent VARCHAR(512) NULL AFTER response_bytes,
+    ADD INDEX idx_client_status (client_id, status_code, timestamp);
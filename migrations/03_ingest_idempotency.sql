@@ -0,0 +1,20 @@
+-- Idempotent log ingestion
+USE activity_tracker;
+
+ALTER TABLE api_logs
+    ADD COLUMN event_id VARCHAR(64) NULL AFTER client_id,
+    ADD INDEX idx_client_event (client_id, event_id);
+
+-- api_logs is partitioned by timestamp, so uniqueness of retried submissions
+-- is enforced in a separate table keyed by (client_id, idempotency_key)
+CREATE TABLE IF NOT EXISTS ingest_idempotency (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    client_id VARCHAR(100) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    event_id VARCHAR(64) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY idx_client_key (client_id, idempotency_key),
+    INDEX idx_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Expired keys are purged by the application using IDEMPOTENCY_TTL
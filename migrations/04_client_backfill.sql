@@ -0,0 +1,5 @@
+-- Permission to backfill historical hits
+USE activity_tracker;
+
+ALTER TABLE clients
+    ADD COLUMN allow_backfill BOOLEAN NOT NULL DEFAULT FALSE AFTER ip_whitelist;
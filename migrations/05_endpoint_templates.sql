@@ -0,0 +1,15 @@
+-- Endpoint normalization into route templates
+USE activity_tracker;
+
+ALTER TABLE api_logs
+    ADD COLUMN endpoint_template VARCHAR(500) NULL AFTER timestamp,
+    ADD INDEX idx_client_template (client_id, endpoint_template, timestamp);
+
+CREATE TABLE IF NOT EXISTS route_templates (
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    client_id VARCHAR(100) NOT NULL,
+    template VARCHAR(500) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY idx_client_template (client_id, template),
+    FOREIGN KEY (client_id) REFERENCES clients(client_id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,22 @@
+-- Custom labels on API hits
+USE activity_tracker;
+
+CREATE TABLE IF NOT EXISTS api_log_labels (
+    log_id BIGINT UNSIGNED NOT NULL,
+    label_key VARCHAR(64) NOT NULL,
+    label_value VARCHAR(128) NOT NULL,
+    client_id VARCHAR(100) NOT NULL,
+    timestamp DATETIME NOT NULL,
+    PRIMARY KEY (log_id, label_key),
+    INDEX idx_client_label (client_id, label_key, label_value, timestamp),
+    INDEX idx_label_time (label_key, label_value, timestamp)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS client_label_values (
+    client_id VARCHAR(100) NOT NULL,
+    label_key VARCHAR(64) NOT NULL,
+    label_value VARCHAR(128) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (client_id, label_key, label_value),
+    FOREIGN KEY (client_id) REFERENCES clients(client_id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
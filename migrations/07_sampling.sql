@@ -0,0 +1,42 @@
+-- Per-client sampling with weighted rows
+USE activity_tracker;
+
+ALTER TABLE api_logs
+    ADD COLUMN sample_weight DOUBLE NOT NULL DEFAULT 1 AFTER endpoint_template;
+
+ALTER TABLE api_log_labels
+    ADD COLUMN sample_weight DOUBLE NOT NULL DEFAULT 1 AFTER timestamp;
+
+CREATE TABLE IF NOT EXISTS sampling_policies (
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    client_id VARCHAR(100) NOT NULL,
+    endpoint_template VARCHAR(500) NOT NULL DEFAULT '',
+    rate DOUBLE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY idx_client_template (client_id, endpoint_template),
+    FOREIGN KEY (client_id) REFERENCES clients(client_id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Daily aggregates count the hits each stored row stands for
+DROP PROCEDURE IF EXISTS AggregateDailyUsage;
+
+DELIMITER //
+
+CREATE PROCEDURE AggregateDailyUsage()
+BEGIN
+    INSERT INTO daily_usage (client_id, date, request_count)
+    SELECT
+        client_id,
+        DATE(timestamp),
+        CAST(ROUND(SUM(sample_weight)) AS SIGNED)
+    FROM api_logs
+    WHERE timestamp >= CURDATE() - INTERVAL 1 DAY
+      AND timestamp < CURDATE()
+    GROUP BY client_id, DATE(timestamp)
+    ON DUPLICATE KEY UPDATE
+        request_count = VALUES(request_count),
+        updated_at = CURRENT_TIMESTAMP;
+END //
+
+DELIMITER ;
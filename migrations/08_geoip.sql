@@ -0,0 +1,8 @@
+-- GeoIP enrichment of hits
+USE activity_tracker;
+
+ALTER TABLE api_logs
+    ADD COLUMN country CHAR(2) NULL AFTER user_agent,
+    ADD COLUMN region VARCHAR(100) NULL AFTER country,
+    ADD COLUMN asn INT UNSIGNED NULL AFTER region,
+    ADD COLUMN as_org VARCHAR(255) NULL AFTER asn;
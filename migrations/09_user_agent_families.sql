@@ -0,0 +1,10 @@
+-- User agents parsed into browser, OS, device and bot families
+USE activity_tracker;
+
+ALTER TABLE api_logs
+    ADD COLUMN browser VARCHAR(64) NULL AFTER as_org,
+    ADD COLUMN browser_version VARCHAR(20) NULL AFTER browser,
+    ADD COLUMN os VARCHAR(32) NULL AFTER browser_version,
+    ADD COLUMN os_version VARCHAR(20) NULL AFTER os,
+    ADD COLUMN device_type VARCHAR(16) NULL AFTER os_version,
+    ADD COLUMN is_bot BOOLEAN NOT NULL DEFAULT FALSE AFTER device_type;
@@ -0,0 +1,9 @@
+-- End-user IDs on hits
+USE activity_tracker;
+
+ALTER TABLE clients
+    ADD COLUMN user_id_storage VARCHAR(10) NOT NULL DEFAULT 'hashed' AFTER allow_backfill;
+
+ALTER TABLE api_logs
+    ADD COLUMN user_id VARCHAR(128) NULL AFTER ip_address,
+    ADD INDEX idx_client_user (client_id, user_id, timestamp);
@@ -0,0 +1,30 @@
+-- Sessions of end users and IP addresses
+USE activity_tracker;
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    client_id VARCHAR(100) NOT NULL,
+    visitor_key VARCHAR(140) NOT NULL,
+    user_id VARCHAR(128) NULL,
+    ip_address VARCHAR(45) NULL,
+    start_time DATETIME NOT NULL,
+    end_time DATETIME NOT NULL,
+    hit_count INT UNSIGNED NOT NULL DEFAULT 0,
+    entry_endpoint VARCHAR(500) NULL,
+    exit_endpoint VARCHAR(500) NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_client_visitor (client_id, visitor_key, start_time),
+    INDEX idx_client_start (client_id, start_time),
+    FOREIGN KEY (client_id) REFERENCES clients(client_id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Sessionization starts with hits recorded after this migration
+CREATE TABLE IF NOT EXISTS session_progress (
+    id INT UNSIGNED PRIMARY KEY,
+    last_log_id BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) ENGINE=InnoDB;
+
+INSERT IGNORE INTO session_progress (id, last_log_id)
+SELECT 1, COALESCE(MAX(id), 0) FROM api_logs;
@@ -0,0 +1,13 @@
+-- Per-client redaction of endpoints before storage
+USE activity_tracker;
+
+CREATE TABLE IF NOT EXISTS redaction_rules (
+    client_id VARCHAR(100) PRIMARY KEY,
+    query_mode VARCHAR(5) NOT NULL DEFAULT 'deny',
+    query_params TEXT NULL,
+    patterns TEXT NULL,
+    detect_emails BOOLEAN NOT NULL DEFAULT TRUE,
+    detect_jwts BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (client_id) REFERENCES clients(client_id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,13 @@
+-- IP address privacy modes and anonymization of old rows
+USE activity_tracker;
+
+ALTER TABLE clients
+    ADD COLUMN ip_privacy VARCHAR(10) NOT NULL DEFAULT 'full' AFTER user_id_storage;
+
+ALTER TABLE api_logs
+    ADD COLUMN ip_anonymized BOOLEAN NOT NULL DEFAULT FALSE AFTER ip_address,
+    ADD INDEX idx_ip_anonymized (ip_anonymized, timestamp);
+
+ALTER TABLE sessions
+    ADD COLUMN ip_anonymized BOOLEAN NOT NULL DEFAULT FALSE AFTER ip_address,
+    ADD INDEX idx_ip_anonymized (ip_anonymized, end_time);
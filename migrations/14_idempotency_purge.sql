@@ -0,0 +1,4 @@
+-- Idempotency keys are purged by the application using IDEMPOTENCY_TTL
+USE activity_tracker;
+
+DROP EVENT IF EXISTS purge_ingest_idempotency;
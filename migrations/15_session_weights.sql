@@ -0,0 +1,5 @@
+-- Session hit counts are sums of sample weights
+USE activity_tracker;
+
+ALTER TABLE sessions
+    MODIFY hit_count DOUBLE NOT NULL DEFAULT 0;
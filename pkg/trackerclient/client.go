@@ -0,0 +1,438 @@
+// Package trackerclient reports API hits to the user activity tracker.
+//
+// Hits are queued in memory and sent in batches to POST /api/logs/batch by
+// background goroutines. Tracking never blocks the caller: when the buffer is
+// full, a batch still fails after all retries, or the tracker rejects a hit,
+// hits are dropped and counted.
+package trackerclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrClosed is returned when flushing a client that has been closed
+var ErrClosed = errors.New("trackerclient: client is closed")
+
+// Config configures a Client. Only BaseURL and APIKey are required.
+type Config struct {
+	BaseURL string // tracker address, e.g. http://tracker:8080
+	APIKey  string
+
+	BatchSize     int           // hits per request, default 100
+	FlushInterval time.Duration // maximum time a hit waits before being sent, default 2s
+	BufferSize    int           // hits held in memory before new ones are dropped, default 10000
+	MaxRetries    int           // retries per batch after the first attempt, default 3, negative for none
+	RetryBackoff  time.Duration // initial backoff, doubled on every retry, default 500ms
+	Timeout       time.Duration // per-request timeout, default 5s
+	MaxInFlight   int           // batches sent or waiting for a retry at the same time, default 4
+
+	// Labels are added to every hit that does not set them itself
+	Labels map[string]string
+
+	// UserID, when set, gives the end user of a request tracked by the middleware.
+	// It is called after the wrapped handler has run.
+	UserID func(r *http.Request) string
+
+	// OnError, when set, receives every delivery failure instead of Logger.
+	// Hits refused by the tracker are reported as a *RejectedError.
+	// It is called from the sending goroutines and must not block.
+	OnError func(err error)
+
+	HTTPClient *http.Client
+	Logger     *log.Logger
+}
+
+// Hit is one API hit as accepted by the tracker's batch endpoint
+type Hit struct {
+	EventID       string            `json:"event_id,omitempty"`
+	Endpoint      string            `json:"endpoint"`
+	Timestamp     *time.Time        `json:"timestamp,omitempty"`
+	IPAddress     string            `json:"ip_address,omitempty"`
+	Method        string            `json:"method,omitempty"`
+	StatusCode    *uint16           `json:"status_code,omitempty"`
+	LatencyMs     *uint32           `json:"latency_ms,omitempty"`
+	RequestBytes  *uint64           `json:"request_bytes,omitempty"`
+	ResponseBytes *uint64           `json:"response_bytes,omitempty"`
+	UserAgent     string            `json:"user_agent,omitempty"`
+	UserID        string            `json:"user_id,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+}
+
+// Stats are the client's delivery counters
+type Stats struct {
+	Queued  int    // hits currently waiting in memory
+	Sent    uint64 // hits accepted by the tracker, including duplicates of earlier hits
+	Dropped uint64 // hits lost because the buffer was full, delivery failed or the tracker rejected them
+	Retries uint64 // batch requests that were retried
+}
+
+// RejectedHit is a hit the tracker refused, with its reason
+type RejectedHit struct {
+	Hit    Hit
+	Reason string
+}
+
+// RejectedError reports the hits of a batch that the tracker refused
+type RejectedError struct {
+	Hits []RejectedHit
+}
+
+func (e *RejectedError) Error() string {
+	if len(e.Hits) == 1 {
+		return "trackerclient: tracker rejected 1 hit: " + e.Hits[0].Reason
+	}
+	return fmt.Sprintf("trackerclient: tracker rejected %d hits, first: %s", len(e.Hits), e.Hits[0].Reason)
+}
+
+// batchResponse is the part of the batch endpoint's response the client reads
+type batchResponse struct {
+	Results []struct {
+		Index  int    `json:"index"`
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	} `json:"results"`
+}
+
+type flushRequest struct {
+	done chan struct{}
+}
+
+type Client struct {
+	cfg      Config
+	endpoint string
+	hits     chan Hit
+	flushes  chan flushRequest
+
+	sent    atomic.Uint64
+	dropped atomic.Uint64
+	retries atomic.Uint64
+
+	// slots bounds the batches being sent; a sender holds one until its batch
+	// is delivered or dropped
+	slots chan struct{}
+
+	// mu orders Track against Close so no hit is queued after the final drain
+	mu       sync.RWMutex
+	isClosed bool
+	closed   chan struct{}
+	done     chan struct{}
+}
+
+// New creates a client and starts its background sender
+func New(cfg Config) *Client {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 2 * time.Second
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 10000
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	} else if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = 3
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = 500 * time.Millisecond
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = 4
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{}
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.Default()
+	}
+
+	c := &Client{
+		cfg:      cfg,
+		endpoint: strings.TrimRight(cfg.BaseURL, "/") + "/api/logs/batch",
+		hits:     make(chan Hit, cfg.BufferSize),
+		flushes:  make(chan flushRequest),
+		slots:    make(chan struct{}, cfg.MaxInFlight),
+		closed:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+// Track queues a hit without blocking. It returns false when the hit was dropped.
+func (c *Client) Track(hit Hit) bool {
+	if hit.EventID == "" {
+		hit.EventID = newEventID()
+	}
+	if hit.Timestamp == nil {
+		now := time.Now()
+		hit.Timestamp = &now
+	}
+	if len(c.cfg.Labels) > 0 {
+		labels := make(map[string]string, len(c.cfg.Labels)+len(hit.Labels))
+		for key, value := range c.cfg.Labels {
+			labels[key] = value
+		}
+		for key, value := range hit.Labels {
+			labels[key] = value
+		}
+		hit.Labels = labels
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.isClosed {
+		c.dropped.Add(1)
+		return false
+	}
+
+	select {
+	case c.hits <- hit:
+		return true
+	default:
+		c.dropped.Add(1)
+		return false
+	}
+}
+
+// Stats returns the current delivery counters
+func (c *Client) Stats() Stats {
+	return Stats{
+		Queued:  len(c.hits),
+		Sent:    c.sent.Load(),
+		Dropped: c.dropped.Load(),
+		Retries: c.retries.Load(),
+	}
+}
+
+// Flush sends every hit queued so far and waits until done or ctx expires
+func (c *Client) Flush(ctx context.Context) error {
+	req := flushRequest{done: make(chan struct{})}
+
+	select {
+	case c.flushes <- req:
+	case <-c.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-req.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting hits and sends the remaining ones, waiting until done
+// or ctx expires. Hits tracked after Close are dropped.
+func (c *Client) Close(ctx context.Context) error {
+	c.mu.Lock()
+	if !c.isClosed {
+		c.isClosed = true
+		close(c.closed)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Client) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Hit, 0, c.cfg.BatchSize)
+	send := func() {
+		if len(batch) > 0 {
+			c.dispatch(batch)
+			batch = make([]Hit, 0, c.cfg.BatchSize)
+		}
+	}
+
+	for {
+		select {
+		case hit := <-c.hits:
+			batch = append(batch, hit)
+			if len(batch) >= c.cfg.BatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case req := <-c.flushes:
+			c.drain(&batch, send)
+			send()
+			c.waitIdle()
+			close(req.done)
+		case <-c.closed:
+			c.drain(&batch, send)
+			send()
+			c.waitIdle()
+			return
+		}
+	}
+}
+
+// drain moves every queued hit into batches, sending full ones
+func (c *Client) drain(batch *[]Hit, send func()) {
+	for {
+		select {
+		case hit := <-c.hits:
+			*batch = append(*batch, hit)
+			if len(*batch) >= c.cfg.BatchSize {
+				send()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// dispatch sends a batch on its own goroutine, so a batch waiting for a retry
+// does not hold up the next ones. It waits while MaxInFlight batches are out;
+// hits keep queueing in the buffer meanwhile.
+func (c *Client) dispatch(batch []Hit) {
+	c.slots <- struct{}{}
+	go func() {
+		defer func() { <-c.slots }()
+		c.send(batch)
+	}()
+}
+
+// waitIdle waits until every dispatched batch has been delivered or dropped
+func (c *Client) waitIdle() {
+	for i := 0; i < cap(c.slots); i++ {
+		c.slots <- struct{}{}
+	}
+	for i := 0; i < cap(c.slots); i++ {
+		<-c.slots
+	}
+}
+
+// send delivers a batch, retrying transient failures with exponential backoff
+func (c *Client) send(batch []Hit) {
+	body, err := json.Marshal(map[string][]Hit{"entries": batch})
+	if err != nil {
+		c.drop(len(batch), fmt.Errorf("trackerclient: encode batch: %w", err))
+		return
+	}
+
+	backoff := c.cfg.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		resp, wait, err := c.post(body)
+		if err == nil || resp != nil {
+			c.settle(batch, resp)
+			return
+		}
+		if wait < 0 || attempt >= c.cfg.MaxRetries {
+			c.drop(len(batch), fmt.Errorf("trackerclient: dropping %d hits: %w", len(batch), err))
+			return
+		}
+
+		c.retries.Add(1)
+		if wait == 0 {
+			wait = backoff
+			backoff *= 2
+		}
+		time.Sleep(wait)
+	}
+}
+
+// settle counts the outcome of every hit in a batch the tracker answered
+func (c *Client) settle(batch []Hit, resp *batchResponse) {
+	var rejected []RejectedHit
+	for _, result := range resp.Results {
+		if result.Status == "rejected" && result.Index >= 0 && result.Index < len(batch) {
+			rejected = append(rejected, RejectedHit{Hit: batch[result.Index], Reason: result.Error})
+		}
+	}
+
+	c.sent.Add(uint64(len(batch) - len(rejected)))
+	if len(rejected) > 0 {
+		c.drop(len(rejected), &RejectedError{Hits: rejected})
+	}
+}
+
+// drop counts lost hits and reports why
+func (c *Client) drop(n int, err error) {
+	c.dropped.Add(uint64(n))
+	if c.cfg.OnError != nil {
+		c.cfg.OnError(err)
+	} else {
+		c.cfg.Logger.Print(err)
+	}
+}
+
+// post sends one batch request and returns the tracker's per-hit results.
+// Results are also returned when the tracker refused the whole batch. On
+// failure it returns how long to wait before retrying: 0 for the default
+// backoff, or a negative value when not retryable. Event IDs make retries
+// safe; the tracker reports repeats as duplicates.
+func (c *Client) post(body []byte) (*batchResponse, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, -1, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", c.cfg.APIKey)
+
+	resp, err := c.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	var result batchResponse
+	decodeErr := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result)
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+
+	switch {
+	case resp.StatusCode < 300:
+		return &result, 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		err = fmt.Errorf("tracker returned %s", resp.Status)
+		if seconds, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil && seconds > 0 {
+			return nil, time.Duration(seconds) * time.Second, err
+		}
+		return nil, 0, err
+	case resp.StatusCode == http.StatusBadRequest && decodeErr == nil && len(result.Results) > 0:
+		return &result, -1, fmt.Errorf("tracker rejected batch: %s", resp.Status)
+	default:
+		return nil, -1, fmt.Errorf("tracker rejected batch: %s", resp.Status)
+	}
+}
+
+func newEventID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,172 @@
+package trackerclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// batchServer answers batch requests with respond and records the entries it received
+func batchServer(t *testing.T, respond func(w http.ResponseWriter, entries []Hit)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Entries []Hit `json:"entries"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode batch: %v", err)
+		}
+		respond(w, body.Entries)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRejectedEntriesAreDropped(t *testing.T) {
+	srv := batchServer(t, func(w http.ResponseWriter, entries []Hit) {
+		results := make([]map[string]interface{}, len(entries))
+		for i, entry := range entries {
+			status := "accepted"
+			if entry.Endpoint == "" {
+				status = "rejected"
+			}
+			results[i] = map[string]interface{}{"index": i, "status": status, "error": "endpoint is required"}
+		}
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+	})
+
+	var mu sync.Mutex
+	var errs []error
+	client := New(Config{BaseURL: srv.URL, APIKey: "key", OnError: func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}})
+
+	client.Track(Hit{Endpoint: "/a"})
+	client.Track(Hit{Endpoint: ""})
+	client.Track(Hit{Endpoint: "/b"})
+	if err := client.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := client.Stats()
+	if stats.Sent != 2 || stats.Dropped != 1 {
+		t.Errorf("Sent = %d, Dropped = %d, want 2 and 1", stats.Sent, stats.Dropped)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) != 1 {
+		t.Fatalf("OnError called %d times, want 1", len(errs))
+	}
+	var rejected *RejectedError
+	if !errors.As(errs[0], &rejected) || len(rejected.Hits) != 1 || rejected.Hits[0].Reason != "endpoint is required" {
+		t.Errorf("OnError got %v, want a RejectedError for one hit", errs[0])
+	}
+}
+
+func TestBatchRejectedAsAWholeIsDropped(t *testing.T) {
+	srv := batchServer(t, func(w http.ResponseWriter, entries []Hit) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"results": []map[string]interface{}{{"index": 0, "status": "rejected", "error": "bad"}},
+		})
+	})
+
+	client := New(Config{BaseURL: srv.URL, APIKey: "key", OnError: func(error) {}})
+	client.Track(Hit{Endpoint: "/a"})
+	client.Close(context.Background())
+
+	if stats := client.Stats(); stats.Sent != 0 || stats.Dropped != 1 || stats.Retries != 0 {
+		t.Errorf("stats = %+v, want one dropped hit and no retries", stats)
+	}
+}
+
+func TestRetryDoesNotHoldUpOtherBatches(t *testing.T) {
+	var calls atomic.Int32
+	srv := batchServer(t, func(w http.ResponseWriter, entries []Hit) {
+		if entries[0].Endpoint == "/slow" && calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"results":[]}`))
+	})
+
+	client := New(Config{BaseURL: srv.URL, APIKey: "key", BatchSize: 1, RetryBackoff: time.Second})
+	defer client.Close(context.Background())
+
+	client.Track(Hit{Endpoint: "/slow"})
+	time.Sleep(100 * time.Millisecond)
+	client.Track(Hit{Endpoint: "/fast"})
+
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for client.Stats().Sent < 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("second batch was held up by the first batch's retry")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stats := client.Stats(); stats.Sent != 2 || stats.Retries != 1 {
+		t.Errorf("stats = %+v, want 2 sent after 1 retry", stats)
+	}
+}
+
+func TestTrackAfterCloseIsDropped(t *testing.T) {
+	var received atomic.Int64
+	srv := batchServer(t, func(w http.ResponseWriter, entries []Hit) {
+		received.Add(int64(len(entries)))
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"results":[]}`))
+	})
+
+	client := New(Config{BaseURL: srv.URL, APIKey: "key", BatchSize: 10})
+
+	var wg sync.WaitGroup
+	var tracked atomic.Int64
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				if client.Track(Hit{Endpoint: "/a"}) {
+					tracked.Add(1)
+				}
+			}
+		}()
+	}
+	time.Sleep(time.Millisecond)
+	if err := client.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	if client.Track(Hit{Endpoint: "/late"}) {
+		t.Error("Track after Close returned true")
+	}
+
+	stats := client.Stats()
+	if stats.Queued != 0 {
+		t.Errorf("%d hits left in the queue after Close", stats.Queued)
+	}
+	if received.Load() != tracked.Load() || stats.Sent != uint64(tracked.Load()) {
+		t.Errorf("tracked %d hits, tracker received %d, sent %d", tracked.Load(), received.Load(), stats.Sent)
+	}
+	if stats.Sent+stats.Dropped != 8*200+1 {
+		t.Errorf("sent %d + dropped %d, want %d", stats.Sent, stats.Dropped, 8*200+1)
+	}
+}
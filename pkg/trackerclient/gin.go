@@ -0,0 +1,28 @@
+package trackerclient
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GinMiddleware tracks every request served by a Gin router. Hits are
+// reported under the matched route (e.g. /users/:id), or the raw path for
+// requests that matched no route.
+func (c *Client) GinMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+
+		ctx.Next()
+
+		size := ctx.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
+		endpoint := ctx.FullPath()
+		if endpoint == "" {
+			endpoint = ctx.Request.URL.Path
+		}
+		c.Track(c.requestHit(ctx.Request, endpoint, start, ctx.ClientIP(), ctx.Writer.Status(), uint64(size)))
+	}
+}
@@ -0,0 +1,82 @@
+package trackerclient
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// Middleware wraps an http.Handler and tracks every request it serves
+func (c *Client) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		ip := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+		c.Track(c.requestHit(r, r.URL.Path, start, ip, rec.status, rec.bytes))
+	})
+}
+
+// requestHit builds the hit for a finished request
+func (c *Client) requestHit(r *http.Request, endpoint string, start time.Time, ip string, status int, responseBytes uint64) Hit {
+	code := uint16(status)
+	latency := uint32(time.Since(start).Milliseconds())
+
+	hit := Hit{
+		Endpoint:      endpoint,
+		Timestamp:     &start,
+		IPAddress:     ip,
+		Method:        r.Method,
+		StatusCode:    &code,
+		LatencyMs:     &latency,
+		ResponseBytes: &responseBytes,
+		UserAgent:     r.UserAgent(),
+	}
+	if r.ContentLength >= 0 {
+		requestBytes := uint64(r.ContentLength)
+		hit.RequestBytes = &requestBytes
+	}
+	if c.cfg.UserID != nil {
+		hit.UserID = c.cfg.UserID(r)
+	}
+	return hit
+}
+
+// statusRecorder captures the status code and body size written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       uint64
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += uint64(n)
+	return n, err
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}